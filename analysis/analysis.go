@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// Cipher describes a block function with a configurable number of rounds, so
+// the analysis tools work the same on ChaCha, Salsa and their variants.
+//
+// The input of the block function is key || nonce || counter, all as bytes.
+type Cipher struct {
+	Name        string
+	KeySize     int
+	NonceSize   int
+	CounterSize int
+	OutputSize  int
+	// Rounds of the full cipher.
+	Rounds int
+
+	Block func(key, nonce, counter []byte, rounds int) []byte
+}
+
+// FromDesign describes an arx design.
+func FromDesign(d *arx.Design) Cipher {
+	return Cipher{
+		Name:        d.Name,
+		KeySize:     32,
+		NonceSize:   d.NonceSize(),
+		CounterSize: d.CounterSize(),
+		OutputSize:  64,
+		Rounds:      d.Rounds,
+		Block: func(key, nonce, counter []byte, rounds int) []byte {
+			c := make([]byte, 8)
+			copy(c, counter)
+			return d.BlockRounds(key, nonce, binary.LittleEndian.Uint64(c), rounds)
+		},
+	}
+}
+
+// ChaCha is the ChaCha block function from RFC 8439: 32 byte key, 12 byte
+// nonce and 4 byte counter.
+var ChaCha = fromDesign(chacha.Design())
+
+// Salsa is the Salsa20 block function: 32 byte key, 8 byte nonce and 8 byte
+// counter.
+var Salsa = fromDesign(salsa.Design())
+
+// fromDesign is FromDesign on a copy the caller can't get at.
+func fromDesign(d arx.Design) Cipher {
+	return FromDesign(&d)
+}
+
+var ciphers = map[string]Cipher{}
+
+// Register makes c available to Lookup.
+func Register(c Cipher) {
+	ciphers[c.Name] = c
+}
+
+// Lookup returns the cipher registered as name.
+func Lookup(name string) (Cipher, error) {
+	c, ok := ciphers[name]
+	if !ok {
+		return Cipher{}, fmt.Errorf("unknown cipher %q", name)
+	}
+	return c, nil
+}
+
+func init() {
+	Register(ChaCha)
+	Register(Salsa)
+}
+
+// InputSize is the size of key || nonce || counter.
+func (c Cipher) InputSize() int {
+	return c.KeySize + c.NonceSize + c.CounterSize
+}
+
+// Eval runs the block function on key || nonce || counter.
+func (c Cipher) Eval(input []byte, rounds int) []byte {
+	key := input[:c.KeySize]
+	nonce := input[c.KeySize : c.KeySize+c.NonceSize]
+	counter := input[c.KeySize+c.NonceSize : c.InputSize()]
+
+	return c.Block(key, nonce, counter, rounds)
+}
+
+// Part of the input.
+type Part string
+
+const (
+	Key     Part = "key"
+	Nonce   Part = "nonce"
+	Counter Part = "counter"
+	All     Part = "all"
+)
+
+// Bits returns the range of input bits [start, end) that belong to part.
+func (c Cipher) Bits(part Part) (int, int, error) {
+	k, n, ctr := c.KeySize*8, c.NonceSize*8, c.CounterSize*8
+
+	switch part {
+	case Key:
+		return 0, k, nil
+	case Nonce:
+		return k, k + n, nil
+	case Counter:
+		return k + n, k + n + ctr, nil
+	case All:
+		return 0, k + n + ctr, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown input part %q", part)
+	}
+}
+
+// FlipBit flips bit i of b, bits are numbered little endian inside each byte.
+func FlipBit(b []byte, i int) {
+	b[i/8] ^= 1 << (i % 8)
+}
+
+// Bit returns bit i of b.
+func Bit(b []byte, i int) int {
+	return int(b[i/8]>>(i%8)) & 1
+}
@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+func TestEval(t *testing.T) {
+	input := make([]byte, ChaCha.InputSize())
+	for i := range input {
+		input[i] = byte(i)
+	}
+
+	key := [32]byte(input[:32])
+	nonce := [12]byte(input[32:44])
+	// little endian counter 0x2f2e2d2c
+	expected := chacha.BlockRounds(key, 0x2f2e2d2c, nonce, 8)
+
+	if out := ChaCha.Eval(input, 8); !bytes.Equal(out, expected) {
+		t.Errorf("Eval() = %x, want %x", out, expected)
+	}
+
+	if out := Salsa.Eval(input[:Salsa.InputSize()], 20); len(out) != Salsa.OutputSize {
+		t.Errorf("Eval() returned %d bytes, want %d", len(out), Salsa.OutputSize)
+	}
+}
+
+func TestBits(t *testing.T) {
+	tests := []struct {
+		cipher Cipher
+		part   Part
+
+		start, end int
+	}{
+		{ChaCha, Key, 0, 256},
+		{ChaCha, Nonce, 256, 352},
+		{ChaCha, Counter, 352, 384},
+		{Salsa, Nonce, 256, 320},
+		{Salsa, Counter, 320, 384},
+		{Salsa, All, 0, 384},
+	}
+
+	for _, tt := range tests {
+		start, end, err := tt.cipher.Bits(tt.part)
+		if err != nil || start != tt.start || end != tt.end {
+			t.Errorf("%s Bits(%s) = %d, %d (%v), want %d, %d", tt.cipher.Name, tt.part, start, end, err, tt.start, tt.end)
+		}
+	}
+
+	if _, _, err := ChaCha.Bits("iv"); err == nil {
+		t.Errorf("Bits accepted an unknown part")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if c, err := Lookup("salsa"); err != nil || c.Name != "salsa" {
+		t.Errorf("Lookup(salsa) = %s, %v", c.Name, err)
+	}
+
+	if _, err := Lookup("rumba"); err == nil {
+		t.Errorf("Lookup accepted an unknown cipher")
+	}
+}
+
+func TestFlipBit(t *testing.T) {
+	b := []byte{0x00, 0x00}
+	FlipBit(b, 9)
+
+	if b[1] != 0x02 || Bit(b, 9) != 1 || Bit(b, 8) != 0 {
+		t.Errorf("FlipBit(9) = %x", b)
+	}
+}
@@ -0,0 +1,203 @@
+package avalanche
+
+import (
+	"encoding/csv"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+)
+
+// Result of flipping every input bit of a part over many random inputs.
+type Result struct {
+	Cipher  string
+	Part    analysis.Part
+	Rounds  int
+	Samples int
+
+	// Start is the first input bit of the part, Flips[i] is for input bit Start+i.
+	Start int
+	// Flips[i][j] is the probability that output bit j flips when input bit
+	// Start+i flips.
+	Flips [][]float64
+}
+
+// Analyze flips every input bit of part on samples random inputs and counts how
+// often each output bit changes. The seed makes runs repeatable.
+func Analyze(c analysis.Cipher, part analysis.Part, rounds, samples int, seed uint64) (*Result, error) {
+	start, end, err := c.Bits(part)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewPCG(seed, uint64(rounds)))
+	outBits := c.OutputSize * 8
+
+	counts := make([][]int, end-start)
+	for i := range counts {
+		counts[i] = make([]int, outBits)
+	}
+
+	input := make([]byte, c.InputSize())
+	for s := 0; s < samples; s++ {
+		for i := range input {
+			input[i] = byte(rng.Uint32())
+		}
+
+		base := c.Eval(input, rounds)
+
+		for i := start; i < end; i++ {
+			analysis.FlipBit(input, i)
+			out := c.Eval(input, rounds)
+			analysis.FlipBit(input, i)
+
+			for j := 0; j < outBits; j++ {
+				if analysis.Bit(out, j) != analysis.Bit(base, j) {
+					counts[i-start][j]++
+				}
+			}
+		}
+	}
+
+	r := &Result{Cipher: c.Name, Part: part, Rounds: rounds, Samples: samples, Start: start}
+	r.Flips = make([][]float64, len(counts))
+	for i := range counts {
+		r.Flips[i] = make([]float64, outBits)
+		for j := range counts[i] {
+			r.Flips[i][j] = float64(counts[i][j]) / float64(samples)
+		}
+	}
+
+	return r, nil
+}
+
+// Mean flip probability over every pair of input and output bits. A good
+// cipher flips half of the output bits.
+func (r *Result) Mean() float64 {
+	sum, n := 0.0, 0
+	for _, row := range r.Flips {
+		for _, p := range row {
+			sum += p
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// SAC is the strict avalanche criterion score: 1 minus the mean distance of
+// every flip probability to 1/2, scaled to [0, 1]. 1 means every output bit
+// flips with probability exactly 1/2, 0 means nothing diffuses (or everything
+// flips all the time).
+//
+// With a finite number of samples even a perfect function does not reach 1,
+// the expected score is about 1 - sqrt(2 / (pi * samples)).
+func (r *Result) SAC() float64 {
+	sum, n := 0.0, 0
+	for _, row := range r.Flips {
+		for _, p := range row {
+			sum += math.Abs(p - 0.5)
+			n++
+		}
+	}
+	return 1 - 2*sum/float64(n)
+}
+
+// WriteCSV writes one line per input bit: its index followed by the flip
+// probability of every output bit.
+func (r *Result) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"input bit"}
+	for j := range r.Flips[0] {
+		header = append(header, "out "+strconv.Itoa(j))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i, row := range r.Flips {
+		line := []string{strconv.Itoa(r.Start + i)}
+		for _, p := range row {
+			line = append(line, strconv.FormatFloat(p, 'f', 4, 64))
+		}
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Heatmap draws one pixel per input bit (rows) and output bit (columns), scaled
+// by scale. Probability 0 is blue, 1/2 is white and 1 is red, so a cipher with
+// full diffusion looks white.
+func (r *Result) Heatmap(scale int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, len(r.Flips[0])*scale, len(r.Flips)*scale))
+
+	for i, row := range r.Flips {
+		for j, p := range row {
+			c := heat(p)
+			for y := i * scale; y < (i+1)*scale; y++ {
+				for x := j * scale; x < (j+1)*scale; x++ {
+					img.Set(x, y, c)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// WritePNG encodes the heatmap as PNG.
+func (r *Result) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, r.Heatmap(scale))
+}
+
+func heat(p float64) color.RGBA {
+	if p < 0.5 {
+		v := uint8(255 * p * 2)
+		return color.RGBA{R: v, G: v, B: 255, A: 255}
+	}
+
+	v := uint8(255 * (1 - p) * 2)
+	return color.RGBA{R: 255, G: v, B: v, A: 255}
+}
+
+// Sweep runs Analyze for every round count from minRounds to maxRounds.
+func Sweep(c analysis.Cipher, part analysis.Part, minRounds, maxRounds, samples int, seed uint64) ([]*Result, error) {
+	var out []*Result
+	for rounds := minRounds; rounds <= maxRounds; rounds++ {
+		r, err := Analyze(c, part, rounds, samples, seed)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// WriteSummaryCSV writes the mean flip probability and SAC score per round count.
+func WriteSummaryCSV(w io.Writer, results []*Result) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"cipher", "part", "rounds", "samples", "mean", "sac"}); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		line := []string{r.Cipher, string(r.Part), strconv.Itoa(r.Rounds), strconv.Itoa(r.Samples),
+			strconv.FormatFloat(r.Mean(), 'f', 4, 64), strconv.FormatFloat(r.SAC(), 'f', 4, 64)}
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
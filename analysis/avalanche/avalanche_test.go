@@ -0,0 +1,130 @@
+package avalanche
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+)
+
+// Without rounds the output is the state added to itself, so flipping an
+// input bit flips exactly the next bit of the same word.
+func TestNoRounds(t *testing.T) {
+	r, err := Analyze(analysis.ChaCha, analysis.Key, 0, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// key bit 0 is bit 0 of word 4, it becomes bit 1 of word 4
+	for j, p := range r.Flips[0] {
+		expected := 0.0
+		if j == 4*32+1 {
+			expected = 1
+		}
+		if p != expected {
+			t.Errorf("flip of key bit 0 changes output bit %d with probability %f, want %f", j, p, expected)
+		}
+	}
+
+	if sac := r.SAC(); sac > 0.01 {
+		t.Errorf("SAC() = %f, want 0", sac)
+	}
+}
+
+func TestFullRounds(t *testing.T) {
+	for _, c := range []analysis.Cipher{analysis.ChaCha, analysis.Salsa} {
+		r, err := Analyze(c, analysis.Nonce, 20, 64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mean := r.Mean(); math.Abs(mean-0.5) > 0.01 {
+			t.Errorf("%s: Mean() = %f, want 0.5", c.Name, mean)
+		}
+
+		// what a random function gets with 64 samples
+		expected := 1 - math.Sqrt(2/(math.Pi*64))
+		if sac := r.SAC(); math.Abs(sac-expected) > 0.02 {
+			t.Errorf("%s: SAC() = %f, want about %f", c.Name, sac, expected)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	results, err := Sweep(analysis.ChaCha, analysis.Counter, 1, 3, 32, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// diffusion gets better with every round until it is saturated, after
+	// about 3 rounds
+	for i := 1; i < len(results); i++ {
+		if results[i].SAC() <= results[i-1].SAC() {
+			t.Errorf("SAC after %d rounds %f is not better than after %d rounds %f",
+				results[i].Rounds, results[i].SAC(), results[i-1].Rounds, results[i-1].SAC())
+		}
+	}
+
+	// same seed, same result
+	again, err := Analyze(analysis.ChaCha, analysis.Counter, 2, 32, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.SAC() != results[1].SAC() {
+		t.Errorf("same seed gave SAC %f and %f", again.SAC(), results[1].SAC())
+	}
+
+	var out bytes.Buffer
+	if err := WriteSummaryCSV(&out, results); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 || lines[1][2] != "1" || lines[3][2] != "3" {
+		t.Errorf("WriteSummaryCSV() = %v", lines)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	r, err := Analyze(analysis.Salsa, analysis.Counter, 3, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := r.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header plus 64 counter bits, index plus 512 output bits
+	if len(lines) != 65 || len(lines[1]) != 513 || lines[1][0] != "320" {
+		t.Errorf("WriteCSV() has %d lines of %d fields starting with %s", len(lines), len(lines[1]), lines[1][0])
+	}
+
+	out.Reset()
+	if err := r.WritePNG(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 1024 || b.Dy() != 128 {
+		t.Errorf("WritePNG() image is %dx%d, want 1024x128", b.Dx(), b.Dy())
+	}
+
+	if _, err := Analyze(analysis.Salsa, "iv", 3, 4, 1); err == nil {
+		t.Errorf("Analyze accepted an unknown part")
+	}
+}
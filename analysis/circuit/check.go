@@ -0,0 +1,99 @@
+package circuit
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+)
+
+// Check runs c, c written as ANF and read back, and c written as CNF, on
+// samples random inputs and compares them with the function c was built
+// from: the outputs must match, and the CNF must be satisfied by the values
+// of the wires and by nothing else, every gate variable being forced by its
+// clauses.
+func Check(c *Circuit, samples int, seed uint64) error {
+	var anf, cnf bytes.Buffer
+	if err := c.WriteANF(&anf); err != nil {
+		return err
+	}
+	if err := c.WriteCNF(&cnf); err != nil {
+		return err
+	}
+
+	parsed, err := ReadANF(&anf)
+	if err != nil {
+		return err
+	}
+	formula, err := ReadCNF(&cnf)
+	if err != nil {
+		return err
+	}
+	if formula.Variables != len(c.Gates)-1 || len(formula.Outputs) != len(c.Outputs) {
+		return fmt.Errorf("circuit: %s: CNF has %d variables and %d outputs", c.Name, formula.Variables, len(formula.Outputs))
+	}
+
+	// the clauses of each variable, to flip them one by one
+	occurs := make([][]int, formula.Variables+1)
+	for i, clause := range formula.Clauses {
+		for _, l := range clause {
+			occurs[max(l, -l)] = append(occurs[max(l, -l)], i)
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(seed, 0))
+	random := func(n int) []byte {
+		b := make([]byte, n/8)
+		for i := range b {
+			b[i] = byte(rng.Uint32())
+		}
+		return b
+	}
+
+	for i := 0; i < samples; i++ {
+		key, nonce, counter := random(len(c.Key)), random(len(c.Nonce)), random(len(c.Counter))
+		expected := c.reference(key, nonce, counter)
+
+		if got := c.Eval(key, nonce, counter); !bytes.Equal(got, expected) {
+			return fmt.Errorf("circuit: %s: got %x, expected %x", c.Name, got, expected)
+		}
+		if got := parsed.Eval(key, nonce, counter); !bytes.Equal(got, expected) {
+			return fmt.Errorf("circuit: %s: the ANF gives %x, expected %x", c.Name, got, expected)
+		}
+
+		assignment := c.wires(bitsOf(key, nonce, counter))
+		if clause := formula.Satisfied(assignment); clause >= 0 {
+			return fmt.Errorf("circuit: %s: the wires don't satisfy clause %v", c.Name, formula.Clauses[clause])
+		}
+
+		for j, l := range formula.Outputs {
+			value := l > 0 == assignment[max(l, -l)]
+			if value != (expected[j/8]>>(j%8)&1 == 1) {
+				return fmt.Errorf("circuit: %s: CNF output %d is wrong", c.Name, j)
+			}
+		}
+
+		if i > 0 {
+			continue
+		}
+
+		// flipping a gate must break one of its clauses
+		for v := 2 + len(c.Key) + len(c.Nonce) + len(c.Counter); v <= formula.Variables; v++ {
+			assignment[v] = !assignment[v]
+
+			broken := false
+			for _, j := range occurs[v] {
+				if (&CNF{Clauses: formula.Clauses[j : j+1]}).Satisfied(assignment) == 0 {
+					broken = true
+					break
+				}
+			}
+
+			assignment[v] = !assignment[v]
+			if !broken {
+				return fmt.Errorf("circuit: %s: the clauses don't force variable %d", c.Name, v)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package circuit turns reduced-round ChaCha and Salsa into circuits of AND,
+// XOR and NOT gates on bits, for algebraic and SAT-based cryptanalysis. The
+// additions modulo 2^32 become ripple-carry adders, the rotations just
+// rename bits, and the constants are folded in. Circuits are written in a
+// simple algebraic normal form text format (WriteANF) or as DIMACS CNF for
+// SAT solvers (WriteCNF); Check evaluates both against the real function.
+package circuit
+
+import (
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// Bit is a wire: False, True or the output of gate Bit.
+type Bit int
+
+const (
+	False Bit = 0
+	True  Bit = 1
+)
+
+// Op is what a gate computes.
+type Op uint8
+
+const (
+	Const Op = iota
+	Input
+	Not
+	Xor
+	And
+)
+
+// Gate computes Op of A and B. Not only uses A, and for inputs A is the
+// number of the input, counting the key, the nonce and the counter bits in
+// that order.
+type Gate struct {
+	Op   Op
+	A, B Bit
+}
+
+// Circuit computes Outputs from the bits of the key, nonce and counter.
+// Bits are numbered little endian: bit j of byte i is bit 8i+j, which is also
+// bit j%32 of the word (8i+j)/32.
+type Circuit struct {
+	Name string
+	// Gates[0] and Gates[1] are False and True; every other gate only uses
+	// gates before it.
+	Gates               []Gate
+	Key, Nonce, Counter []Bit
+	Outputs             []Bit
+
+	// what the circuit computes, for Check
+	design      *arx.Design
+	rounds      int
+	feedForward bool
+}
+
+func empty(name string) *Circuit {
+	return &Circuit{Name: name, Gates: []Gate{{Op: Const}, {Op: Const}}}
+}
+
+func (c *Circuit) gate(op Op, a, b Bit) Bit {
+	c.Gates = append(c.Gates, Gate{Op: op, A: a, B: b})
+	return Bit(len(c.Gates) - 1)
+}
+
+func (c *Circuit) inputs(n int) []Bit {
+	bits := make([]Bit, n)
+	for i := range bits {
+		bits[i] = c.gate(Input, Bit(len(c.Key)+len(c.Nonce)+len(c.Counter)+i), 0)
+	}
+	return bits
+}
+
+func (c *Circuit) not(a Bit) Bit {
+	switch {
+	case a == False:
+		return True
+	case a == True:
+		return False
+	case c.Gates[a].Op == Not:
+		return c.Gates[a].A
+	}
+	return c.gate(Not, a, 0)
+}
+
+func (c *Circuit) xor(a, b Bit) Bit {
+	switch {
+	case a == False:
+		return b
+	case b == False:
+		return a
+	case a == b:
+		return False
+	case a == True:
+		return c.not(b)
+	case b == True:
+		return c.not(a)
+	}
+	return c.gate(Xor, a, b)
+}
+
+func (c *Circuit) and(a, b Bit) Bit {
+	switch {
+	case a == False, b == False:
+		return False
+	case a == True, a == b:
+		return b
+	case b == True:
+		return a
+	}
+	return c.gate(And, a, b)
+}
+
+// Word is a 32 bit word of wires, bit 0 first.
+type Word [32]Bit
+
+// Constant is w as a word of False and True.
+func Constant(w uint32) Word {
+	var out Word
+	for i := range out {
+		out[i] = Bit(w >> i & 1)
+	}
+	return out
+}
+
+// Add is x + y modulo 2^32, a ripple-carry adder.
+func (c *Circuit) Add(x, y Word) Word {
+	var z Word
+	carry := False
+	for i := range z {
+		t := c.xor(x[i], y[i])
+		z[i] = c.xor(t, carry)
+		if i < 31 {
+			// the majority of x, y and carry: x and y can't both be 1 when t is
+			carry = c.xor(c.and(x[i], y[i]), c.and(carry, t))
+		}
+	}
+	return z
+}
+
+// Xor is x ⊕ y.
+func (c *Circuit) Xor(x, y Word) Word {
+	var z Word
+	for i := range z {
+		z[i] = c.xor(x[i], y[i])
+	}
+	return z
+}
+
+// RotateLeft is x <<< n, no gates needed.
+func RotateLeft(x Word, n int) Word {
+	var z Word
+	for i := range x {
+		z[(i+n)%32] = x[i]
+	}
+	return z
+}
+
+// QuarterRound adds the gates of a quarter round to c.
+type QuarterRound func(c *Circuit, a, b, cc, d Word) (Word, Word, Word, Word)
+
+// ChaChaQuarterRound is the quarter round of ChaCha.
+func ChaChaQuarterRound(c *Circuit, a, b, cc, d Word) (Word, Word, Word, Word) {
+	a = c.Add(a, b)
+	d = RotateLeft(c.Xor(d, a), 16)
+	cc = c.Add(cc, d)
+	b = RotateLeft(c.Xor(b, cc), 12)
+	a = c.Add(a, b)
+	d = RotateLeft(c.Xor(d, a), 8)
+	cc = c.Add(cc, d)
+	b = RotateLeft(c.Xor(b, cc), 7)
+	return a, b, cc, d
+}
+
+// SalsaQuarterRound is the quarter round of Salsa.
+func SalsaQuarterRound(c *Circuit, y0, y1, y2, y3 Word) (Word, Word, Word, Word) {
+	y1 = c.Xor(y1, RotateLeft(c.Add(y0, y3), 7))
+	y2 = c.Xor(y2, RotateLeft(c.Add(y1, y0), 9))
+	y3 = c.Xor(y3, RotateLeft(c.Add(y2, y1), 13))
+	y0 = c.Xor(y0, RotateLeft(c.Add(y3, y2), 18))
+	return y0, y1, y2, y3
+}
+
+// New builds the circuit of rounds rounds of d, with q as its quarter round,
+// and with or without the feed-forward. The outputs are the 512 bits of the
+// block.
+func New(d *arx.Design, q QuarterRound, rounds int, feedForward bool) *Circuit {
+	name := fmt.Sprintf("%s, %d rounds", d.Name, rounds)
+	if !feedForward {
+		name += ", no feed-forward"
+	}
+
+	c := empty(name)
+	c.design, c.rounds, c.feedForward = d, rounds, feedForward
+	c.Key = c.inputs(256)
+	c.Nonce = c.inputs(8 * d.NonceSize())
+	c.Counter = c.inputs(8 * d.CounterSize())
+
+	var state [16]Word
+	for i, w := range d.ConstantWords {
+		state[w] = Constant(d.Constants[i])
+	}
+	for i, w := range d.KeyWords {
+		state[w] = Word(c.Key[32*i:])
+	}
+	for i, w := range d.NonceWords {
+		state[w] = Word(c.Nonce[32*i:])
+	}
+	for i, w := range d.CounterWords {
+		state[w] = Word(c.Counter[32*i:])
+	}
+
+	z := state
+	for i := 0; i < rounds; i++ {
+		for _, w := range d.Schedule[i%len(d.Schedule)].Quarters {
+			z[w[0]], z[w[1]], z[w[2]], z[w[3]] = q(c, z[w[0]], z[w[1]], z[w[2]], z[w[3]])
+		}
+	}
+
+	for i := range z {
+		if feedForward {
+			z[i] = c.Add(z[i], state[i])
+		}
+		c.Outputs = append(c.Outputs, z[i][:]...)
+	}
+
+	return c
+}
+
+// Block is the ChaCha block function with rounds rounds.
+func Block(rounds int) *Circuit {
+	d := chacha.Design()
+	return New(&d, ChaChaQuarterRound, rounds, true)
+}
+
+// Hash is the Salsa hash function with rounds rounds.
+func Hash(rounds int) *Circuit {
+	d := salsa.Design()
+	return New(&d, SalsaQuarterRound, rounds, true)
+}
+
+// wires evaluates every gate with inputs, the key, nonce and counter bits.
+func (c *Circuit) wires(inputs []bool) []bool {
+	v := make([]bool, len(c.Gates))
+	for i, g := range c.Gates {
+		switch g.Op {
+		case Const:
+			v[i] = Bit(i) == True
+		case Input:
+			v[i] = inputs[g.A]
+		case Not:
+			v[i] = !v[g.A]
+		case Xor:
+			v[i] = v[g.A] != v[g.B]
+		case And:
+			v[i] = v[g.A] && v[g.B]
+		}
+	}
+	return v
+}
+
+func bitsOf(b ...[]byte) []bool {
+	var out []bool
+	for _, s := range b {
+		for _, x := range s {
+			for j := 0; j < 8; j++ {
+				out = append(out, x>>j&1 == 1)
+			}
+		}
+	}
+	return out
+}
+
+// Eval runs the circuit on the key, nonce and counter, the counter as little
+// endian bytes, and returns the outputs as bytes.
+func (c *Circuit) Eval(key, nonce, counter []byte) []byte {
+	v := c.wires(bitsOf(key, nonce, counter))
+
+	out := make([]byte, (len(c.Outputs)+7)/8)
+	for i, b := range c.Outputs {
+		if v[b] {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// reference is what the circuit should compute.
+func (c *Circuit) reference(key, nonce, counter []byte) []byte {
+	var n uint64
+	for i := len(counter) - 1; i >= 0; i-- {
+		n = n<<8 | uint64(counter[i])
+	}
+
+	if c.feedForward {
+		return c.design.BlockRounds(key, nonce, n, c.rounds)
+	}
+
+	state := c.design.State(key, nonce, n)
+	c.design.Permute(&state, c.rounds)
+	return arx.Bytes(state)
+}
@@ -0,0 +1,105 @@
+package circuit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+func TestCheck(t *testing.T) {
+	chachaDesign, salsaDesign := chacha.Design(), salsa.Design()
+	for _, c := range []*Circuit{
+		Block(0), Block(1), Block(2), Block(5),
+		Hash(1), Hash(2), Hash(5),
+		New(&chachaDesign, ChaChaQuarterRound, 3, false),
+		New(&salsaDesign, SalsaQuarterRound, 3, false),
+	} {
+		if err := Check(c, 8, 1); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// The full ciphers, once.
+func TestFullRounds(t *testing.T) {
+	for _, c := range []*Circuit{Block(20), Hash(20)} {
+		if err := Check(c, 1, 2); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// The first additions of ChaCha take a constant: folding it in leaves fewer
+// AND gates than adders of two unknown words need.
+func TestSize(t *testing.T) {
+	c := Block(1)
+
+	var gates int
+	for _, g := range c.Gates {
+		if g.Op == And {
+			gates++
+		}
+	}
+
+	// 4 quarter rounds of 4 additions of 31 carries, 2 AND gates each, and
+	// the feed-forward's 16 additions
+	if unfolded := 2 * 31 * (16 + 16); gates >= unfolded {
+		t.Errorf("Block(1): %d AND gates, expected fewer than %d", gates, unfolded)
+	}
+}
+
+func TestWrongCircuit(t *testing.T) {
+	c := Block(2)
+	// turn the last XOR into an AND or the other way round
+	for i := len(c.Gates) - 1; ; i-- {
+		if g := &c.Gates[i]; g.Op == Xor || g.Op == And {
+			g.Op = And + Xor - g.Op
+			break
+		}
+	}
+
+	if err := Check(c, 4, 1); err == nil {
+		t.Error("Check: expected an error for a wrong gate")
+	}
+}
+
+func TestANF(t *testing.T) {
+	var anf bytes.Buffer
+	if err := Hash(1).WriteANF(&anf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"# salsa, 1 rounds\n", "key 256\nnonce 64\ncounter 64\n", "o511 = x"} {
+		if !strings.Contains(anf.String(), expected) {
+			t.Errorf("WriteANF: no %q", expected)
+		}
+	}
+
+	for _, bad := range []string{
+		"key 256\nx3 = k0 + k1\n",
+		"key 2\nx4 = k0 + k2\n",
+		"key 2\nx4 = k0 - k1\n",
+		"key 2\no1 = k0\n",
+	} {
+		if _, err := ReadANF(strings.NewReader(bad)); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ReadANF(%q): expected ErrSyntax, got %v", bad, err)
+		}
+	}
+}
+
+func TestCNF(t *testing.T) {
+	var cnf bytes.Buffer
+	if err := Block(1).WriteCNF(&cnf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"c key 2-257\n", "c nonce 258-353\n", "c counter 354-385\n", "\n1 0\n"} {
+		if !strings.Contains(cnf.String(), expected) {
+			t.Errorf("WriteCNF: no %q", expected)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package circuit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// name is how b is written: 0, 1, k, n or c and the number of the key, nonce
+// or counter bit, or x and the number of the gate.
+func (c *Circuit) name(b Bit) string {
+	g := c.Gates[b]
+	switch {
+	case g.Op == Const:
+		return strconv.Itoa(int(b))
+	case g.Op != Input:
+		return fmt.Sprintf("x%d", b)
+	case int(g.A) < len(c.Key):
+		return fmt.Sprintf("k%d", g.A)
+	case int(g.A) < len(c.Key)+len(c.Nonce):
+		return fmt.Sprintf("n%d", int(g.A)-len(c.Key))
+	default:
+		return fmt.Sprintf("c%d", int(g.A)-len(c.Key)-len(c.Nonce))
+	}
+}
+
+// WriteANF writes the circuit as one equation over GF(2) per gate, + being
+// XOR and * AND, then one per output bit:
+//
+//	key 256
+//	nonce 96
+//	counter 32
+//	x390 = k0 + k128
+//	x391 = k0 * k128
+//	x392 = x391 + 1
+//	...
+//	o511 = x57381
+func (c *Circuit) WriteANF(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# %s\n", c.Name)
+	fmt.Fprintln(out, "# k, n and c are the key, nonce and counter bits and o the output bits,")
+	fmt.Fprintln(out, "# bit 8i+j being bit j of byte i")
+	fmt.Fprintf(out, "key %d\nnonce %d\ncounter %d\n", len(c.Key), len(c.Nonce), len(c.Counter))
+
+	for i, g := range c.Gates {
+		switch g.Op {
+		case Not:
+			fmt.Fprintf(out, "x%d = %s + 1\n", i, c.name(g.A))
+		case Xor:
+			fmt.Fprintf(out, "x%d = %s + %s\n", i, c.name(g.A), c.name(g.B))
+		case And:
+			fmt.Fprintf(out, "x%d = %s * %s\n", i, c.name(g.A), c.name(g.B))
+		}
+	}
+
+	for i, b := range c.Outputs {
+		fmt.Fprintf(out, "o%d = %s\n", i, c.name(b))
+	}
+
+	return out.Flush()
+}
+
+// ErrSyntax means the ANF or CNF doesn't parse.
+var ErrSyntax = errors.New("circuit: syntax error")
+
+// ReadANF reads a circuit written by WriteANF. Gates must come in order.
+func ReadANF(r io.Reader) (*Circuit, error) {
+	c := empty("")
+	lines := bufio.NewScanner(r)
+	lines.Buffer(nil, 1<<20)
+
+	for n := 1; lines.Scan(); n++ {
+		line := lines.Text()
+		syntax := func() error {
+			return fmt.Errorf("%w: line %d: %q", ErrSyntax, n, line)
+		}
+
+		if name, ok := strings.CutPrefix(line, "# "); ok && c.Name == "" {
+			c.Name = name
+			continue
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 0 || len(c.Gates) != 2+len(c.Key)+len(c.Nonce)+len(c.Counter) {
+				return nil, syntax()
+			}
+
+			switch fields[0] {
+			case "key":
+				c.Key = append(c.Key, c.inputs(size)...)
+			case "nonce":
+				c.Nonce = append(c.Nonce, c.inputs(size)...)
+			case "counter":
+				c.Counter = append(c.Counter, c.inputs(size)...)
+			default:
+				return nil, syntax()
+			}
+			continue
+		}
+
+		if len(fields) != 3 && len(fields) != 5 || fields[1] != "=" {
+			return nil, syntax()
+		}
+
+		a, err := c.parse(fields[2])
+		if err != nil {
+			return nil, syntax()
+		}
+
+		// an output
+		if o, ok := strings.CutPrefix(fields[0], "o"); ok && len(fields) == 3 {
+			if o != strconv.Itoa(len(c.Outputs)) {
+				return nil, syntax()
+			}
+			c.Outputs = append(c.Outputs, a)
+			continue
+		}
+
+		if fields[0] != fmt.Sprintf("x%d", len(c.Gates)) || len(fields) != 5 {
+			return nil, syntax()
+		}
+
+		b, err := c.parse(fields[4])
+		switch {
+		case err != nil:
+			return nil, syntax()
+		case fields[3] == "*":
+			c.gate(And, a, b)
+		case fields[3] == "+" && b == True:
+			c.gate(Not, a, 0)
+		case fields[3] == "+":
+			c.gate(Xor, a, b)
+		default:
+			return nil, syntax()
+		}
+	}
+
+	return c, lines.Err()
+}
+
+// parse is the inverse of name.
+func (c *Circuit) parse(s string) (Bit, error) {
+	if s == "0" || s == "1" {
+		return Bit(s[0] - '0'), nil
+	}
+	if len(s) < 2 {
+		return 0, ErrSyntax
+	}
+
+	i, err := strconv.Atoi(s[1:])
+	if err != nil || i < 0 {
+		return 0, ErrSyntax
+	}
+
+	var bits []Bit
+	switch s[0] {
+	case 'x':
+		if i < 2 || i >= len(c.Gates) {
+			return 0, ErrSyntax
+		}
+		return Bit(i), nil
+	case 'k':
+		bits = c.Key
+	case 'n':
+		bits = c.Nonce
+	case 'c':
+		bits = c.Counter
+	}
+
+	if i >= len(bits) {
+		return 0, ErrSyntax
+	}
+	return bits[i], nil
+}
+
+// literal is the DIMACS literal of b: variable 1 is True, and gate i is
+// variable i.
+func literal(b Bit) int {
+	if b == False {
+		return -1
+	}
+	return int(b)
+}
+
+// WriteCNF writes the circuit as DIMACS CNF for a SAT solver. Variable 1 is
+// always true, the key, nonce and counter bits follow in order, and then one
+// variable per gate. Comments list the variables of the inputs and the
+// literal of each output bit, like
+//
+//	c key 2-257
+//	c output 0 -5012
+//
+// Fixing the outputs and some inputs with unit clauses and solving for the
+// rest is the attack.
+func (c *Circuit) WriteCNF(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	var clauses int
+	for _, g := range c.Gates {
+		clauses += map[Op]int{Not: 2, Xor: 4, And: 3}[g.Op]
+	}
+
+	fmt.Fprintf(out, "c %s\n", c.Name)
+	for _, input := range []struct {
+		name string
+		bits []Bit
+	}{{"key", c.Key}, {"nonce", c.Nonce}, {"counter", c.Counter}} {
+		if len(input.bits) > 0 {
+			fmt.Fprintf(out, "c %s %d-%d\n", input.name, input.bits[0], input.bits[len(input.bits)-1])
+		}
+	}
+	for i, b := range c.Outputs {
+		fmt.Fprintf(out, "c output %d %d\n", i, literal(b))
+	}
+
+	fmt.Fprintf(out, "p cnf %d %d\n", len(c.Gates)-1, clauses+1)
+	fmt.Fprintln(out, "1 0")
+
+	for i, g := range c.Gates {
+		z, a, b := i, literal(g.A), literal(g.B)
+
+		switch g.Op {
+		case Not:
+			fmt.Fprintf(out, "%d %d 0\n%d %d 0\n", z, a, -z, -a)
+		case Xor:
+			fmt.Fprintf(out, "%d %d %d 0\n%d %d %d 0\n%d %d %d 0\n%d %d %d 0\n",
+				-z, a, b, -z, -a, -b, z, -a, b, z, a, -b)
+		case And:
+			fmt.Fprintf(out, "%d %d 0\n%d %d 0\n%d %d %d 0\n", -z, a, -z, b, z, -a, -b)
+		}
+	}
+
+	return out.Flush()
+}
+
+// CNF is a formula read by ReadCNF.
+type CNF struct {
+	Variables int
+	Clauses   [][]int
+	// Outputs has the literals of the output bits, from the comments.
+	Outputs []int
+}
+
+// ReadCNF reads DIMACS CNF and the output comments of WriteCNF.
+func ReadCNF(r io.Reader) (*CNF, error) {
+	f := &CNF{}
+	lines := bufio.NewScanner(r)
+	var clause []int
+
+	for n := 1; lines.Scan(); n++ {
+		fields := strings.Fields(lines.Text())
+		syntax := fmt.Errorf("%w: line %d: %q", ErrSyntax, n, lines.Text())
+
+		switch {
+		case len(fields) == 0:
+			continue
+
+		case fields[0] == "c":
+			if len(fields) == 4 && fields[1] == "output" {
+				l, err := strconv.Atoi(fields[3])
+				if err != nil || fields[2] != strconv.Itoa(len(f.Outputs)) {
+					return nil, syntax
+				}
+				f.Outputs = append(f.Outputs, l)
+			}
+
+		case fields[0] == "p":
+			if len(fields) != 4 || fields[1] != "cnf" {
+				return nil, syntax
+			}
+			v, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, syntax
+			}
+			f.Variables = v
+
+		default:
+			for _, field := range fields {
+				l, err := strconv.Atoi(field)
+				if err != nil || l > f.Variables || -l > f.Variables {
+					return nil, syntax
+				}
+				if l == 0 {
+					f.Clauses = append(f.Clauses, clause)
+					clause = nil
+					continue
+				}
+				clause = append(clause, l)
+			}
+		}
+	}
+
+	if len(clause) > 0 {
+		return nil, fmt.Errorf("%w: unterminated clause", ErrSyntax)
+	}
+	return f, lines.Err()
+}
+
+// Satisfied returns the first clause assignment doesn't satisfy, or -1.
+// assignment[v] is the value of variable v.
+func (f *CNF) Satisfied(assignment []bool) int {
+	for i, clause := range f.Clauses {
+		ok := false
+		for _, l := range clause {
+			if l > 0 == assignment[max(l, -l)] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return i
+		}
+	}
+	return -1
+}
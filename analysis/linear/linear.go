@@ -0,0 +1,497 @@
+// Package linear looks for linear approximations of reduced-round ChaCha and
+// Salsa: input and output masks over the state whose parities agree more or
+// less often than half of the time. The correlation of a pair of masks is
+// measured on random states (Correlation) or estimated along a linear trail,
+// multiplying the exact correlations of the modular additions on the way
+// (Trail); Back and Forward build trails greedily from one end, Search builds
+// them backwards from single output bits.
+//
+// The approximations are of the permutation: the rounds without the
+// feed-forward.
+package linear
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// Op is an operation of a quarter round.
+type Op uint8
+
+const (
+	// Add is Dst = A + B.
+	Add Op = iota
+	// Xor is Dst = A ^ B.
+	Xor
+	// Rotate is Dst = A <<< N.
+	Rotate
+)
+
+// Step of a quarter round, on four registers for the four words and a fifth
+// one for temporary values.
+type Step struct {
+	Op        Op
+	Dst, A, B int
+	N         int
+}
+
+// Cipher is a design with its quarter round spelled out step by step, so
+// masks can go through it backwards.
+type Cipher struct {
+	Design *arx.Design
+	Steps  []Step
+}
+
+func designOf(d arx.Design) *arx.Design {
+	return &d
+}
+
+// temp is the register for temporary values.
+const temp = 4
+
+var (
+	ChaCha = Cipher{Design: designOf(chacha.Design()), Steps: []Step{
+		{Op: Add, Dst: 0, A: 0, B: 1}, {Op: Xor, Dst: 3, A: 3, B: 0}, {Op: Rotate, Dst: 3, A: 3, N: 16},
+		{Op: Add, Dst: 2, A: 2, B: 3}, {Op: Xor, Dst: 1, A: 1, B: 2}, {Op: Rotate, Dst: 1, A: 1, N: 12},
+		{Op: Add, Dst: 0, A: 0, B: 1}, {Op: Xor, Dst: 3, A: 3, B: 0}, {Op: Rotate, Dst: 3, A: 3, N: 8},
+		{Op: Add, Dst: 2, A: 2, B: 3}, {Op: Xor, Dst: 1, A: 1, B: 2}, {Op: Rotate, Dst: 1, A: 1, N: 7},
+	}}
+
+	Salsa = Cipher{Design: designOf(salsa.Design()), Steps: []Step{
+		{Op: Add, Dst: temp, A: 0, B: 3}, {Op: Rotate, Dst: temp, A: temp, N: 7}, {Op: Xor, Dst: 1, A: 1, B: temp},
+		{Op: Add, Dst: temp, A: 1, B: 0}, {Op: Rotate, Dst: temp, A: temp, N: 9}, {Op: Xor, Dst: 2, A: 2, B: temp},
+		{Op: Add, Dst: temp, A: 2, B: 1}, {Op: Rotate, Dst: temp, A: temp, N: 13}, {Op: Xor, Dst: 3, A: 3, B: temp},
+		{Op: Add, Dst: temp, A: 3, B: 2}, {Op: Rotate, Dst: temp, A: temp, N: 18}, {Op: Xor, Dst: 0, A: 0, B: temp},
+	}}
+)
+
+// quarterRound runs the steps on four words, to check they are the quarter
+// round of the design.
+func (c Cipher) quarterRound(a, b, cc, d uint32) (uint32, uint32, uint32, uint32) {
+	r := [5]uint32{a, b, cc, d}
+	for _, s := range c.Steps {
+		switch s.Op {
+		case Add:
+			r[s.Dst] = r[s.A] + r[s.B]
+		case Xor:
+			r[s.Dst] = r[s.A] ^ r[s.B]
+		case Rotate:
+			r[s.Dst] = bits.RotateLeft32(r[s.A], s.N)
+		}
+	}
+	return r[0], r[1], r[2], r[3]
+}
+
+// Mask selects bits of the state.
+type Mask [16]uint32
+
+// parity is the XOR of the bits of state that m selects.
+func (m Mask) parity(state *[16]uint32) uint32 {
+	var p uint32
+	for i, w := range m {
+		p ^= w & state[i]
+	}
+	return uint32(bits.OnesCount32(p) & 1)
+}
+
+// Weight is the number of bits in m.
+func (m Mask) Weight() int {
+	var n int
+	for _, w := range m {
+		n += bits.OnesCount32(w)
+	}
+	return n
+}
+
+// String lists the bits as [word]_bit, like the differentials.
+func (m Mask) String() string {
+	var b []string
+	for i, w := range m {
+		for j := 0; j < 32; j++ {
+			if w>>j&1 == 1 {
+				b = append(b, fmt.Sprintf("[%d]_%d", i, j))
+			}
+		}
+	}
+	if len(b) == 0 {
+		return "0"
+	}
+	return strings.Join(b, "+")
+}
+
+// ParseMask reads a mask as word:bit pairs separated by commas, like
+// "0:0,12:7".
+func ParseMask(s string) (Mask, error) {
+	var m Mask
+	for _, field := range strings.Split(s, ",") {
+		word, bit, ok := strings.Cut(strings.TrimSpace(field), ":")
+		w, err1 := strconv.Atoi(word)
+		b, err2 := strconv.Atoi(bit)
+		if !ok || err1 != nil || err2 != nil || w < 0 || w > 15 || b < 0 || b > 31 {
+			return Mask{}, fmt.Errorf("linear: bad mask bit %q, expected word:bit", field)
+		}
+		m[w] |= 1 << b
+	}
+	return m, nil
+}
+
+// Correlation measures the correlation between the parity of in on random
+// states and the parity of out after rounds rounds: 2·Pr[equal] - 1.
+func (c Cipher) Correlation(in, out Mask, rounds, samples int, seed uint64) (float64, error) {
+	if samples < 1 {
+		return 0, errors.New("linear: samples must be at least 1")
+	}
+
+	rng := rand.New(rand.NewPCG(seed, 0))
+
+	var equal int
+	for i := 0; i < samples; i++ {
+		var state [16]uint32
+		for j := range state {
+			state[j] = rng.Uint32()
+		}
+
+		p := in.parity(&state)
+		c.Design.Permute(&state, rounds)
+		if p == out.parity(&state) {
+			equal++
+		}
+	}
+
+	return 2*float64(equal)/float64(samples) - 1, nil
+}
+
+// AddCorrelation is the exact correlation of u·(x + y) ⊕ v·x ⊕ w·y over
+// random x and y. It goes bit by bit keeping, for each value of the carry,
+// the signed share of the inputs that lead to it.
+func AddCorrelation(u, v, w uint32) float64 {
+	carries := [2]float64{1, 0}
+
+	for i := 0; i < 32; i++ {
+		ui, vi, wi := u>>i&1, v>>i&1, w>>i&1
+
+		var next [2]float64
+		for carry := uint32(0); carry < 2; carry++ {
+			if carries[carry] == 0 {
+				continue
+			}
+			for x := uint32(0); x < 2; x++ {
+				for y := uint32(0); y < 2; y++ {
+					z := x ^ y ^ carry
+					term := carries[carry] / 4
+					if ui&z^vi&x^wi&y == 1 {
+						term = -term
+					}
+					next[x&y|x&carry|y&carry] += term
+				}
+			}
+		}
+		carries = next
+	}
+
+	return carries[0] + carries[1]
+}
+
+// bestAdd returns input masks for output mask u of an addition with a large
+// correlation: starting from v = w = u it flips single bits of v or w, up to
+// the highest bit of u, as long as that improves the correlation or keeps it
+// with fewer bits in the masks, as every bit has to go through the rounds
+// before.
+func bestAdd(u uint32) (uint32, uint32, float64) {
+	if u == 0 {
+		return 0, 0, 1
+	}
+
+	v, w := improve(u, 32-bits.LeadingZeros32(u), func(v, w uint32) float64 {
+		return AddCorrelation(u, v, w)
+	})
+	return v, w, AddCorrelation(u, v, w)
+}
+
+// bestAddForward returns the masks on the sum and on y of an addition with
+// mask v on x, the way bestAdd does. The carries go up, so any bit of the sum
+// can be in its mask.
+func bestAddForward(v uint32) (uint32, uint32, float64) {
+	if v == 0 {
+		return 0, 0, 1
+	}
+
+	u, w := improve(v, 32, func(u, w uint32) float64 {
+		return AddCorrelation(u, v, w)
+	})
+	return u, w, AddCorrelation(u, v, w)
+}
+
+// improve starts from two masks equal to m and flips single bits of either
+// below top while that improves |corr| or keeps it with fewer bits.
+func improve(m uint32, top int, corr func(a, b uint32) float64) (uint32, uint32) {
+	a, b := m, m
+	best := math.Abs(corr(a, b))
+	weight := bits.OnesCount32(a) + bits.OnesCount32(b)
+
+	for improved := true; improved; {
+		improved = false
+		ba, bb := a, b
+
+		for i := 0; i < top; i++ {
+			for _, flip := range [][2]uint32{{1 << i, 0}, {0, 1 << i}, {1 << i, 1 << i}} {
+				fa, fb := a^flip[0], b^flip[1]
+				c := math.Abs(corr(fa, fb))
+				n := bits.OnesCount32(fa) + bits.OnesCount32(fb)
+
+				if c > best || c == best && n < weight {
+					best, weight, ba, bb, improved = c, n, fa, fb, true
+				}
+			}
+		}
+		a, b = ba, bb
+	}
+
+	return a, b
+}
+
+// Trail is a linear trail: the masks on the state before the first round and
+// after each round, and the correlation of each round.
+type Trail struct {
+	Masks        []Mask
+	Correlations []float64
+}
+
+// Correlation is the estimated correlation of the trail, the product of the
+// correlations of its rounds.
+func (t Trail) Correlation() float64 {
+	c := 1.0
+	for _, r := range t.Correlations {
+		c *= r
+	}
+	return c
+}
+
+// In is the mask on the input of the trail.
+func (t Trail) In() Mask {
+	return t.Masks[0]
+}
+
+// Out is the mask on the output of the trail.
+func (t Trail) Out() Mask {
+	return t.Masks[len(t.Masks)-1]
+}
+
+// quarterBack takes the masks on the output of a quarter round to masks on
+// its input, choosing the input masks of each addition with bestAdd, and
+// returns the correlation.
+func (c Cipher) quarterBack(m *[5]uint32) float64 {
+	corr := 1.0
+
+	for i := len(c.Steps) - 1; i >= 0; i-- {
+		s := c.Steps[i]
+		u := m[s.Dst]
+		// the old value of Dst is overwritten, unless it is also an input
+		m[s.Dst] = 0
+
+		switch s.Op {
+		case Rotate:
+			m[s.A] ^= bits.RotateLeft32(u, -s.N)
+		case Xor:
+			m[s.A] ^= u
+			m[s.B] ^= u
+		case Add:
+			v, w, a := bestAdd(u)
+			m[s.A] ^= v
+			m[s.B] ^= w
+			corr *= a
+		}
+	}
+
+	return corr
+}
+
+// Back builds a trail greedily backwards from out, one addition at a time.
+func (c Cipher) Back(out Mask, rounds int) Trail {
+	t := Trail{Masks: make([]Mask, rounds+1), Correlations: make([]float64, rounds)}
+	t.Masks[rounds] = out
+
+	m := out
+	for r := rounds - 1; r >= 0; r-- {
+		corr := 1.0
+		for _, q := range c.Design.Schedule[r%len(c.Design.Schedule)].Quarters {
+			registers := [5]uint32{m[q[0]], m[q[1]], m[q[2]], m[q[3]]}
+			corr *= c.quarterBack(&registers)
+			m[q[0]], m[q[1]], m[q[2]], m[q[3]] = registers[0], registers[1], registers[2], registers[3]
+		}
+
+		t.Masks[r] = m
+		t.Correlations[r] = corr
+	}
+
+	return t
+}
+
+// fresh reports whether s writes a new value to Dst instead of updating it.
+func fresh(s Step) bool {
+	return s.Dst != s.A && (s.Op == Rotate || s.Dst != s.B)
+}
+
+// forwardStep takes the masks on the input of a step that updates Dst to
+// masks on its output, choosing the masks of an addition with bestAddForward,
+// and returns the correlation.
+func forwardStep(s Step, m *[5]uint32) float64 {
+	other := s.B
+	if s.Dst == s.B {
+		other = s.A
+	}
+
+	switch s.Op {
+	case Rotate:
+		m[s.Dst] = bits.RotateLeft32(m[s.Dst], s.N)
+	case Xor:
+		m[other] ^= m[s.Dst]
+	case Add:
+		u, w, a := bestAddForward(m[s.Dst])
+		m[s.Dst] = u
+		m[other] ^= w
+		return a
+	}
+	return 1
+}
+
+// owed is the mask the new value of step i must carry so that none is left on
+// the register when it is written again, or at the end. Only rotations of the
+// register change that mask on the way, so it is what is left without one,
+// rotated back. It fails when the register is updated in another way.
+func (c Cipher) owed(m [5]uint32, i int) (uint32, bool) {
+	d := c.Steps[i].Dst
+	m[d] = 0
+	rotation := 0
+
+	for _, s := range c.Steps[i+1:] {
+		if fresh(s) {
+			if s.Dst == d {
+				break
+			}
+			return 0, false
+		}
+		if s.Dst == d {
+			if s.Op != Rotate {
+				return 0, false
+			}
+			rotation += s.N
+		}
+		forwardStep(s, &m)
+	}
+
+	return bits.RotateLeft32(m[d], -rotation), true
+}
+
+// quarterForward takes the masks on the input of a quarter round to masks on
+// its output and returns the correlation. It fails when a value with a mask
+// is overwritten or a mask can't be carried forward.
+func (c Cipher) quarterForward(m *[5]uint32) (float64, bool) {
+	corr := 1.0
+
+	for i, s := range c.Steps {
+		if !fresh(s) {
+			corr *= forwardStep(s, m)
+			continue
+		}
+
+		// the old value of Dst is gone
+		if m[s.Dst] != 0 {
+			return 0, false
+		}
+		u, ok := c.owed(*m, i)
+		if !ok {
+			return 0, false
+		}
+
+		switch s.Op {
+		case Rotate:
+			m[s.A] ^= bits.RotateLeft32(u, -s.N)
+		case Xor:
+			m[s.A] ^= u
+			m[s.B] ^= u
+		case Add:
+			v, w, a := bestAdd(u)
+			m[s.A] ^= v
+			m[s.B] ^= w
+			corr *= a
+		}
+		m[s.Dst] = u
+	}
+
+	return corr, m[temp] == 0
+}
+
+// Forward builds a trail greedily forwards from in, like Back. It fails when
+// the steps of c can't carry a mask forwards.
+func (c Cipher) Forward(in Mask, rounds int) (Trail, bool) {
+	t := Trail{Masks: make([]Mask, rounds+1), Correlations: make([]float64, rounds)}
+	t.Masks[0] = in
+
+	m := in
+	for r := 0; r < rounds; r++ {
+		corr := 1.0
+		for _, q := range c.Design.Schedule[r%len(c.Design.Schedule)].Quarters {
+			registers := [5]uint32{m[q[0]], m[q[1]], m[q[2]], m[q[3]]}
+			qc, ok := c.quarterForward(&registers)
+			if !ok {
+				return Trail{}, false
+			}
+			corr *= qc
+			m[q[0]], m[q[1]], m[q[2]], m[q[3]] = registers[0], registers[1], registers[2], registers[3]
+		}
+
+		t.Masks[r+1] = m
+		t.Correlations[r] = corr
+	}
+
+	return t, true
+}
+
+// Theoretical estimates the correlation of in and out after rounds rounds
+// along a trail between them: the one Back builds from out or the one Forward
+// builds from in, whichever ends at the other mask. Without one it returns 0
+// and false.
+//
+// It does not search for a trail towards the other mask: both trails are
+// greedy, so it only gives an estimate when in and out are the two ends of
+// one of them, like the masks Back, Forward and Search return. Most other
+// pairs of masks have none.
+func (c Cipher) Theoretical(in, out Mask, rounds int) (float64, bool) {
+	if t := c.Back(out, rounds); t.In() == in {
+		return t.Correlation(), true
+	}
+	if t, ok := c.Forward(in, rounds); ok && t.Out() == out {
+		return t.Correlation(), true
+	}
+	return 0, false
+}
+
+// Search builds a trail back from every single output bit and returns the
+// best ones, largest correlation first.
+func (c Cipher) Search(rounds, best int) []Trail {
+	var trails []Trail
+	for word := 0; word < 16; word++ {
+		for bit := 0; bit < 32; bit++ {
+			var out Mask
+			out[word] = 1 << bit
+			trails = append(trails, c.Back(out, rounds))
+		}
+	}
+
+	slices.SortStableFunc(trails, func(a, b Trail) int {
+		return cmp.Compare(math.Abs(b.Correlation()), math.Abs(a.Correlation()))
+	})
+	return trails[:min(best, len(trails))]
+}
@@ -0,0 +1,180 @@
+package linear
+
+import (
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestQuarterRound(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 0))
+
+	for _, c := range []Cipher{ChaCha, Salsa} {
+		for i := 0; i < 100; i++ {
+			a, b, cc, d := rng.Uint32(), rng.Uint32(), rng.Uint32(), rng.Uint32()
+
+			w0, w1, w2, w3 := c.Design.QuarterRound(a, b, cc, d)
+			g0, g1, g2, g3 := c.quarterRound(a, b, cc, d)
+			if w0 != g0 || w1 != g1 || w2 != g2 || w3 != g3 {
+				t.Fatalf("%s: the steps aren't the quarter round", c.Design.Name)
+			}
+		}
+	}
+}
+
+func TestAddCorrelation(t *testing.T) {
+	for _, tt := range []struct {
+		u, v, w uint32
+		c       float64
+	}{
+		// the lowest bit has no carry
+		{1, 1, 1, 1},
+		// the carry into bit 1 is x0·y0, 1 a quarter of the time
+		{2, 2, 2, 0.5},
+		{4, 4, 4, 0.25},
+		{4, 6, 4, 0.5},
+		// bits above the output mask can't matter
+		{1, 3, 1, 0},
+	} {
+		if c := AddCorrelation(tt.u, tt.v, tt.w); c != tt.c {
+			t.Errorf("AddCorrelation(%x, %x, %x): expected %v, got %v", tt.u, tt.v, tt.w, tt.c, c)
+		}
+	}
+
+	// against random additions
+	rng := rand.New(rand.NewPCG(2, 0))
+	for i := 0; i < 20; i++ {
+		u := rng.Uint32() & 0xff
+		v, w, c := bestAdd(u)
+
+		var equal int
+		const samples = 1 << 16
+		for j := 0; j < samples; j++ {
+			x, y := rng.Uint32(), rng.Uint32()
+			if bits.OnesCount32(u&(x+y)^v&x^w&y)%2 == 0 {
+				equal++
+			}
+		}
+
+		if e := 2*float64(equal)/samples - 1; math.Abs(e-c) > 0.02 {
+			t.Errorf("AddCorrelation(%x, %x, %x) = %v, measured %v", u, v, w, c, e)
+		}
+	}
+}
+
+func TestBestAdd(t *testing.T) {
+	// one bit above the lowest: 1/2, the best there is
+	for i := 1; i < 32; i++ {
+		if _, _, c := bestAdd(1 << i); math.Abs(c) != 0.5 {
+			t.Errorf("bestAdd(1<<%d): correlation %v", i, c)
+		}
+	}
+}
+
+// One round has trails good enough to measure.
+func TestSearch(t *testing.T) {
+	for _, tt := range []struct {
+		cipher Cipher
+		best   float64
+	}{
+		{ChaCha, 0.25},
+		{Salsa, 1},
+	} {
+		trails := tt.cipher.Search(1, 4)
+		if len(trails) != 4 {
+			t.Fatalf("Search: %d trails", len(trails))
+		}
+
+		for _, trail := range trails {
+			theory := trail.Correlation()
+			if math.Abs(theory) != tt.best {
+				t.Errorf("%s: trail %s -> %s with correlation %v, expected %v", tt.cipher.Design.Name, trail.In(), trail.Out(), theory, tt.best)
+			}
+
+			if e, _ := tt.cipher.Correlation(trail.In(), trail.Out(), 1, 1<<14, 1); math.Abs(e-theory) > 0.05 {
+				t.Errorf("%s: trail %s -> %s: estimated %v, measured %v", tt.cipher.Design.Name, trail.In(), trail.Out(), theory, e)
+			}
+		}
+	}
+
+	// the more rounds, the worse
+	c1 := math.Abs(Salsa.Search(1, 1)[0].Correlation())
+	c2 := math.Abs(Salsa.Search(2, 1)[0].Correlation())
+	if c2 >= c1 || c2 == 0 {
+		t.Errorf("Search: correlation %v after 1 round, %v after 2", c1, c2)
+	}
+}
+
+func TestTheoretical(t *testing.T) {
+	out, _ := ParseMask("0:0")
+	trail := ChaCha.Back(out, 1)
+
+	if c, ok := ChaCha.Theoretical(trail.In(), out, 1); !ok || c != trail.Correlation() {
+		t.Errorf("Theoretical: %v, %v for the trail's own masks", c, ok)
+	}
+	if _, ok := ChaCha.Theoretical(out, out, 1); ok {
+		t.Error("Theoretical: expected no estimate off the trail")
+	}
+
+	// the trail forwards from in, which Back doesn't find
+	in, _ := ParseMask("8:7")
+	forward, ok := ChaCha.Forward(in, 2)
+	if !ok {
+		t.Fatal("Forward: no trail")
+	}
+	if back := ChaCha.Back(forward.Out(), 2); back.In() == in {
+		t.Fatalf("Back: found the trail from %s as well", in)
+	}
+	if c, ok := ChaCha.Theoretical(in, forward.Out(), 2); !ok || c != forward.Correlation() {
+		t.Errorf("Theoretical: %v, %v for the masks of the forward trail", c, ok)
+	}
+}
+
+// Forward trails from single input bits match the measured correlation.
+func TestForward(t *testing.T) {
+	for _, c := range []Cipher{ChaCha, Salsa} {
+		for _, bit := range []string{"0:0", "3:5", "12:0", "15:31"} {
+			in, _ := ParseMask(bit)
+
+			trail, ok := c.Forward(in, 1)
+			if !ok {
+				t.Errorf("%s: no trail forwards from %s", c.Design.Name, in)
+				continue
+			}
+			if trail.In() != in || len(trail.Masks) != 2 {
+				t.Errorf("%s: trail %v from %s", c.Design.Name, trail.Masks, in)
+			}
+
+			theory := trail.Correlation()
+			if e, _ := c.Correlation(in, trail.Out(), 1, 1<<14, 1); theory == 0 || math.Abs(e-theory) > 0.05 {
+				t.Errorf("%s: trail %s -> %s: estimated %v, measured %v", c.Design.Name, in, trail.Out(), theory, e)
+			}
+		}
+	}
+}
+
+func TestCorrelationSamples(t *testing.T) {
+	in, _ := ParseMask("0:0")
+	for _, samples := range []int{0, -1} {
+		if c, err := ChaCha.Correlation(in, in, 1, samples, 1); err == nil {
+			t.Errorf("Correlation with %d samples: %v, expected an error", samples, c)
+		}
+	}
+}
+
+func TestParseMask(t *testing.T) {
+	m, err := ParseMask("0:0, 12:7,0:31")
+	if err != nil || m != (Mask{0: 0x80000001, 12: 0x80}) {
+		t.Errorf("ParseMask: %v, %v", m, err)
+	}
+	if m.String() != "[0]_0+[0]_31+[12]_7" || m.Weight() != 3 {
+		t.Errorf("Mask: %s with weight %d", m, m.Weight())
+	}
+
+	for _, bad := range []string{"", "0", "16:0", "0:32", "a:b"} {
+		if _, err := ParseMask(bad); err == nil {
+			t.Errorf("ParseMask(%q): expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package nist
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+)
+
+// Keystream returns size bytes of c's keystream with the given number of
+// rounds, running the block function on counter, counter+1 and so on. With the
+// full rounds it is the same keystream chacha.Encrypt and salsa.Encrypt XOR
+// with the message.
+func Keystream(c analysis.Cipher, key, nonce []byte, counter uint64, rounds, size int) []byte {
+	out := make([]byte, 0, size+c.OutputSize)
+	ctr := make([]byte, 8)
+
+	for len(out) < size {
+		binary.LittleEndian.PutUint64(ctr, counter)
+		out = append(out, c.Block(key, nonce, ctr[:c.CounterSize], rounds)...)
+		counter++
+	}
+
+	return out[:size]
+}
+
+// RandomKeystream is Keystream with a key and nonce drawn from seed, starting
+// at counter 0.
+func RandomKeystream(c analysis.Cipher, rounds, size int, seed uint64) []byte {
+	rng := rand.New(rand.NewPCG(seed, 0))
+
+	input := make([]byte, c.KeySize+c.NonceSize)
+	for i := range input {
+		input[i] = byte(rng.Uint32())
+	}
+
+	return Keystream(c, input[:c.KeySize], input[c.KeySize:], 0, rounds, size)
+}
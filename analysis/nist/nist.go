@@ -0,0 +1,304 @@
+package nist
+
+import (
+	"fmt"
+	"math"
+)
+
+// Tests from NIST SP 800-22 rev 1a: https://csrc.nist.gov/pubs/sp/800/22/r1/upd1/final
+// Every test takes the sequence as one bit per byte (0 or 1).
+
+// Alpha is the significance level, a p-value below it fails the test.
+const Alpha = 0.01
+
+// Result of one test. Some tests have more than one p-value, they pass only if
+// all of them are at least Alpha.
+type Result struct {
+	Name    string
+	PValues []float64
+}
+
+// Pass reports whether every p-value is at least Alpha.
+func (r Result) Pass() bool {
+	for _, p := range r.PValues {
+		if p < Alpha {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Result) String() string {
+	status := "pass"
+	if !r.Pass() {
+		status = "FAIL"
+	}
+	return fmt.Sprintf("%-26s %v %s", r.Name, r.PValues, status)
+}
+
+// Bits expands bytes into bits, most significant bit first.
+func Bits(data []byte) []uint8 {
+	bits := make([]uint8, 0, len(data)*8)
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, (b>>i)&1)
+		}
+	}
+	return bits
+}
+
+// Frequency (monobit) test, section 2.1: are there as many ones as zeros?
+func Frequency(bits []uint8) Result {
+	s := 0
+	for _, b := range bits {
+		s += 2*int(b) - 1
+	}
+
+	obs := math.Abs(float64(s)) / math.Sqrt(float64(len(bits)))
+
+	return Result{Name: "frequency", PValues: []float64{math.Erfc(obs / math.Sqrt2)}}
+}
+
+// BlockFrequency test, section 2.2: is the proportion of ones in every block
+// of m bits about 1/2?
+func BlockFrequency(bits []uint8, m int) Result {
+	n := len(bits) / m
+
+	chi := 0.0
+	for i := 0; i < n; i++ {
+		ones := 0
+		for _, b := range bits[i*m : (i+1)*m] {
+			ones += int(b)
+		}
+		pi := float64(ones)/float64(m) - 0.5
+		chi += pi * pi
+	}
+	chi *= 4 * float64(m)
+
+	return Result{Name: "block frequency", PValues: []float64{igamc(float64(n)/2, chi/2)}}
+}
+
+// Runs test, section 2.3: do runs of ones and zeros switch as often as they
+// would at random?
+func Runs(bits []uint8) Result {
+	n := float64(len(bits))
+
+	ones := 0
+	for _, b := range bits {
+		ones += int(b)
+	}
+	pi := float64(ones) / n
+
+	// the frequency prerequisite failed, don't even bother
+	if math.Abs(pi-0.5) >= 2/math.Sqrt(n) {
+		return Result{Name: "runs", PValues: []float64{0}}
+	}
+
+	v := 1
+	for i := 1; i < len(bits); i++ {
+		if bits[i] != bits[i-1] {
+			v++
+		}
+	}
+
+	num := math.Abs(float64(v) - 2*n*pi*(1-pi))
+	den := 2 * math.Sqrt(2*n) * pi * (1 - pi)
+
+	return Result{Name: "runs", PValues: []float64{math.Erfc(num / den)}}
+}
+
+// LongestRun of ones in a block, section 2.4. The block size and the expected
+// distribution depend on the length of the sequence, which must have at least
+// 128 bits.
+func LongestRun(bits []uint8) Result {
+	var m int
+	var low int
+	var pi []float64
+
+	switch n := len(bits); {
+	case n >= 750000:
+		m, low = 10000, 10
+		pi = []float64{0.0882, 0.2092, 0.2483, 0.1933, 0.1208, 0.0675, 0.0727}
+	case n >= 6272:
+		m, low = 128, 4
+		pi = []float64{0.1174, 0.2430, 0.2493, 0.1752, 0.1027, 0.1124}
+	case n >= 128:
+		m, low = 8, 1
+		pi = []float64{0.2148, 0.3672, 0.2305, 0.1875}
+	default:
+		return Result{Name: "longest run", PValues: []float64{0}}
+	}
+
+	blocks := len(bits) / m
+	v := make([]int, len(pi))
+
+	for i := 0; i < blocks; i++ {
+		longest, run := 0, 0
+		for _, b := range bits[i*m : (i+1)*m] {
+			if b == 1 {
+				run++
+				longest = max(longest, run)
+			} else {
+				run = 0
+			}
+		}
+
+		class := min(max(longest-low, 0), len(pi)-1)
+		v[class]++
+	}
+
+	chi := 0.0
+	for i := range v {
+		expected := float64(blocks) * pi[i]
+		chi += (float64(v[i]) - expected) * (float64(v[i]) - expected) / expected
+	}
+
+	k := float64(len(pi) - 1)
+
+	return Result{Name: "longest run", PValues: []float64{igamc(k/2, chi/2)}}
+}
+
+// patterns counts every overlapping m bit pattern, the sequence wraps around
+// at the end.
+func patterns(bits []uint8, m int) []int {
+	counts := make([]int, 1<<m)
+	if m == 0 {
+		return counts
+	}
+
+	n := len(bits)
+	for i := 0; i < n; i++ {
+		p := 0
+		for j := 0; j < m; j++ {
+			p = p<<1 | int(bits[(i+j)%n])
+		}
+		counts[p]++
+	}
+
+	return counts
+}
+
+func psi2(bits []uint8, m int) float64 {
+	if m <= 0 {
+		return 0
+	}
+
+	n := float64(len(bits))
+	sum := 0.0
+	for _, c := range patterns(bits, m) {
+		sum += float64(c) * float64(c)
+	}
+
+	return sum*math.Pow(2, float64(m))/n - n
+}
+
+// Serial test, section 2.11: do all 2^m overlapping patterns of m bits appear
+// about as often?
+func Serial(bits []uint8, m int) Result {
+	p0 := psi2(bits, m)
+	p1 := psi2(bits, m-1)
+	p2 := psi2(bits, m-2)
+
+	del1 := p0 - p1
+	del2 := p0 - 2*p1 + p2
+
+	return Result{Name: "serial", PValues: []float64{
+		igamc(math.Pow(2, float64(m-2)), del1/2),
+		igamc(math.Pow(2, float64(m-3)), del2/2),
+	}}
+}
+
+func phi(bits []uint8, m int) float64 {
+	n := float64(len(bits))
+	sum := 0.0
+	for _, c := range patterns(bits, m) {
+		if c > 0 {
+			p := float64(c) / n
+			sum += p * math.Log(p)
+		}
+	}
+	return sum
+}
+
+// ApproximateEntropy test, section 2.12: compares how often overlapping
+// patterns of m and m+1 bits appear.
+func ApproximateEntropy(bits []uint8, m int) Result {
+	n := float64(len(bits))
+
+	apen := phi(bits, m) - phi(bits, m+1)
+	chi := 2 * n * (math.Log(2) - apen)
+
+	return Result{Name: "approximate entropy", PValues: []float64{igamc(math.Pow(2, float64(m-1)), chi/2)}}
+}
+
+// CumulativeSums test, section 2.13: does the random walk of the sequence
+// (ones are +1, zeros are -1) stray too far from zero? It is run forwards
+// and backwards.
+func CumulativeSums(bits []uint8) Result {
+	return Result{Name: "cumulative sums", PValues: []float64{cusum(bits, false), cusum(bits, true)}}
+}
+
+func cusum(bits []uint8, backwards bool) float64 {
+	n := len(bits)
+
+	s, z := 0, 0
+	for i := 0; i < n; i++ {
+		b := bits[i]
+		if backwards {
+			b = bits[n-1-i]
+		}
+
+		s += 2*int(b) - 1
+		if s > z {
+			z = s
+		} else if -s > z {
+			z = -s
+		}
+	}
+
+	if z == 0 {
+		return 0
+	}
+
+	fn := float64(n)
+	fz := float64(z)
+	sqrtN := math.Sqrt(fn)
+
+	sum1 := 0.0
+	for k := int(math.Floor((-fn/fz + 1) / 4)); k <= int(math.Floor((fn/fz-1)/4)); k++ {
+		sum1 += normal(float64(4*k+1)*fz/sqrtN) - normal(float64(4*k-1)*fz/sqrtN)
+	}
+
+	sum2 := 0.0
+	for k := int(math.Floor((-fn/fz - 3) / 4)); k <= int(math.Floor((fn/fz-1)/4)); k++ {
+		sum2 += normal(float64(4*k+3)*fz/sqrtN) - normal(float64(4*k+1)*fz/sqrtN)
+	}
+
+	return 1 - sum1 + sum2
+}
+
+// Config of the tests that take parameters.
+type Config struct {
+	// BlockSize of the block frequency test.
+	BlockSize int
+	// SerialM is the pattern length of the serial test.
+	SerialM int
+	// EntropyM is the pattern length of the approximate entropy test.
+	EntropyM int
+}
+
+// DefaultConfig works for sequences of about a million bits.
+var DefaultConfig = Config{BlockSize: 128, SerialM: 16, EntropyM: 10}
+
+// Suite runs every test on bits.
+func Suite(bits []uint8, c Config) []Result {
+	return []Result{
+		Frequency(bits),
+		BlockFrequency(bits, c.BlockSize),
+		Runs(bits),
+		LongestRun(bits),
+		Serial(bits, c.SerialM),
+		ApproximateEntropy(bits, c.EntropyM),
+		CumulativeSums(bits),
+	}
+}
@@ -0,0 +1,107 @@
+package nist
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+func parseBits(s string) []uint8 {
+	bits := make([]uint8, len(s))
+	for i := range s {
+		bits[i] = s[i] - '0'
+	}
+	return bits
+}
+
+func checkP(t *testing.T, r Result, expected ...float64) {
+	t.Helper()
+
+	if len(r.PValues) != len(expected) {
+		t.Fatalf("%s: got %d p-values, want %d", r.Name, len(r.PValues), len(expected))
+	}
+
+	for i, p := range r.PValues {
+		if math.Abs(p-expected[i]) > 1e-6 {
+			t.Errorf("%s: p-value %d = %f, want %f", r.Name, i, p, expected[i])
+		}
+	}
+}
+
+// The examples worked out in SP 800-22 for every test.
+func TestExamples(t *testing.T) {
+	checkP(t, Frequency(parseBits("1011010101")), 0.527089)
+	checkP(t, BlockFrequency(parseBits("0110011010"), 3), 0.801252)
+	checkP(t, Runs(parseBits("1001101011")), 0.147232)
+	checkP(t, LongestRun(parseBits(
+		"11001100000101010110110001001100111000000000001001001101010100010001001111010110100000001101011111001100111001101101100010110010")),
+		0.180598)
+	checkP(t, Serial(parseBits("0011011101"), 3), 0.808792, 0.670320)
+	checkP(t, ApproximateEntropy(parseBits("0100110101"), 3), 0.261961)
+	// the document rounds the normal distribution, hence 0.4116588
+	checkP(t, CumulativeSums(parseBits("1011010111")), 0.411585, 0.411585)
+}
+
+func TestBits(t *testing.T) {
+	expected := parseBits("1000000000001111")
+	if got := Bits([]byte{0x80, 0x0f}); !bytes.Equal(got, expected) {
+		t.Errorf("Bits() = %v, want %v", got, expected)
+	}
+}
+
+func TestKeystream(t *testing.T) {
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	zeros := make([]byte, 300)
+
+	chachaNonce := [12]byte{0, 0, 0, 9, 0, 0, 0, 0x4a}
+	expected := chacha.Encrypt(key, chachaNonce, zeros)
+	// chacha.Encrypt starts at counter 1, counter 0 is for the Poly1305 key
+	if got := Keystream(analysis.ChaCha, key[:], chachaNonce[:], 1, 20, len(zeros)); !bytes.Equal(got, expected) {
+		t.Errorf("chacha keystream = %x, want %x", got, expected)
+	}
+
+	salsaNonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	expected = salsa.Encrypt(&key, salsaNonce, zeros)
+	if got := Keystream(analysis.Salsa, key[:], salsaNonce, 0, 20, len(zeros)); !bytes.Equal(got, expected) {
+		t.Errorf("salsa keystream = %x, want %x", got, expected)
+	}
+}
+
+// A million bits of the full ciphers pass every test, for this seed at least:
+// each p-value of a truly random sequence fails with probability Alpha.
+func TestFullRounds(t *testing.T) {
+	for _, c := range []analysis.Cipher{analysis.ChaCha, analysis.Salsa} {
+		bits := Bits(RandomKeystream(c, 20, 125000, 2))
+		for _, r := range Suite(bits, DefaultConfig) {
+			if !r.Pass() {
+				t.Errorf("%s: %s", c.Name, r)
+			}
+		}
+	}
+}
+
+// With a single round consecutive blocks differ only in a few bits, which the
+// pattern tests notice.
+func TestReducedRounds(t *testing.T) {
+	for _, c := range []analysis.Cipher{analysis.ChaCha, analysis.Salsa} {
+		bits := Bits(RandomKeystream(c, 1, 125000, 1))
+
+		failed := 0
+		for _, r := range Suite(bits, DefaultConfig) {
+			if !r.Pass() {
+				failed++
+			}
+		}
+
+		if failed == 0 {
+			t.Errorf("%s with 1 round passed every test", c.Name)
+		}
+	}
+}
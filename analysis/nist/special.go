@@ -0,0 +1,71 @@
+package nist
+
+import "math"
+
+// igamc is the complemented incomplete gamma function Q(a, x), as used by
+// every chi-squared test in SP 800-22. Numerical Recipes, section 6.2.
+func igamc(a, x float64) float64 {
+	if x <= 0 || a <= 0 {
+		return 1
+	}
+
+	if x < a+1 {
+		return 1 - igamSeries(a, x)
+	}
+
+	return igamcFraction(a, x)
+}
+
+func igamSeries(a, x float64) float64 {
+	lg, _ := math.Lgamma(a)
+
+	ap := a
+	sum := 1 / a
+	del := sum
+	for i := 0; i < 1000; i++ {
+		ap++
+		del *= x / ap
+		sum += del
+		if math.Abs(del) < math.Abs(sum)*1e-15 {
+			break
+		}
+	}
+
+	return sum * math.Exp(-x+a*math.Log(x)-lg)
+}
+
+func igamcFraction(a, x float64) float64 {
+	const tiny = 1e-300
+	lg, _ := math.Lgamma(a)
+
+	// modified Lentz's method
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+
+	return math.Exp(-x+a*math.Log(x)-lg) * h
+}
+
+// normal is the standard normal cumulative distribution function.
+func normal(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
@@ -0,0 +1,255 @@
+// Package arx builds Salsa-like stream ciphers out of their parts: the
+// constants, where the key, nonce and counter go in the 4x4 state of 32 bit
+// words, the quarter round, and which words each round mixes. ChaCha20
+// (chacha.Design()) and Salsa20 (salsa.Design()) are both designs; a new one only
+// needs its parts, the block function, keystream and permutation come from
+// here. Package arxtest checks a design behaves.
+package arx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/trace"
+)
+
+// QuarterRound mixes four words.
+type QuarterRound func(a, b, c, d uint32) (uint32, uint32, uint32, uint32)
+
+// Round runs the quarter round on four groups of words, in the order the
+// quarter round takes them. Kind is what the round is called in traces.
+type Round struct {
+	Quarters [4][4]int
+	Kind     trace.Kind
+}
+
+// The round schedules of ChaCha and Salsa.
+var (
+	// ColumnDiagonal mixes the columns, then the diagonals.
+	ColumnDiagonal = []Round{
+		{[4][4]int{{0, 4, 8, 12}, {1, 5, 9, 13}, {2, 6, 10, 14}, {3, 7, 11, 15}}, trace.ColumnRound},
+		{[4][4]int{{0, 5, 10, 15}, {1, 6, 11, 12}, {2, 7, 8, 13}, {3, 4, 9, 14}}, trace.DiagonalRound},
+	}
+
+	// ColumnRow mixes the columns, then the rows. Each quarter round starts
+	// at the diagonal word of its column or row.
+	ColumnRow = []Round{
+		{[4][4]int{{0, 4, 8, 12}, {5, 9, 13, 1}, {10, 14, 2, 6}, {15, 3, 7, 11}}, trace.ColumnRound},
+		{[4][4]int{{0, 1, 2, 3}, {5, 6, 7, 4}, {10, 11, 8, 9}, {15, 12, 13, 14}}, trace.RowRound},
+	}
+)
+
+// Design of a cipher. Words are little endian, the key is always 32 bytes,
+// the nonce 4 bytes per nonce word and the counter 32 bits per counter word,
+// low word first.
+type Design struct {
+	Name string
+
+	Constants     [4]uint32
+	ConstantWords [4]int
+	KeyWords      [8]int
+	NonceWords    []int
+	CounterWords  []int
+
+	QuarterRound QuarterRound
+	// InverseQuarterRound is optional, without it the design can't Inverse.
+	InverseQuarterRound QuarterRound
+
+	// Schedule is repeated round after round: with two rounds in it odd
+	// round counts end with the first one.
+	Schedule []Round
+	Rounds   int
+}
+
+// Clone returns a copy of d that shares nothing with it, so changing the
+// copy's schedule or layout leaves d alone.
+func (d *Design) Clone() *Design {
+	c := *d
+	c.NonceWords = append([]int(nil), d.NonceWords...)
+	c.CounterWords = append([]int(nil), d.CounterWords...)
+	c.Schedule = append([]Round(nil), d.Schedule...)
+	return &c
+}
+
+// Validate checks the state layout uses every word exactly once and the
+// design has a quarter round and a schedule.
+func (d *Design) Validate() error {
+	if d.QuarterRound == nil || len(d.Schedule) == 0 || d.Rounds < 1 {
+		return fmt.Errorf("arx: %s needs a quarter round, a schedule and rounds", d.Name)
+	}
+
+	var uses [16]int
+	words := append(append(append(d.ConstantWords[:], d.KeyWords[:]...), d.NonceWords...), d.CounterWords...)
+	for _, w := range words {
+		if w < 0 || w > 15 {
+			return fmt.Errorf("arx: %s places something in word %d", d.Name, w)
+		}
+		uses[w]++
+	}
+
+	for w, n := range uses {
+		if n != 1 {
+			return fmt.Errorf("arx: %s uses word %d %d times", d.Name, w, n)
+		}
+	}
+
+	for i, r := range d.Schedule {
+		var mixed [16]bool
+		for _, q := range r.Quarters {
+			for _, w := range q {
+				mixed[w] = true
+			}
+		}
+		for w, ok := range mixed {
+			if !ok {
+				return fmt.Errorf("arx: %s round %d doesn't mix word %d", d.Name, i, w)
+			}
+		}
+	}
+
+	return nil
+}
+
+// NonceSize in bytes.
+func (d *Design) NonceSize() int {
+	return 4 * len(d.NonceWords)
+}
+
+// CounterSize in bytes.
+func (d *Design) CounterSize() int {
+	return 4 * len(d.CounterWords)
+}
+
+// State lays out the constants, key, nonce and counter.
+func (d *Design) State(key, nonce []byte, counter uint64) [16]uint32 {
+	if len(key) != 32 || len(nonce) != d.NonceSize() {
+		panic(fmt.Sprintf("arx: %s takes a 32 byte key and a %d byte nonce", d.Name, d.NonceSize()))
+	}
+
+	var state [16]uint32
+	for i, w := range d.ConstantWords {
+		state[w] = d.Constants[i]
+	}
+	for i, w := range d.KeyWords {
+		state[w] = binary.LittleEndian.Uint32(key[i*4:])
+	}
+	for i, w := range d.NonceWords {
+		state[w] = binary.LittleEndian.Uint32(nonce[i*4:])
+	}
+	for _, w := range d.CounterWords {
+		state[w] = uint32(counter)
+		counter >>= 32
+	}
+
+	return state
+}
+
+// Round applies round i (counting from 0) of the schedule to state.
+func (d *Design) Round(state *[16]uint32, i int) {
+	for _, q := range d.Schedule[i%len(d.Schedule)].Quarters {
+		state[q[0]], state[q[1]], state[q[2]], state[q[3]] = d.QuarterRound(state[q[0]], state[q[1]], state[q[2]], state[q[3]])
+	}
+}
+
+// InverseRound undoes Round(state, i).
+func (d *Design) InverseRound(state *[16]uint32, i int) {
+	for _, q := range d.Schedule[i%len(d.Schedule)].Quarters {
+		state[q[0]], state[q[1]], state[q[2]], state[q[3]] = d.InverseQuarterRound(state[q[0]], state[q[1]], state[q[2]], state[q[3]])
+	}
+}
+
+// Permute applies rounds rounds to state, without the feed-forward.
+func (d *Design) Permute(state *[16]uint32, rounds int) {
+	for i := 0; i < rounds; i++ {
+		d.Round(state, i)
+	}
+}
+
+// Inverse undoes rounds of Permute: it takes the state after from rounds back
+// to the state after to rounds.
+func (d *Design) Inverse(state *[16]uint32, from, to int) {
+	for i := from - 1; i >= to; i-- {
+		d.InverseRound(state, i)
+	}
+}
+
+// Hash applies rounds rounds to state and adds state back, the feed-forward.
+// obs, if not nil, sees the state after every quarter round, every round
+// and the feed-forward.
+func (d *Design) Hash(state [16]uint32, rounds int, obs trace.Observer) [16]uint32 {
+	z := state
+	emit(obs, trace.Step{Kind: trace.Initial}, &z)
+
+	for i := 0; i < rounds; i++ {
+		if obs == nil {
+			d.Round(&z, i)
+			continue
+		}
+
+		r := d.Schedule[i%len(d.Schedule)]
+		for j, q := range r.Quarters {
+			z[q[0]], z[q[1]], z[q[2]], z[q[3]] = d.QuarterRound(z[q[0]], z[q[1]], z[q[2]], z[q[3]])
+			emit(obs, trace.Step{Kind: trace.QuarterRound, Round: i + 1, Quarter: j + 1, Words: q}, &z)
+		}
+		emit(obs, trace.Step{Kind: r.Kind, Round: i + 1}, &z)
+	}
+
+	for i := range z {
+		z[i] += state[i]
+	}
+	emit(obs, trace.Step{Kind: trace.FeedForward}, &z)
+
+	return z
+}
+
+func emit(obs trace.Observer, step trace.Step, state *[16]uint32) {
+	if obs == nil {
+		return
+	}
+	step.State = *state
+	obs(step)
+}
+
+// Bytes serializes words, little endian.
+func Bytes(words [16]uint32) []byte {
+	b := make([]byte, 64)
+	for i, w := range words {
+		binary.LittleEndian.PutUint32(b[i*4:], w)
+	}
+	return b
+}
+
+// BlockRounds returns the 64 byte keystream block for key, nonce and counter
+// with rounds rounds.
+func (d *Design) BlockRounds(key, nonce []byte, counter uint64, rounds int) []byte {
+	return Bytes(d.Hash(d.State(key, nonce, counter), rounds, nil))
+}
+
+// Block is BlockRounds with the rounds of the design.
+func (d *Design) Block(key, nonce []byte, counter uint64) []byte {
+	return d.BlockRounds(key, nonce, counter, d.Rounds)
+}
+
+// ErrCounter means the message needs more blocks than the counter can count.
+var ErrCounter = errors.New("arx: counter overflow")
+
+// XORKeyStream encrypts, or decrypts, message with the keystream starting at
+// block counter.
+func (d *Design) XORKeyStream(key, nonce []byte, counter uint64, message []byte) ([]byte, error) {
+	blocks := uint64(len(message)+63) / 64
+	if bits := 32 * len(d.CounterWords); bits < 64 && (counter+blocks-1)>>bits != 0 && blocks > 0 {
+		return nil, ErrCounter
+	}
+
+	out := make([]byte, len(message))
+	for i := 0; i < len(message); i += 64 {
+		stream := d.Block(key, nonce, counter)
+		for j := 0; j < 64 && i+j < len(message); j++ {
+			out[i+j] = message[i+j] ^ stream[j]
+		}
+		counter++
+	}
+
+	return out, nil
+}
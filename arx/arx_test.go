@@ -0,0 +1,86 @@
+package arx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func identity(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	return a, b, c, d
+}
+
+func design() Design {
+	return Design{
+		Name:          "test",
+		ConstantWords: [4]int{0, 1, 2, 3},
+		KeyWords:      [8]int{4, 5, 6, 7, 8, 9, 10, 11},
+		NonceWords:    []int{13, 14, 15},
+		CounterWords:  []int{12},
+		QuarterRound:  identity,
+		Schedule:      ColumnDiagonal,
+		Rounds:        20,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := design()
+	if err := d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := design()
+	empty.Schedule = nil
+
+	outside := design()
+	outside.NonceWords = []int{13, 14, 16}
+
+	twice := design()
+	twice.CounterWords = []int{11}
+
+	unmixed := design()
+	unmixed.Schedule = []Round{{Quarters: [4][4]int{{0, 4, 8, 12}, {1, 5, 9, 13}, {2, 6, 10, 14}, {3, 7, 11, 11}}}}
+
+	for _, tt := range []struct {
+		design Design
+		err    string
+	}{
+		{empty, "needs a quarter round"},
+		{outside, "word 16"},
+		{twice, "uses word 11 2 times"},
+		{unmixed, "doesn't mix word 15"},
+	} {
+		err := tt.design.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Validate: expected %q, got %v", tt.err, err)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	d := design()
+	d.Constants = [4]uint32{1, 2, 3, 4}
+
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	state := d.State(key, []byte{1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0}, 7)
+
+	expected := [16]uint32{1, 2, 3, 4, 0x03020100, 0x07060504, 0x0b0a0908, 0x0f0e0d0c, 0x13121110, 0x17161514, 0x1b1a1918, 0x1f1e1d1c, 7, 1, 2, 3}
+	if state != expected {
+		t.Errorf("State: expected %08x, got %08x", expected, state)
+	}
+}
+
+func TestXORKeyStreamCounter(t *testing.T) {
+	d := design()
+	key, nonce := make([]byte, 32), make([]byte, 12)
+
+	if _, err := d.XORKeyStream(key, nonce, 0xffffffff, make([]byte, 64)); err != nil {
+		t.Errorf("last block: %v", err)
+	}
+	if _, err := d.XORKeyStream(key, nonce, 0xffffffff, make([]byte, 65)); !errors.Is(err, ErrCounter) {
+		t.Errorf("past the last block: expected ErrCounter, got %v", err)
+	}
+}
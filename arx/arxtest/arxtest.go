@@ -0,0 +1,142 @@
+// Package arxtest checks an arx design: a valid layout, a permutation that
+// inverts, a keystream that decrypts what it encrypts, and full rounds that
+// look random to the avalanche and NIST tools.
+package arxtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/analysis/avalanche"
+	"github.com/mario-areias/latin-dances-go/analysis/nist"
+	"github.com/mario-areias/latin-dances-go/arx"
+)
+
+// MinSAC is the strict avalanche criterion score the full rounds must reach.
+// 128 samples of a perfect function score about 0.93.
+const MinSAC = 0.9
+
+// Check runs every check on d and returns the first failure.
+func Check(d *arx.Design) error {
+	for _, check := range []func(*arx.Design) error{CheckLayout, CheckInverse, CheckKeystream, CheckDiffusion, CheckRandomness} {
+		if err := check(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckLayout is d.Validate.
+func CheckLayout(d *arx.Design) error {
+	return d.Validate()
+}
+
+func randomBytes(rng *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+	return b
+}
+
+// CheckInverse checks Inverse undoes Permute, if d has an inverse quarter
+// round.
+func CheckInverse(d *arx.Design) error {
+	if d.InverseQuarterRound == nil {
+		return nil
+	}
+
+	rng := rand.New(rand.NewPCG(1, 0))
+	for i := 0; i < 16; i++ {
+		var initial [16]uint32
+		for j := range initial {
+			initial[j] = rng.Uint32()
+		}
+
+		state := initial
+		d.Permute(&state, d.Rounds)
+		d.Inverse(&state, d.Rounds, 0)
+
+		if state != initial {
+			return fmt.Errorf("arxtest: %s: Inverse doesn't undo Permute", d.Name)
+		}
+	}
+
+	return nil
+}
+
+// CheckKeystream checks XORKeyStream decrypts what it encrypts, and that a
+// different key, nonce or counter gives a different block.
+func CheckKeystream(d *arx.Design) error {
+	rng := rand.New(rand.NewPCG(2, 0))
+	key, nonce := randomBytes(rng, 32), randomBytes(rng, d.NonceSize())
+	message := randomBytes(rng, 1000)
+
+	cipher, err := d.XORKeyStream(key, nonce, 1, message)
+	if err != nil {
+		return err
+	}
+	plain, err := d.XORKeyStream(key, nonce, 1, cipher)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plain, message) {
+		return fmt.Errorf("arxtest: %s: decrypting doesn't give the message back", d.Name)
+	}
+
+	block := d.Block(key, nonce, 1)
+	otherKey := bytes.Clone(key)
+	otherKey[31] ^= 0x80
+
+	others := map[string][]byte{
+		"counter": d.Block(key, nonce, 2),
+		"key":     d.Block(otherKey, nonce, 1),
+	}
+	if len(nonce) > 0 {
+		otherNonce := bytes.Clone(nonce)
+		otherNonce[0] ^= 1
+		others["nonce"] = d.Block(key, otherNonce, 1)
+	}
+
+	for input, other := range others {
+		if bytes.Equal(block, other) {
+			return fmt.Errorf("arxtest: %s: another %s gives the same block", d.Name, input)
+		}
+	}
+
+	return nil
+}
+
+// CheckDiffusion checks every input bit of the full rounds changes every
+// output bit about half of the time.
+func CheckDiffusion(d *arx.Design) error {
+	r, err := avalanche.Analyze(analysis.FromDesign(d), analysis.All, d.Rounds, 128, 3)
+	if err != nil {
+		return err
+	}
+
+	if sac := r.SAC(); sac < MinSAC {
+		return fmt.Errorf("arxtest: %s: SAC %.3f after %d rounds, expected at least %.2f", d.Name, sac, d.Rounds, MinSAC)
+	}
+
+	return nil
+}
+
+// CheckRandomness runs the NIST tests on two keystreams of 125000 bytes. At
+// a 1% significance level a good cipher fails one test or another every few
+// keystreams, so a test only counts as failed if it fails on both.
+func CheckRandomness(d *arx.Design) error {
+	c := analysis.FromDesign(d)
+	first := nist.Suite(nist.Bits(nist.RandomKeystream(c, d.Rounds, 125000, 4)), nist.DefaultConfig)
+	second := nist.Suite(nist.Bits(nist.RandomKeystream(c, d.Rounds, 125000, 5)), nist.DefaultConfig)
+
+	for i, r := range first {
+		if !r.Pass() && !second[i].Pass() {
+			return fmt.Errorf("arxtest: %s: keystream fails %s", d.Name, r)
+		}
+	}
+
+	return nil
+}
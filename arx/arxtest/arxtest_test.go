@@ -0,0 +1,86 @@
+package arxtest
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+func TestCheck(t *testing.T) {
+	for _, d := range []arx.Design{chacha.Design(), salsa.Design()} {
+		if err := Check(&d); err != nil {
+			t.Errorf("%s: %s", d.Name, err)
+		}
+	}
+}
+
+// weak is ChaCha with a quarter round that only adds, so nothing ever moves
+// to a lower bit.
+func weak() *arx.Design {
+	d := chacha.Design()
+	d.Name = "weak"
+	d.QuarterRound = func(a, b, c, dd uint32) (uint32, uint32, uint32, uint32) {
+		a += b
+		dd += a
+		c += dd
+		b += c
+		return a, b, c, dd
+	}
+	d.InverseQuarterRound = nil
+	return &d
+}
+
+func TestCheckFailures(t *testing.T) {
+	badLayout := chacha.Design()
+	badLayout.NonceWords = []int{12, 14, 15}
+
+	noInverse := chacha.Design()
+	noInverse.InverseQuarterRound = func(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+		return a, b, c, d
+	}
+
+	lazy := chacha.Design()
+	lazy.Rounds = 1
+
+	for _, tt := range []struct {
+		design *arx.Design
+		err    string
+	}{
+		{&badLayout, "uses word 12 2 times"},
+		{&noInverse, "Inverse doesn't undo Permute"},
+		{weak(), "SAC"},
+		{&lazy, "SAC"},
+	} {
+		err := Check(tt.design)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Check: expected %q, got %v", tt.err, err)
+		}
+	}
+}
+
+// A design of our own: Salsa's layout and schedule with the ChaCha quarter
+// round.
+func TestCustomDesign(t *testing.T) {
+	d := salsa.Design()
+	d.Name = "chasalsa"
+	d.QuarterRound = func(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+		a += b
+		d = bits.RotateLeft32(d^a, 16)
+		c += d
+		b = bits.RotateLeft32(b^c, 12)
+		a += b
+		d = bits.RotateLeft32(d^a, 8)
+		c += d
+		b = bits.RotateLeft32(b^c, 7)
+		return a, b, c, d
+	}
+	d.InverseQuarterRound = nil
+
+	if err := Check(&d); err != nil {
+		t.Error(err)
+	}
+}
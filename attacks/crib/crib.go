@@ -0,0 +1,224 @@
+package crib
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// A stream cipher encrypts by XORing the message with a keystream that only
+// depends on the key, the nonce and the counter. Encrypting two messages with
+// the same key and nonce, like calling chacha.Encrypt or salsa.Encrypt twice,
+// uses the same keystream for both:
+//
+//	c1 ⊕ c2 = (p1 ⊕ k) ⊕ (p2 ⊕ k) = p1 ⊕ p2
+//
+// The keystream cancels out and what is left is the XOR of two texts, which
+// is far from random. Guessing a word that is likely in one of them, a crib,
+// at some position gives the keystream there, and with it the other texts at
+// the same position: if they read like English the guess was probably right.
+// Dragging the crib across every position of every ciphertext and keeping
+// the positions that score best, then growing the texts around them, recovers
+// all the plaintexts without ever knowing the key.
+
+// ErrPosition means a text was placed outside its ciphertext.
+var ErrPosition = errors.New("crib: text outside the ciphertext")
+
+// XOR returns a ⊕ b, as long as the shorter of the two.
+func XOR(a, b []byte) []byte {
+	out := make([]byte, min(len(a), len(b)))
+	for i := range out {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// english has the log probability of each byte in English text: letter
+// frequencies from large corpora, a space about every 6 characters, a little
+// room for capitals, digits and punctuation and next to none for anything else.
+var english = func() [256]float64 {
+	letters := [26]float64{
+		8.2, 1.5, 2.8, 4.3, 12.7, 2.2, 2.0, 6.1, 7.0, 0.15, 0.77, 4.0, 2.4,
+		6.7, 7.5, 1.9, 0.095, 6.0, 6.3, 9.1, 2.8, 0.98, 2.4, 0.15, 2.0, 0.074,
+	}
+
+	var p [256]float64
+	for i := range p {
+		p[i] = 1e-6
+	}
+	for i, f := range letters {
+		p['a'+i] = 0.75 * f / 100
+		p['A'+i] = 0.03 * f / 100
+	}
+	for c := '0'; c <= '9'; c++ {
+		p[c] = 0.001
+	}
+	for _, c := range ".,'\"-!?;:()\n" {
+		p[c] = 0.003
+	}
+	p[','] = 0.01
+	p['.'] = 0.01
+	p[' '] = 0.18
+
+	var logs [256]float64
+	for i := range logs {
+		logs[i] = math.Log(p[i])
+	}
+	return logs
+}()
+
+// Score is how much text looks like English: the average log probability of
+// its bytes, from about -2.5 for plain English down to -13.8 for bytes that
+// are never in text.
+func Score(text []byte) float64 {
+	if len(text) == 0 {
+		return math.Inf(-1)
+	}
+
+	var s float64
+	for _, c := range text {
+		s += english[c]
+	}
+	return s / float64(len(text))
+}
+
+// Attack recovers texts encrypted with the same keystream.
+type Attack struct {
+	Ciphertexts [][]byte
+	// Keystream is the keystream recovered so far; Known says which of its
+	// bytes were recovered.
+	Keystream []byte
+	Known     []bool
+}
+
+// New starts an attack on ciphertexts encrypted with the same key and nonce.
+func New(ciphertexts ...[]byte) *Attack {
+	var n int
+	for _, c := range ciphertexts {
+		n = max(n, len(c))
+	}
+
+	return &Attack{
+		Ciphertexts: ciphertexts,
+		Keystream:   make([]byte, n),
+		Known:       make([]bool, n),
+	}
+}
+
+// Candidate is the crib placed at Position of text Text, and what the other
+// texts read there.
+type Candidate struct {
+	Text, Position int
+	// Others has the other texts at Position, indexed like the ciphertexts;
+	// it is nil for texts that are too short to get there.
+	Others [][]byte
+	// Score is the Score of the other texts together.
+	Score float64
+}
+
+func (c Candidate) String() string {
+	return fmt.Sprintf("text %d at %d: %q (%.2f)", c.Text, c.Position, c.Others, c.Score)
+}
+
+// Drag places crib at every position of every text and returns the
+// candidates, best scores first.
+func (a *Attack) Drag(crib []byte) []Candidate {
+	var candidates []Candidate
+
+	for i, c := range a.Ciphertexts {
+		for pos := 0; pos+len(crib) <= len(c); pos++ {
+			keystream := XOR(c[pos:], crib)
+
+			candidate := Candidate{Text: i, Position: pos, Others: make([][]byte, len(a.Ciphertexts))}
+			var all []byte
+			for j, other := range a.Ciphertexts {
+				if j == i || pos >= len(other) {
+					continue
+				}
+				candidate.Others[j] = XOR(other[pos:], keystream)
+				all = append(all, candidate.Others[j]...)
+			}
+
+			// nothing to check the guess against
+			if len(all) == 0 {
+				continue
+			}
+
+			candidate.Score = Score(all)
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(x, y Candidate) int {
+		return cmp.Compare(y.Score, x.Score)
+	})
+	return candidates
+}
+
+// Place records that text reads plain from pos on, which gives the keystream
+// there and with it every other text.
+func (a *Attack) Place(text, pos int, plain []byte) error {
+	if text < 0 || text >= len(a.Ciphertexts) || pos < 0 || pos+len(plain) > len(a.Ciphertexts[text]) {
+		return ErrPosition
+	}
+
+	for i, b := range XOR(a.Ciphertexts[text][pos:], plain) {
+		a.Keystream[pos+i] = b
+		a.Known[pos+i] = true
+	}
+	return nil
+}
+
+// Guess fills every unknown byte of the keystream with the byte that makes
+// the texts read most like English at that position. With many texts it gets
+// most of them right on its own; with two it can't tell which text has the
+// space and which one the letter, and cribs do better.
+func (a *Attack) Guess() {
+	for pos := range a.Keystream {
+		if a.Known[pos] {
+			continue
+		}
+
+		var column []byte
+		for _, c := range a.Ciphertexts {
+			if pos < len(c) {
+				column = append(column, c[pos])
+			}
+		}
+		// a lone byte could be anything
+		if len(column) < 2 {
+			continue
+		}
+
+		best, bestScore := 0, math.Inf(-1)
+		for k := 0; k < 256; k++ {
+			var s float64
+			for _, c := range column {
+				s += english[c^byte(k)]
+			}
+			if s > bestScore {
+				best, bestScore = k, s
+			}
+		}
+
+		a.Keystream[pos] = byte(best)
+		a.Known[pos] = true
+	}
+}
+
+// Plaintext is text decrypted with the keystream recovered so far, with
+// unknown instead of the bytes still unknown.
+func (a *Attack) Plaintext(text int, unknown byte) []byte {
+	c := a.Ciphertexts[text]
+	plain := make([]byte, len(c))
+	for i := range c {
+		if a.Known[i] {
+			plain[i] = c[i] ^ a.Keystream[i]
+		} else {
+			plain[i] = unknown
+		}
+	}
+	return plain
+}
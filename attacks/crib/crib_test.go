@@ -0,0 +1,112 @@
+package crib
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+var texts = []string{
+	"Meet me at the old bridge at midnight and bring the documents with you.",
+	"The attack starts at dawn, so make sure that everyone is ready to move.",
+	"Nobody should ever reuse a nonce with the same key in a stream cipher.",
+	"We have moved the money to the account in the north, as you asked us to.",
+	"Please tell the others that the meeting has been moved to next Tuesday.",
+	"It was the best of times, it was the worst of times, it was the age of.",
+	"All happy families are alike; each unhappy family is unhappy in its way.",
+	"Call me Ishmael. Some years ago, never mind how long precisely, having.",
+}
+
+func encrypt(texts []string) [][]byte {
+	key := [32]byte{1, 2, 3}
+	nonce := [12]byte{4, 5, 6}
+
+	var ciphertexts [][]byte
+	for _, t := range texts {
+		ciphertexts = append(ciphertexts, chacha.Encrypt(key, nonce, []byte(t)))
+	}
+	return ciphertexts
+}
+
+func TestXOR(t *testing.T) {
+	key := [32]byte{7}
+	nonce := make([]byte, 8)
+	a, b := []byte(texts[0]), []byte(texts[1])
+
+	for _, c := range [][2][]byte{
+		{encrypt(texts[:2])[0], encrypt(texts[:2])[1]},
+		{salsa.Encrypt(&key, nonce, a), salsa.Encrypt(&key, nonce, b)},
+	} {
+		if !bytes.Equal(XOR(c[0], c[1]), XOR(a, b)) {
+			t.Error("XOR: the keystream doesn't cancel out")
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	english := Score([]byte("the quick brown fox jumps over the lazy dog"))
+	random := Score([]byte{0x8f, 0x12, 0xd3, 0x00, 0x7e, 0xa1, 0x33, 0xfe})
+
+	if english < -3.5 || random > -8 {
+		t.Errorf("Score: %f for English, %f for random bytes", english, random)
+	}
+}
+
+func TestDrag(t *testing.T) {
+	a := New(encrypt(texts[:2])...)
+
+	best := a.Drag([]byte(" the "))[0]
+	if best.Text != 0 || best.Position != 10 || string(best.Others[1]) != " star" {
+		t.Errorf("Drag: best candidate %s", best)
+	}
+}
+
+// Two texts: one crib, grown from what the other text reads there, gives
+// both texts back.
+func TestRecoverTwo(t *testing.T) {
+	a := New(encrypt(texts[:2])...)
+
+	if err := a.Place(0, 10, []byte(" the ")); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Plaintext(1, '_'); string(got[10:15]) != " star" {
+		t.Fatalf("Place: text 1 reads %q", got)
+	}
+
+	// the reader finishes the sentences from the fragments
+	if err := a.Place(1, 0, []byte(texts[1])); err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range texts[:2] {
+		if got := a.Plaintext(i, '_'); string(got) != text {
+			t.Errorf("Plaintext %d: expected %q, got %q", i, text, got)
+		}
+	}
+
+	if err := a.Place(0, 70, []byte("you.")); err != ErrPosition {
+		t.Errorf("Place: expected ErrPosition past the end, got %v", err)
+	}
+}
+
+// Many texts: guessing each position on its own recovers most of them.
+func TestGuess(t *testing.T) {
+	a := New(encrypt(texts)...)
+	a.Guess()
+
+	var right, all int
+	for i, text := range texts {
+		got := a.Plaintext(i, '_')
+		for j := range got {
+			if got[j] == text[j] {
+				right++
+			}
+			all++
+		}
+	}
+
+	if float64(right)/float64(all) < 0.8 {
+		t.Errorf("Guess: %d of %d bytes right", right, all)
+	}
+}
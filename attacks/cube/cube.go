@@ -0,0 +1,240 @@
+package cube
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+)
+
+// Cube testers, after Aumasson, Dinur, Meier and Shamir, "Cube testers and
+// key recovery attacks on reduced-round MD6 and Trivium" (2009).
+//
+// Every output bit is a polynomial over GF(2) in the key and the nonce and
+// counter bits. Summing it over every value of k chosen nonce and counter
+// bits, the cube, leaves the superpoly: what multiplies the product of the
+// cube bits, a polynomial in the key and the other bits. For a random
+// function of that many variables the superpoly is random too, but after a
+// few rounds the degree of the output bits is still low and the superpolies
+// of large enough cubes are constant, linear or biased in the key, which
+// distinguishes the rounds from random, and linear superpolies give linear
+// equations on the key bits.
+
+// MaxCube is the largest cube Sum takes. Cubes around 24 bits, 2^24 blocks a
+// sum, are the practical limit.
+const MaxCube = 32
+
+var ErrCube = errors.New("cube: cube bits must be distinct nonce or counter bits")
+
+// Tester sums the outputs of Cipher with Rounds rounds over Cube.
+type Tester struct {
+	Cipher analysis.Cipher
+	Rounds int
+	// Cube has the bits of the cube, counting the bits of nonce || counter
+	// little endian: bit j of byte i is bit 8i+j.
+	Cube []int
+}
+
+// check returns ErrCube for a cube Sum can't run.
+func (t *Tester) check() error {
+	bits := 8 * (t.Cipher.NonceSize + t.Cipher.CounterSize)
+	if len(t.Cube) > MaxCube {
+		return fmt.Errorf("%w, and at most %d of them", ErrCube, MaxCube)
+	}
+
+	for i, b := range t.Cube {
+		if b < 0 || b >= bits || slices.Contains(t.Cube[:i], b) {
+			return ErrCube
+		}
+	}
+	return nil
+}
+
+// Sum XORs the output blocks for key and every value of the cube bits, the
+// other bits of nonce || counter being those of iv. The cube is split
+// between as many goroutines as there are CPUs.
+func (t *Tester) Sum(key, iv []byte) []byte {
+	points := uint64(1) << len(t.Cube)
+	workers := uint64(runtime.GOMAXPROCS(0))
+	workers = min(workers, points)
+
+	sums := make([][]byte, workers)
+	var wg sync.WaitGroup
+
+	for w := uint64(0); w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sum := make([]byte, t.Cipher.OutputSize)
+			local := slices.Clone(iv)
+			nonce, counter := local[:t.Cipher.NonceSize], local[t.Cipher.NonceSize:]
+
+			for x := points * w / workers; x < points*(w+1)/workers; x++ {
+				for i, b := range t.Cube {
+					local[b/8] = local[b/8]&^(1<<(b%8)) | byte(x>>i&1)<<(b%8)
+				}
+
+				for i, o := range t.Cipher.Block(key, nonce, counter, t.Rounds) {
+					sum[i] ^= o
+				}
+			}
+			sums[w] = sum
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range sums[1:] {
+		for i := range s {
+			sums[0][i] ^= s[i]
+		}
+	}
+	return sums[0]
+}
+
+// Kind is what a superpoly looks like.
+type Kind uint8
+
+const (
+	// Balanced superpolies are 1 about half of the time, like random ones.
+	Balanced Kind = iota
+	// Biased superpolies are 0 or 1 far more often than the other.
+	Biased
+	// Linear superpolies passed every linearity test: they are affine in the
+	// key.
+	Linear
+	// Constant superpolies are the same for every key tried.
+	Constant
+)
+
+func (k Kind) String() string {
+	return [...]string{"balanced", "biased", "linear", "constant"}[k]
+}
+
+// Result of Test, for each output bit, bit 8i+j being bit j of byte i.
+type Result struct {
+	Kinds []Kind
+	// Ones is the share of keys whose superpoly is 1.
+	Ones []float64
+}
+
+// Count returns the number of output bits of kind k.
+func (r Result) Count(k Kind) int {
+	var n int
+	for _, kind := range r.Kinds {
+		if kind == k {
+			n++
+		}
+	}
+	return n
+}
+
+// Test finds the superpolies of every output bit for a random iv and
+// random keys x, y, and checks them for linearity the way of Blum, Luby and
+// Rubinfeld: an affine f has f(x) ⊕ f(y) ⊕ f(x ⊕ y) = f(0) every time, and a
+// random one half of the time. tests pairs of keys take 3·tests + 1 sums.
+func (t *Tester) Test(tests int, seed uint64) (Result, error) {
+	if err := t.check(); err != nil {
+		return Result{}, err
+	}
+
+	rng := rand.New(rand.NewPCG(seed, 0))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(rng.Uint32())
+		}
+		return b
+	}
+
+	iv := random(t.Cipher.NonceSize + t.Cipher.CounterSize)
+	zero := t.Sum(make([]byte, t.Cipher.KeySize), iv)
+
+	n := 8 * t.Cipher.OutputSize
+	ones := make([]int, n)
+	linear := make([]bool, n)
+	constant := make([]bool, n)
+	for i := range linear {
+		linear[i], constant[i] = true, true
+	}
+
+	for i := 0; i < tests; i++ {
+		x, y := random(t.Cipher.KeySize), random(t.Cipher.KeySize)
+		xy := make([]byte, len(x))
+		for j := range xy {
+			xy[j] = x[j] ^ y[j]
+		}
+		fx, fy, fxy := t.Sum(x, iv), t.Sum(y, iv), t.Sum(xy, iv)
+
+		for b := 0; b < n; b++ {
+			byt, bit := b/8, b%8
+			f0 := zero[byt] >> bit & 1
+			values := [3]byte{fx[byt] >> bit & 1, fy[byt] >> bit & 1, fxy[byt] >> bit & 1}
+
+			if values[0]^values[1]^values[2] != f0 {
+				linear[b] = false
+			}
+			for _, v := range values {
+				ones[b] += int(v)
+				if v != f0 {
+					constant[b] = false
+				}
+			}
+		}
+	}
+
+	r := Result{Kinds: make([]Kind, n), Ones: make([]float64, n)}
+	samples := float64(3 * tests)
+	for b := range r.Kinds {
+		r.Ones[b] = float64(ones[b]) / samples
+
+		switch {
+		case constant[b]:
+			r.Kinds[b] = Constant
+		case linear[b]:
+			r.Kinds[b] = Linear
+		// 4 standard deviations from a half
+		case math.Abs(r.Ones[b]-0.5) > 2/math.Sqrt(samples):
+			r.Kinds[b] = Biased
+		}
+	}
+
+	return r, nil
+}
+
+// Grow adds to the cube, one at a time, the nonce or counter bit that leaves
+// the most output bits that aren't balanced, until it has size bits.
+func (t *Tester) Grow(size, tests int, seed uint64) error {
+	bits := 8 * (t.Cipher.NonceSize + t.Cipher.CounterSize)
+
+	for len(t.Cube) < size {
+		best, bestScore := -1, -1
+		for b := 0; b < bits; b++ {
+			if slices.Contains(t.Cube, b) {
+				continue
+			}
+
+			try := Tester{Cipher: t.Cipher, Rounds: t.Rounds, Cube: append(slices.Clone(t.Cube), b)}
+			r, err := try.Test(tests, seed)
+			if err != nil {
+				return err
+			}
+
+			if score := len(r.Kinds) - r.Count(Balanced); score > bestScore {
+				best, bestScore = b, score
+			}
+		}
+
+		if best < 0 {
+			return ErrCube
+		}
+		t.Cube = append(t.Cube, best)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package cube
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+)
+
+// Sum is the XOR of the blocks, one by one, however many goroutines share
+// the work.
+func TestSum(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	iv := bytes.Repeat([]byte{0xa5}, 16)
+	tester := Tester{Cipher: analysis.ChaCha, Rounds: 4, Cube: []int{0, 9, 100, 127, 64}}
+
+	expected := make([]byte, 64)
+	for x := 0; x < 32; x++ {
+		input := bytes.Clone(iv)
+		for i, b := range tester.Cube {
+			input[b/8] = input[b/8]&^(1<<(b%8)) | byte(x>>i&1)<<(b%8)
+		}
+		for i, o := range analysis.ChaCha.Block(key, input[:12], input[12:], 4) {
+			expected[i] ^= o
+		}
+	}
+
+	for _, procs := range []int{1, 3, 64} {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+		if sum := tester.Sum(key, iv); !bytes.Equal(sum, expected) {
+			t.Errorf("Sum with %d goroutines: expected %x, got %x", procs, expected, sum)
+		}
+	}
+}
+
+func TestTest(t *testing.T) {
+	cube := []int{0, 3, 6, 9, 12, 15, 18, 21, 24, 27, 30, 33}
+
+	// one round doesn't reach degree 12
+	one := Tester{Cipher: analysis.Salsa, Rounds: 1, Cube: cube}
+	r, err := one.Test(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Count(Constant); n != 512 {
+		t.Errorf("Test: %d constant superpolies after 1 round, expected 512", n)
+	}
+
+	full := Tester{Cipher: analysis.ChaCha, Rounds: 20, Cube: cube[:8]}
+	r, err = full.Test(16, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count(Constant) > 0 || r.Count(Biased) > 2 || r.Count(Linear) > 2 {
+		t.Errorf("Test: %d constant, %d biased and %d linear superpolies after 20 rounds",
+			r.Count(Constant), r.Count(Biased), r.Count(Linear))
+	}
+}
+
+// Six well chosen counter bits are enough on 3 rounds of Salsa.
+func TestGrow(t *testing.T) {
+	tester := Tester{Cipher: analysis.Salsa, Rounds: 3}
+	if err := tester.Grow(6, 4, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := tester.Test(16, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Count(Constant); n < 32 {
+		t.Errorf("Grow: cube %v has %d constant superpolies after 3 rounds", tester.Cube, n)
+	}
+}
+
+func TestCubeErrors(t *testing.T) {
+	for _, cube := range [][]int{{1, 1}, {-1}, {128}, make([]int, MaxCube+1)} {
+		tester := Tester{Cipher: analysis.ChaCha, Rounds: 1, Cube: cube}
+		if _, err := tester.Test(1, 1); !errors.Is(err, ErrCube) {
+			t.Errorf("Test with cube %v: expected ErrCube, got %v", cube, err)
+		}
+	}
+}
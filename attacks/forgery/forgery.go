@@ -0,0 +1,167 @@
+package forgery
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// Poly1305 takes a one-time key (r, s) and tags a message split in 16 byte
+// blocks c1 … cq, each read as a little-endian number with a 1 byte appended,
+// as
+//
+//	tag = ((c1·r^q + c2·r^(q-1) + … + cq·r) mod p + s) mod 2^128
+//
+// with p = 2^130 - 5. EncryptAED derives the key from the cipher key and the
+// nonce, so encrypting twice with the same nonce tags two messages with the
+// same r and s. Subtracting the tags cancels s and leaves a polynomial in r:
+//
+//	P1(r) - P2(r) = t1 - t2 + k·2^128 (mod p)
+//
+// where k, from the two reductions mod 2^128, is between -4 and 4. The roots
+// of each of those 9 polynomials are the candidates for r; the clamping of r,
+// which clears 22 of its bits, rules out all but the right one, and then
+// s = t1 - P1(r) mod 2^128. With r and s anyone can tag any ciphertext.
+
+// ErrNoKey means no key tags every pair, they weren't tagged with the same
+// key.
+var ErrNoKey = errors.New("forgery: no key tags every pair")
+
+// Pair is a message and its Poly1305 tag.
+type Pair struct {
+	Message, Tag []byte
+}
+
+// AEADPair is the pair EncryptAED tags: the ciphertext and the additional
+// data, padded to 16 bytes, then their lengths (RFC 8439, section 2.8).
+func AEADPair(cipher, aad, tag []byte) Pair {
+	return Pair{Message: AEADMessage(cipher, aad), Tag: tag}
+}
+
+// AEADMessage is what EncryptAED tags for cipher and aad.
+func AEADMessage(cipher, aad []byte) []byte {
+	var m []byte
+	for _, b := range [][]byte{aad, cipher} {
+		m = append(m, b...)
+		if n := len(b) % 16; n > 0 {
+			m = append(m, make([]byte, 16-n)...)
+		}
+	}
+
+	m = binary.LittleEndian.AppendUint64(m, uint64(len(aad)))
+	return binary.LittleEndian.AppendUint64(m, uint64(len(cipher)))
+}
+
+// Key is a Poly1305 one-time key.
+type Key struct {
+	R, S *big.Int
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("r=%032x s=%032x", k.R, k.S)
+}
+
+// clamp has the bits of r that Poly1305 keeps.
+var clamp, _ = new(big.Int).SetString("0ffffffc0ffffffc0ffffffc0fffffff", 16)
+
+// two128 is 2^128.
+var two128 = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// polynomial has the blocks of message as coefficients, so that its value
+// at r is the tag before adding s.
+func polynomial(message []byte) poly {
+	var blocks []*big.Int
+	for len(message) > 0 {
+		n := min(len(message), 16)
+		blocks = append(blocks, number(append(slices.Clone(message[:n]), 1)))
+		message = message[n:]
+	}
+
+	// c1 multiplies the highest power of r, and no block the constant term
+	f := poly{new(big.Int)}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		f = append(f, blocks[i])
+	}
+	return f
+}
+
+// number reads b as a little-endian number.
+func number(b []byte) *big.Int {
+	b = slices.Clone(b)
+	slices.Reverse(b)
+	return new(big.Int).SetBytes(b)
+}
+
+// Tag is the Poly1305 tag of message under k.
+func (k Key) Tag(message []byte) []byte {
+	a := polynomial(message).eval(k.R)
+	a.Add(a, k.S)
+	a.Mod(a, two128)
+
+	tag := a.FillBytes(make([]byte, 16))
+	slices.Reverse(tag)
+	return tag
+}
+
+// Forge returns the tag DecryptAED expects for cipher and aad.
+func (k Key) Forge(cipher, aad []byte) []byte {
+	return k.Tag(AEADMessage(cipher, aad))
+}
+
+// Recover returns the keys that tag every pair, taking the candidates from
+// the first two. Two pairs are almost always enough for a single key.
+func Recover(pairs ...Pair) ([]Key, error) {
+	if len(pairs) < 2 {
+		return nil, errors.New("forgery: need at least two pairs")
+	}
+	for _, pair := range pairs {
+		if len(pair.Tag) != 16 {
+			return nil, errors.New("forgery: tags have 16 bytes")
+		}
+	}
+
+	p1, p2 := polynomial(pairs[0].Message), polynomial(pairs[1].Message)
+	t1, t2 := number(pairs[0].Tag), number(pairs[1].Tag)
+	diff := sub(p1, p2)
+
+	var keys []Key
+	for k := int64(-4); k <= 4; k++ {
+		d := new(big.Int).Mul(big.NewInt(k), two128)
+		d.Add(d, t1)
+		d.Sub(d, t2)
+		d.Mod(d, p)
+
+		for _, r := range roots(sub(diff, poly{d})) {
+			if new(big.Int).AndNot(r, clamp).Sign() != 0 {
+				continue
+			}
+
+			s := new(big.Int).Sub(t1, p1.eval(r))
+			key := Key{R: r, S: s.Mod(s, two128)}
+
+			if key.tags(pairs) && !slices.ContainsFunc(keys, key.equal) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	return keys, nil
+}
+
+func (k Key) tags(pairs []Pair) bool {
+	for _, pair := range pairs {
+		if !slices.Equal(k.Tag(pair.Message), pair.Tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (k Key) equal(other Key) bool {
+	return k.R.Cmp(other.R) == 0 && k.S.Cmp(other.S) == 0
+}
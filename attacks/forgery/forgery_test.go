@@ -0,0 +1,95 @@
+package forgery
+
+import (
+	"bytes"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+func TestRoots(t *testing.T) {
+	// (x - 3)(x - 5)(x - (p - 1)) times x^2 + 1, which has no roots as
+	// p = 3 mod 4
+	f := poly{big.NewInt(1), new(big.Int), big.NewInt(1)}
+	want := []*big.Int{big.NewInt(3), big.NewInt(5), new(big.Int).Sub(p, big.NewInt(1))}
+	for _, r := range want {
+		f = mul(f, poly{new(big.Int).Sub(p, r), big.NewInt(1)})
+	}
+
+	got := roots(f)
+	slices.SortFunc(got, func(a, b *big.Int) int { return a.Cmp(b) })
+	if !slices.EqualFunc(got, want, func(a, b *big.Int) bool { return a.Cmp(b) == 0 }) {
+		t.Errorf("roots: expected %v, got %v", want, got)
+	}
+}
+
+// The key and message of RFC 8439, section 2.5.2.
+func TestTag(t *testing.T) {
+	r, _ := new(big.Int).SetString("806d5400e52447c036d555408bed685", 16)
+	s, _ := new(big.Int).SetString("1bf54941aff6bf4afdb20dfb8a800301", 16)
+	expected := []byte{
+		0xa8, 0x06, 0x1d, 0xc1, 0x30, 0x51, 0x36, 0xc6, 0xc2, 0x2b, 0x8b, 0xaf, 0x0c, 0x01, 0x27, 0xa9,
+	}
+
+	if tag := (Key{R: r, S: s}).Tag([]byte("Cryptographic Forum Research Group")); !bytes.Equal(tag, expected) {
+		t.Errorf("Tag: expected %x, got %x", expected, tag)
+	}
+}
+
+func TestForge(t *testing.T) {
+	key := [32]byte{1, 2, 3}
+	nonce := [12]byte{4, 5, 6}
+	aad := []byte("header")
+
+	// two messages sealed with the same nonce
+	plain1 := []byte("Transfer 10 EUR to Alice, reference 2024-001.")
+	plain2 := []byte("Transfer 25 EUR to Bob, reference 2024-002, for the concert.")
+	cipher1, tag1 := chacha.EncryptAED(key, nonce, plain1, aad)
+	cipher2, tag2 := chacha.EncryptAED(key, nonce, plain2, aad)
+
+	keys, err := Recover(AEADPair(cipher1, aad, tag1), AEADPair(cipher2, aad, tag2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("Recover: %d keys", len(keys))
+	}
+
+	// the one-time key is the first 32 bytes of block 0
+	design := chacha.Design()
+	oneTime := design.Block(key[:], nonce[:], 0)
+	r := number(oneTime[:16])
+	r.And(r, clamp)
+	if keys[0].R.Cmp(r) != 0 || keys[0].S.Cmp(number(oneTime[16:32])) != 0 {
+		t.Errorf("Recover: expected r=%032x s=%032x, got %s", r, number(oneTime[16:32]), keys[0])
+	}
+
+	// the known message gives the keystream, and the key a valid tag for
+	// whatever the attacker wants to say
+	forged := []byte("Transfer 99999 EUR to Eve, reference 2024-003.")
+	cipher := make([]byte, len(forged))
+	for i := range forged {
+		cipher[i] = forged[i] ^ plain2[i] ^ cipher2[i]
+	}
+	aad = []byte("another header")
+
+	message, err := chacha.DecryptAED(key, nonce, cipher, keys[0].Forge(cipher, aad), aad)
+	if err != nil {
+		t.Fatalf("DecryptAED rejects the forgery: %s", err)
+	}
+	if !bytes.Equal(message, forged) {
+		t.Errorf("DecryptAED: expected %q, got %q", forged, message)
+	}
+}
+
+func TestRecoverDifferentKeys(t *testing.T) {
+	aad := []byte("header")
+	cipher1, tag1 := chacha.EncryptAED([32]byte{1}, [12]byte{}, []byte("first message"), aad)
+	cipher2, tag2 := chacha.EncryptAED([32]byte{2}, [12]byte{}, []byte("second message"), aad)
+
+	if _, err := Recover(AEADPair(cipher1, aad, tag1), AEADPair(cipher2, aad, tag2)); err != ErrNoKey {
+		t.Errorf("Recover: expected ErrNoKey, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package forgery
+
+import "math/big"
+
+// p is the Poly1305 prime, 2^130 - 5.
+var p = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 130), big.NewInt(5))
+
+// poly is a polynomial over GF(p), with the coefficient of x^i at i.
+type poly []*big.Int
+
+func (f poly) trim() poly {
+	for len(f) > 0 && f[len(f)-1].Sign() == 0 {
+		f = f[:len(f)-1]
+	}
+	return f
+}
+
+// degree is -1 for the zero polynomial.
+func (f poly) degree() int {
+	return len(f.trim()) - 1
+}
+
+func (f poly) eval(x *big.Int) *big.Int {
+	y := new(big.Int)
+	for i := len(f) - 1; i >= 0; i-- {
+		y.Mul(y, x)
+		y.Add(y, f[i])
+		y.Mod(y, p)
+	}
+	return y
+}
+
+func sub(f, g poly) poly {
+	out := make(poly, max(len(f), len(g)))
+	for i := range out {
+		c := new(big.Int)
+		if i < len(f) {
+			c.Set(f[i])
+		}
+		if i < len(g) {
+			c.Sub(c, g[i])
+		}
+		out[i] = c.Mod(c, p)
+	}
+	return out.trim()
+}
+
+func mul(f, g poly) poly {
+	if len(f) == 0 || len(g) == 0 {
+		return nil
+	}
+
+	out := make(poly, len(f)+len(g)-1)
+	for i := range out {
+		out[i] = new(big.Int)
+	}
+
+	var t big.Int
+	for i, a := range f {
+		for j, b := range g {
+			out[i+j].Add(out[i+j], t.Mul(a, b))
+		}
+	}
+	for _, c := range out {
+		c.Mod(c, p)
+	}
+	return out.trim()
+}
+
+// divmod returns q and r with f = q·g + r and r of lower degree than g,
+// which must not be zero.
+func divmod(f, g poly) (poly, poly) {
+	g = g.trim()
+	n := len(g) - 1
+
+	r := make(poly, len(f))
+	for i, c := range f {
+		r[i] = new(big.Int).Set(c)
+	}
+	r = r.trim()
+	if len(r) <= n {
+		return nil, r
+	}
+
+	inv := new(big.Int).ModInverse(g[n], p)
+	q := make(poly, len(r)-n)
+
+	var t big.Int
+	for i := len(r) - 1; i >= n; i-- {
+		c := new(big.Int).Mul(r[i], inv)
+		c.Mod(c, p)
+		q[i-n] = c
+
+		for j := 0; j <= n; j++ {
+			r[i-n+j].Sub(r[i-n+j], t.Mul(c, g[j]))
+			r[i-n+j].Mod(r[i-n+j], p)
+		}
+	}
+
+	return q.trim(), r[:n].trim()
+}
+
+// monic divides f by its leading coefficient.
+func monic(f poly) poly {
+	f = f.trim()
+	if len(f) == 0 {
+		return f
+	}
+
+	inv := new(big.Int).ModInverse(f[len(f)-1], p)
+	out := make(poly, len(f))
+	for i, c := range f {
+		out[i] = new(big.Int).Mul(c, inv)
+		out[i].Mod(out[i], p)
+	}
+	return out
+}
+
+func gcd(f, g poly) poly {
+	f, g = f.trim(), g.trim()
+	for len(g) > 0 {
+		_, r := divmod(f, g)
+		f, g = g, r
+	}
+	return monic(f)
+}
+
+// powmod is f^e mod m.
+func powmod(f poly, e *big.Int, m poly) poly {
+	_, f = divmod(f, m)
+	out := poly{big.NewInt(1)}
+
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		_, out = divmod(mul(out, out), m)
+		if e.Bit(i) == 1 {
+			_, out = divmod(mul(out, f), m)
+		}
+	}
+	return out
+}
+
+// roots returns the distinct roots of f in GF(p).
+func roots(f poly) []*big.Int {
+	if f.degree() < 1 {
+		return nil
+	}
+
+	// x^p - x is the product of (x - a) for every a, so the gcd keeps one
+	// linear factor for every root of f
+	x := poly{new(big.Int), big.NewInt(1)}
+	return split(gcd(f, sub(powmod(x, p, f), x)), 1)
+}
+
+// split finds the roots of f, a product of distinct linear factors, the
+// Cantor-Zassenhaus way: (x + a)^((p-1)/2) is 1 mod (x - r) for about half of
+// the roots r, those where r + a is a square, so its gcd with f splits f in
+// two.
+func split(f poly, a int64) []*big.Int {
+	switch f.degree() {
+	case 0:
+		return nil
+	case 1:
+		r := new(big.Int).Neg(f[0])
+		return []*big.Int{r.Mod(r, p)}
+	}
+
+	half := new(big.Int).Rsh(p, 1)
+	for ; ; a++ {
+		h := powmod(poly{big.NewInt(a), big.NewInt(1)}, half, f)
+		g := gcd(f, sub(h, poly{big.NewInt(1)}))
+
+		if d := g.degree(); d > 0 && d < f.degree() {
+			q, _ := divmod(f, g)
+			return append(split(g, a+1), split(monic(q), a+1)...)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package neutral has what the attacks of Aumasson et al., "New Features of
+// Latin Dances" (FSE 2008), share between ChaCha and Salsa20: random initial
+// states, walking back from the output with a guess of the key, and how
+// neutral each key bit is to that walk back.
+package neutral
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+)
+
+// Bias turns how often a difference was seen into ε in
+// Pr[difference = 1] = (1 + ε) / 2.
+func Bias(count, samples int) float64 {
+	return 2*float64(count)/float64(samples) - 1
+}
+
+// CmpAbs compares the absolute values of a and b.
+func CmpAbs(a, b float64) int {
+	a, b = math.Abs(a), math.Abs(b)
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// RandomState is an initial state of d with a random key, nonce and counter,
+// drawn from rng in that order.
+func RandomState(d *arx.Design, rng *rand.Rand) [16]uint32 {
+	key := make([]byte, 32)
+	nonce := make([]byte, d.NonceSize())
+	for i := range key {
+		key[i] = byte(rng.Uint32())
+	}
+	for i := range nonce {
+		nonce[i] = byte(rng.Uint32())
+	}
+
+	var counter uint64
+	if d.CounterSize() > 4 {
+		counter = rng.Uint64()
+	} else {
+		counter = uint64(rng.Uint32())
+	}
+
+	return d.State(key, nonce, counter)
+}
+
+// Output is rounds rounds of d on x with the feed-forward.
+func Output(d *arx.Design, x [16]uint32, rounds int) [16]uint32 {
+	z := x
+	d.Permute(&z, rounds)
+	for i := range z {
+		z[i] += x[i]
+	}
+	return z
+}
+
+// FlipKeyBit flips key bit bit of state, bit 0 being the lowest bit of the
+// first key word.
+func FlipKeyBit(d *arx.Design, state *[16]uint32, bit int) {
+	state[d.KeyWords[bit/32]] ^= 1 << (bit % 32)
+}
+
+// SetKeyBits sets the key bits in bits of state to the bits of v, lowest
+// first.
+func SetKeyBits(d *arx.Design, state *[16]uint32, bits []int, v uint64) {
+	for i, k := range bits {
+		w := d.KeyWords[k/32]
+		state[w] &^= 1 << (k % 32)
+		state[w] |= uint32(v>>i&1) << (k % 32)
+	}
+}
+
+// Pair is two initial states with the input difference and their outputs.
+type Pair struct {
+	X, Y   [16]uint32
+	ZX, ZY [16]uint32
+}
+
+// Walk goes back from the output of Rounds rounds of Design to the state
+// after Middle rounds, where bit Bit of word Word of the difference is biased.
+type Walk struct {
+	Design         *arx.Design
+	Rounds, Middle int
+	Word, Bit      int
+}
+
+// Back subtracts the initial state from the output z, walks back to the
+// middle round and returns the biased bit.
+func (w Walk) Back(z, initial *[16]uint32) uint32 {
+	var s [16]uint32
+	for i := range s {
+		s[i] = z[i] - initial[i]
+	}
+
+	w.Design.Inverse(&s, w.Rounds, w.Middle)
+	return s[w.Word] >> w.Bit & 1
+}
+
+// Difference is the biased bit of the difference of p after walking back
+// with the key in x and y.
+func (w Walk) Difference(x, y *[16]uint32, p *Pair) uint32 {
+	return w.Back(&p.ZX, x) ^ w.Back(&p.ZY, y)
+}
+
+// Neutrality measures, for each of the 256 key bits, how neutral it is to
+// the walk back: the bias of "the difference is the same when the key bit is
+// flipped". Neutral bits have a neutrality close to 1, bits that matter close
+// to 0.
+func (w Walk) Neutrality(pairs []Pair) [256]float64 {
+	var neutrality [256]float64
+
+	for _, p := range pairs {
+		expected := w.Difference(&p.X, &p.Y, &p)
+
+		for k := range neutrality {
+			FlipKeyBit(w.Design, &p.X, k)
+			FlipKeyBit(w.Design, &p.Y, k)
+
+			if w.Difference(&p.X, &p.Y, &p) == expected {
+				neutrality[k]++
+			}
+
+			FlipKeyBit(w.Design, &p.X, k)
+			FlipKeyBit(w.Design, &p.Y, k)
+		}
+	}
+
+	for k := range neutrality {
+		neutrality[k] = Bias(int(neutrality[k]), len(pairs))
+	}
+
+	return neutrality
+}
+
+// NeutralBits returns the key bits with a neutrality of at least gamma.
+func NeutralBits(neutrality [256]float64, gamma float64) []int {
+	var bits []int
+	for k, n := range neutrality {
+		if n >= gamma {
+			bits = append(bits, k)
+		}
+	}
+	return bits
+}
@@ -0,0 +1,33 @@
+package malleability_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/attacks/malleability"
+)
+
+// Mallory is a guest allowed to move 100, and knows what the server puts in
+// a token because it is the JSON of her own command.
+func Example() {
+	mallory := malleability.Command{User: "mallory", Role: "guest", Amount: 100}
+	plain, _ := json.Marshal(mallory)
+
+	for _, server := range []*malleability.Server{
+		malleability.NewServer(malleability.ChaCha),
+		malleability.NewServer(malleability.Salsa),
+		malleability.NewAEADServer(),
+	} {
+		token, _ := server.Issue(mallory)
+
+		token.Ciphertext, _ = malleability.Rewrite(token.Ciphertext, plain, `"role":"guest"`, `"role":"admin"`)
+		token.Ciphertext, _ = malleability.Rewrite(token.Ciphertext, plain, `"amount":100`, `"amount":900`)
+
+		command, err := server.Handle(token)
+		fmt.Printf("%+v %v\n", command, err)
+	}
+	// Output:
+	// {User:mallory Role:admin Amount:900} <nil>
+	// {User:mallory Role:admin Amount:900} <nil>
+	// {User: Role: Amount:0} invalid tag
+}
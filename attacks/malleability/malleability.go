@@ -0,0 +1,150 @@
+package malleability
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// Encrypt, in chacha and salsa alike, XORs the message with the keystream
+// and nothing else. Flipping a bit of the ciphertext flips the same bit of
+// what it decrypts to, and whoever knows, or can guess, what the plaintext
+// says at some position can make it say anything else of the same length
+// there, without the key. A server that trusts whatever decrypts to valid
+// JSON is trusting the attacker. EncryptAED tags the ciphertext, and
+// DecryptAED rejects any change to it.
+
+// Stream is an unauthenticated stream cipher.
+type Stream struct {
+	Name      string
+	NonceSize int
+	XOR       func(key *[32]byte, nonce, message []byte) []byte
+}
+
+var (
+	ChaCha = Stream{Name: "chacha", NonceSize: 12, XOR: func(key *[32]byte, nonce, message []byte) []byte {
+		return chacha.Encrypt(*key, [12]byte(nonce), message)
+	}}
+	Salsa = Stream{Name: "salsa", NonceSize: 8, XOR: salsa.Encrypt}
+)
+
+// Command is what the server runs.
+type Command struct {
+	User   string `json:"user"`
+	Role   string `json:"role"`
+	Amount int    `json:"amount"`
+}
+
+// Token is an encrypted command. Tag is empty without authentication.
+type Token struct {
+	Nonce, Ciphertext, Tag []byte
+}
+
+// Server issues encrypted commands to its users and runs the ones they send
+// back, trusting them because only the server has the key.
+type Server struct {
+	key [32]byte
+	// stream is nil for EncryptAED
+	stream *Stream
+}
+
+// NewServer returns a server that encrypts commands with stream.
+func NewServer(stream Stream) *Server {
+	return &Server{key: randomKey(), stream: &stream}
+}
+
+// NewAEADServer returns a server that encrypts commands with EncryptAED.
+func NewAEADServer() *Server {
+	return &Server{key: randomKey()}
+}
+
+// randomKey panics if crypto/rand fails, there is no server without a key.
+func randomKey() [32]byte {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Issue encrypts c.
+func (s *Server) Issue(c Command) (Token, error) {
+	message, err := json.Marshal(c)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if s.stream == nil {
+		var nonce [12]byte
+		if _, err := rand.Read(nonce[:]); err != nil {
+			return Token{}, err
+		}
+		cipher, tag := chacha.EncryptAED(s.key, nonce, message, nil)
+		return Token{Nonce: nonce[:], Ciphertext: cipher, Tag: tag}, nil
+	}
+
+	nonce := make([]byte, s.stream.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return Token{}, err
+	}
+	return Token{Nonce: nonce, Ciphertext: s.stream.XOR(&s.key, nonce, message)}, nil
+}
+
+// Handle decrypts t and returns the command to run.
+func (s *Server) Handle(t Token) (Command, error) {
+	var message []byte
+
+	if s.stream == nil {
+		if len(t.Nonce) != 12 {
+			return Command{}, errors.New("malleability: bad nonce")
+		}
+
+		var err error
+		message, err = chacha.DecryptAED(s.key, [12]byte(t.Nonce), t.Ciphertext, t.Tag, nil)
+		if err != nil {
+			return Command{}, err
+		}
+	} else {
+		if len(t.Nonce) != s.stream.NonceSize {
+			return Command{}, errors.New("malleability: bad nonce")
+		}
+		message = s.stream.XOR(&s.key, t.Nonce, t.Ciphertext)
+	}
+
+	var c Command
+	err := json.Unmarshal(message, &c)
+	return c, err
+}
+
+// Flip XORs delta into cipher from offset on, which XORs delta into what it
+// decrypts to.
+func Flip(cipher []byte, offset int, delta []byte) []byte {
+	out := bytes.Clone(cipher)
+	for i, d := range delta {
+		out[offset+i] ^= d
+	}
+	return out
+}
+
+// Rewrite changes the plaintext of cipher, which the attacker knows or
+// guesses is plain, so that from reads to instead.
+func Rewrite(cipher, plain []byte, from, to string) ([]byte, error) {
+	if len(from) != len(to) {
+		return nil, errors.New("malleability: can only rewrite to text of the same length")
+	}
+
+	offset := bytes.Index(plain, []byte(from))
+	if offset < 0 || len(plain) > len(cipher) {
+		return nil, errors.New("malleability: text not in the plaintext")
+	}
+
+	delta := make([]byte, len(from))
+	for i := range delta {
+		delta[i] = from[i] ^ to[i]
+	}
+	return Flip(cipher, offset, delta), nil
+}
@@ -0,0 +1,68 @@
+package malleability
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	c := Command{User: "alice", Role: "user", Amount: 10}
+
+	for _, s := range []*Server{NewServer(ChaCha), NewServer(Salsa), NewAEADServer()} {
+		token, err := s.Issue(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := s.Handle(token); err != nil || got != c {
+			t.Errorf("Handle: expected %+v, got %+v (%v)", c, got, err)
+		}
+	}
+}
+
+func TestFlip(t *testing.T) {
+	cipher := []byte{1, 2, 3, 4}
+	if got := Flip(cipher, 1, []byte{0xff, 1}); !bytes.Equal(got, []byte{1, 0xfd, 2, 4}) {
+		t.Errorf("Flip: got %v", got)
+	}
+	if !bytes.Equal(cipher, []byte{1, 2, 3, 4}) {
+		t.Error("Flip changed its input")
+	}
+}
+
+// Every single bit flip goes through without authentication and none with
+// it.
+func TestEveryBit(t *testing.T) {
+	c := Command{User: "bob", Role: "user", Amount: 1}
+
+	for _, s := range []*Server{NewServer(Salsa), NewAEADServer()} {
+		token, _ := s.Issue(c)
+
+		var accepted int
+		for i := range 8 * len(token.Ciphertext) {
+			flipped := token
+			flipped.Ciphertext = Flip(token.Ciphertext, i/8, []byte{1 << (i % 8)})
+
+			if got, err := s.Handle(flipped); err == nil && got != c {
+				accepted++
+			}
+		}
+
+		if s.stream == nil && accepted != 0 {
+			t.Errorf("AEAD: %d flipped bits changed the command", accepted)
+		}
+		if s.stream != nil && accepted == 0 {
+			t.Error("no flipped bit changed the command")
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	plain := []byte(`{"amount":100}`)
+	if _, err := Rewrite(plain, plain, "100", "1000"); err == nil {
+		t.Error("Rewrite: expected an error for a longer text")
+	}
+	if _, err := Rewrite(plain, plain, "200", "300"); err == nil {
+		t.Error("Rewrite: expected an error for a text not in the plaintext")
+	}
+}
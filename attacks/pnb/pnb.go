@@ -0,0 +1,369 @@
+package pnb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/mario-areias/latin-dances-go/attacks/internal/neutral"
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+// The probabilistic neutral bits attack of Aumasson, Fischer, Khazaei, Meier
+// and Rechberger, "New Features of Latin Dances: Analysis of Salsa, ChaCha,
+// and Rumba" (FSE 2008), on ChaCha reduced to a few rounds.
+//
+// A one bit difference in the nonce or counter still shows up as a biased
+// bit of the state after a few rounds. Given the output of the full reduced
+// cipher and a guess of the key, the attacker subtracts the initial state and
+// walks back with the inverse round to that middle round. With the right key
+// the bias appears, with a wrong one it doesn't. Many key bits barely matter
+// to the walk back (they are neutral), so the attacker only guesses the others
+// and finds the neutral ones by brute force afterwards.
+
+// Differential is a one bit input difference in the counter or nonce (words
+// 12 to 15 of the state) and the one bit output difference it causes after
+// Rounds rounds of the permutation.
+type Differential struct {
+	InWord, InBit   int
+	OutWord, OutBit int
+	Rounds          int
+	// Bias is ε in Pr[output difference = 1] = (1 + ε) / 2.
+	Bias float64
+}
+
+func (d Differential) String() string {
+	return fmt.Sprintf("[%d]_%d -> [%d]_%d after %d rounds, bias %.4f", d.InWord, d.InBit, d.OutWord, d.OutBit, d.Rounds, d.Bias)
+}
+
+var design = chacha.Design()
+
+// walk goes back from rounds rounds to the middle round of d.
+func (d Differential) walk(rounds int) neutral.Walk {
+	return neutral.Walk{Design: &design, Rounds: rounds, Middle: d.Rounds, Word: d.OutWord, Bit: d.OutBit}
+}
+
+// FindBiases measures every output bit difference after rounds rounds for each
+// one bit input difference in the counter and nonce, over samples random
+// states. It returns the differentials with |bias| of at least minBias, the
+// largest first.
+func FindBiases(rounds, samples int, minBias float64, seed uint64) []Differential {
+	var found []Differential
+	rng := rand.New(rand.NewPCG(seed, 0))
+
+	for in := 12 * 32; in < 16*32; in++ {
+		var counts [16][32]int
+
+		for s := 0; s < samples; s++ {
+			x := neutral.RandomState(&design, rng)
+			y := x
+			y[in/32] ^= 1 << (in % 32)
+
+			design.Permute(&x, rounds)
+			design.Permute(&y, rounds)
+
+			for w := range x {
+				diff := x[w] ^ y[w]
+				for b := 0; diff != 0; b++ {
+					counts[w][b] += int(diff & 1)
+					diff >>= 1
+				}
+			}
+		}
+
+		for w := range counts {
+			for b, c := range counts[w] {
+				if e := neutral.Bias(c, samples); math.Abs(e) >= minBias {
+					found = append(found, Differential{InWord: in / 32, InBit: in % 32, OutWord: w, OutBit: b, Rounds: rounds, Bias: e})
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(found, func(a, b Differential) int {
+		return -neutral.CmpAbs(a.Bias, b.Bias)
+	})
+
+	return found
+}
+
+// Measure returns the bias of d over samples random states.
+func (d Differential) Measure(samples int, seed uint64) float64 {
+	rng := rand.New(rand.NewPCG(seed, 0))
+
+	count := 0
+	for s := 0; s < samples; s++ {
+		x := neutral.RandomState(&design, rng)
+		y := x
+		y[d.InWord] ^= 1 << d.InBit
+
+		design.Permute(&x, d.Rounds)
+		design.Permute(&y, d.Rounds)
+		count += int((x[d.OutWord] ^ y[d.OutWord]) >> d.OutBit & 1)
+	}
+
+	return neutral.Bias(count, samples)
+}
+
+// Neutrality measures, for each of the 256 key bits, how neutral it is to the
+// walk back from rounds rounds to the middle round of d: the bias of "the
+// output difference is the same when the key bit is flipped". Neutral bits
+// have a neutrality close to 1, bits that matter close to 0.
+func (d Differential) Neutrality(rounds, samples int, seed uint64) [256]float64 {
+	rng := rand.New(rand.NewPCG(seed, 0))
+
+	pairs := make([]neutral.Pair, samples)
+	for i := range pairs {
+		p := &pairs[i]
+		p.X = neutral.RandomState(&design, rng)
+		p.Y = p.X
+		p.Y[d.InWord] ^= 1 << d.InBit
+		p.ZX, p.ZY = neutral.Output(&design, p.X, rounds), neutral.Output(&design, p.Y, rounds)
+	}
+
+	return d.walk(rounds).Neutrality(pairs)
+}
+
+// NeutralBits returns the key bits with a neutrality of at least gamma.
+func NeutralBits(neutrality [256]float64, gamma float64) []int {
+	return neutral.NeutralBits(neutrality, gamma)
+}
+
+// Oracle is the ChaCha block reduced to the rounds of the attack, with the
+// secret key the attacker is after.
+type Oracle func(counter uint32, nonce [12]byte) []byte
+
+// NewOracle returns the oracle of key with rounds rounds.
+func NewOracle(key [32]byte, rounds int) Oracle {
+	return func(counter uint32, nonce [12]byte) []byte {
+		return chacha.BlockRounds(key, counter, nonce, rounds)
+	}
+}
+
+// Attack recovers the key bits in Unknown, the attacker knows all the others.
+// The bits in Neutral, a subset of Unknown, are set to zero during the walk
+// back and found by brute force after the others.
+type Attack struct {
+	Differential
+	Rounds  int
+	Unknown []int
+	Neutral []int
+	// Samples is the number of pairs asked to the oracle.
+	Samples int
+	// Threshold is the |bias| a guess needs to be a candidate, half of the
+	// bias Calibrate measures is a good value.
+	Threshold float64
+}
+
+// ErrNotFound means no candidate key matched the oracle.
+var ErrNotFound = errors.New("pnb: key not found")
+
+// significant returns the unknown bits that are not neutral.
+func (a *Attack) significant() []int {
+	var bits []int
+	for _, k := range a.Unknown {
+		if !slices.Contains(a.Neutral, k) {
+			bits = append(bits, k)
+		}
+	}
+	return bits
+}
+
+// Calibrate measures the bias of the walk back with the right key bits except
+// the neutral ones, which are zero. It is the bias Run looks for.
+func (a *Attack) Calibrate(samples int, seed uint64) float64 {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	walk := a.walk(a.Rounds)
+
+	count := 0
+	for s := 0; s < samples; s++ {
+		x := neutral.RandomState(&design, rng)
+		y := x
+		y[a.InWord] ^= 1 << a.InBit
+
+		p := neutral.Pair{X: x, Y: y, ZX: neutral.Output(&design, x, a.Rounds), ZY: neutral.Output(&design, y, a.Rounds)}
+		neutral.SetKeyBits(&design, &p.X, a.Neutral, 0)
+		neutral.SetKeyBits(&design, &p.Y, a.Neutral, 0)
+
+		count += int(walk.Difference(&p.X, &p.Y, &p))
+	}
+
+	return neutral.Bias(count, samples)
+}
+
+// MaxSamples is the most pairs an attack asks for. Run walks every pair back
+// for each guess of the significant bits, past it even a few significant bits
+// take hours.
+const MaxSamples = 1 << 16
+
+// Plan sets up an attack on rounds rounds with d: the neutrals most neutral
+// key bits and the significant least neutral ones are unknown. It measures the
+// bias to expect with the right key and asks for enough pairs that a wrong
+// guess almost never reaches half of it. It fails when no bias is left or it
+// would take more than MaxSamples pairs to see it.
+func Plan(d Differential, rounds, significant, neutrals int, seed uint64) (*Attack, error) {
+	neutrality := d.Neutrality(rounds, 1<<11, seed)
+
+	order := make([]int, len(neutrality))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return neutral.CmpAbs(neutrality[b], neutrality[a])
+	})
+
+	a := &Attack{
+		Differential: d,
+		Rounds:       rounds,
+		Neutral:      slices.Clone(order[:neutrals]),
+	}
+	a.Unknown = append(slices.Clone(a.Neutral), order[len(order)-significant:]...)
+	slices.Sort(a.Unknown)
+
+	expected := math.Abs(a.Calibrate(1<<14, seed))
+	if expected == 0 {
+		return nil, fmt.Errorf("pnb: no bias left walking back from %d rounds to %d", rounds, d.Rounds)
+	}
+	a.Threshold = expected / 2
+
+	// the threshold is 4.5 standard deviations of the bias of a wrong guess
+	samples := math.Ceil(math.Pow(9/expected, 2))
+	if samples > MaxSamples {
+		return nil, fmt.Errorf("pnb: a bias of %.4f needs %.0f pairs, more than %d", expected, samples, MaxSamples)
+	}
+	a.Samples = int(samples)
+
+	return a, nil
+}
+
+// Run asks oracle for Samples pairs of blocks with the input difference and
+// recovers the unknown key bits. known is the key with any value in the
+// unknown bits.
+func (a *Attack) Run(oracle Oracle, known [32]byte, seed uint64) ([32]byte, error) {
+	if len(a.Unknown) > 64 {
+		return known, errors.New("pnb: too many unknown bits")
+	}
+	if a.Samples < 1 || a.Samples > MaxSamples {
+		return known, fmt.Errorf("pnb: %d pairs, expected 1 to %d", a.Samples, MaxSamples)
+	}
+
+	rng := rand.New(rand.NewPCG(seed, 0))
+	base := chacha.State(known, 0, [12]byte{})
+
+	pairs := make([]neutral.Pair, a.Samples)
+	for i := range pairs {
+		var nonce [12]byte
+		for j := range nonce {
+			nonce[j] = byte(rng.Uint32())
+		}
+		counter := rng.Uint32()
+
+		p := &pairs[i]
+		p.X = chacha.State(known, counter, nonce)
+		p.Y = p.X
+		p.Y[a.InWord] ^= 1 << a.InBit
+
+		// p.Y is a valid input as well, words 12 to 15 are the counter and nonce
+		ny := nonce
+		for w := 13; w < 16; w++ {
+			putWord(ny[(w-13)*4:], p.Y[w])
+		}
+
+		p.ZX = bytesToState(oracle(counter, nonce))
+		p.ZY = bytesToState(oracle(p.Y[12], ny))
+	}
+
+	significant := a.significant()
+	candidates := a.search(base, pairs, significant)
+
+	for _, c := range candidates {
+		state := base
+		neutral.SetKeyBits(&design, &state, significant, c)
+
+		if key, ok := a.bruteForce(state, &pairs[0]); ok {
+			return key, nil
+		}
+	}
+
+	return known, ErrNotFound
+}
+
+// search tries every value of the significant bits, in parallel, and returns
+// the ones with a bias of at least Threshold.
+func (a *Attack) search(base [16]uint32, pairs []neutral.Pair, significant []int) []uint64 {
+	walk := a.walk(a.Rounds)
+	var mu sync.Mutex
+	var candidates []uint64
+	var wg sync.WaitGroup
+
+	guesses := uint64(1) << len(significant)
+	workers := uint64(runtime.NumCPU())
+
+	for w := uint64(0); w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for g := w; g < guesses; g += workers {
+				key := base
+				neutral.SetKeyBits(&design, &key, significant, g)
+				neutral.SetKeyBits(&design, &key, a.Neutral, 0)
+
+				count := 0
+				for i := range pairs {
+					p := &pairs[i]
+					x, y := p.X, p.Y
+					copy(x[4:12], key[4:12])
+					copy(y[4:12], key[4:12])
+
+					count += int(walk.Difference(&x, &y, p))
+				}
+
+				if math.Abs(neutral.Bias(count, len(pairs))) >= a.Threshold {
+					mu.Lock()
+					candidates = append(candidates, g)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(candidates)
+	return candidates
+}
+
+// bruteForce tries every value of the neutral bits against one known output.
+func (a *Attack) bruteForce(state [16]uint32, p *neutral.Pair) ([32]byte, bool) {
+	for v := uint64(0); v < 1<<len(a.Neutral); v++ {
+		x := p.X
+		copy(x[4:12], state[4:12])
+		neutral.SetKeyBits(&design, &x, a.Neutral, v)
+
+		if neutral.Output(&design, x, a.Rounds) == p.ZX {
+			var key [32]byte
+			for i := 0; i < 8; i++ {
+				putWord(key[i*4:], x[4+i])
+			}
+			return key, true
+		}
+	}
+
+	return [32]byte{}, false
+}
+
+func putWord(b []byte, w uint32) {
+	b[0], b[1], b[2], b[3] = byte(w), byte(w>>8), byte(w>>16), byte(w>>24)
+}
+
+func bytesToState(b []byte) [16]uint32 {
+	var s [16]uint32
+	for i := range s {
+		s[i] = uint32(b[4*i]) | uint32(b[4*i+1])<<8 | uint32(b[4*i+2])<<16 | uint32(b[4*i+3])<<24
+	}
+	return s
+}
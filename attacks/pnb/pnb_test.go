@@ -0,0 +1,96 @@
+package pnb
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFindBiases(t *testing.T) {
+	found := FindBiases(3, 1<<10, 0.15, 1)
+	if len(found) == 0 {
+		t.Fatal("FindBiases: no bias of 0.15 after 3 rounds")
+	}
+
+	for i := 1; i < len(found); i++ {
+		if math.Abs(found[i].Bias) > math.Abs(found[i-1].Bias) {
+			t.Errorf("FindBiases: %s before %s", found[i-1], found[i])
+		}
+	}
+
+	for _, d := range found {
+		if d.InWord < 12 {
+			t.Errorf("FindBiases: input difference %s outside the counter and nonce", d)
+		}
+	}
+}
+
+// The differential of the paper, with ε = -0.0272.
+func TestMeasure(t *testing.T) {
+	d := Differential{InWord: 13, InBit: 13, OutWord: 11, OutBit: 0, Rounds: 3}
+	if e := d.Measure(1<<18, 1); math.Abs(e+0.0272) > 0.01 {
+		t.Errorf("Measure: expected bias -0.0272, got %f", e)
+	}
+}
+
+func TestNeutrality(t *testing.T) {
+	d := Differential{InWord: 14, InBit: 21, OutWord: 0, OutBit: 0, Rounds: 3}
+
+	// without walking back the key can't change word 0
+	neutrality := d.Neutrality(3, 64, 1)
+	if n := len(NeutralBits(neutrality, 1)); n != 256 {
+		t.Errorf("Neutrality: %d neutral bits without walking back, expected 256", n)
+	}
+
+	// the more rounds back, the fewer neutral bits
+	n5 := len(NeutralBits(d.Neutrality(5, 1024, 1), 0.5))
+	n6 := len(NeutralBits(d.Neutrality(6, 1024, 1), 0.5))
+	n7 := len(NeutralBits(d.Neutrality(7, 1024, 1), 0.5))
+	if n5 <= n6 || n6 <= n7 || n7 == 0 {
+		t.Errorf("Neutrality: %d, %d and %d neutral bits from 5, 6 and 7 rounds", n5, n6, n7)
+	}
+}
+
+func TestAttack(t *testing.T) {
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(i * 37)
+	}
+
+	for _, rounds := range []int{6, 7} {
+		d := Differential{InWord: 14, InBit: 21, OutWord: 0, OutBit: 0, Rounds: 3}
+		a, err := Plan(d, rounds, 6, 6, 1)
+		if err != nil {
+			t.Fatalf("Plan: %s", err)
+		}
+
+		if len(a.Unknown) != 12 || len(a.significant()) != 6 {
+			t.Fatalf("Plan: %d unknown bits, %d significant", len(a.Unknown), len(a.significant()))
+		}
+
+		known := key
+		for _, k := range a.Unknown {
+			known[k/8] ^= 1 << (k % 8)
+		}
+
+		found, err := a.Run(NewOracle(key, rounds), known, 2)
+		if err != nil {
+			t.Fatalf("%d rounds: %s", rounds, err)
+		}
+		if found != key {
+			t.Errorf("%d rounds: expected %x, got %x", rounds, key, found)
+		}
+	}
+}
+
+// With 20 neutral bits the bias left after walking back from 7 rounds is too
+// small to see in MaxSamples pairs.
+func TestPlanTooManySamples(t *testing.T) {
+	d := Differential{InWord: 14, InBit: 21, OutWord: 0, OutBit: 0, Rounds: 3}
+	if a, err := Plan(d, 7, 16, 20, 1); err == nil {
+		t.Errorf("Plan: %d pairs, expected an error", a.Samples)
+	}
+
+	if _, err := (&Attack{Differential: d, Rounds: 7}).Run(NewOracle([32]byte{}, 7), [32]byte{}, 1); err == nil {
+		t.Error("Run: no error without pairs")
+	}
+}
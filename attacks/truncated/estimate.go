@@ -0,0 +1,104 @@
+package truncated
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/mario-areias/latin-dances-go/attacks/internal/neutral"
+)
+
+// Estimate of a key recovery attack on Rounds rounds: Differential gives the
+// bias after fewer rounds, the attacker guesses the key bits that matter to
+// walk back the other rounds and sets the Neutral others to zero.
+type Estimate struct {
+	Differential Differential
+	Rounds       int
+	// Gamma is the neutrality a key bit needs to be neutral.
+	Gamma   float64
+	Neutral int
+	// Bias after walking back with the neutral bits set to zero.
+	Bias float64
+	// Data and Time are log2 of the pairs and of the work.
+	Data, Time float64
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%d rounds: %s after %d rounds, %d neutral bits, bias %.4f, 2^%.1f pairs, 2^%.1f time",
+		e.Rounds, e.Differential, e.Differential.Rounds, e.Neutral, e.Bias, e.Data, e.Time)
+}
+
+// Estimate measures how neutral each key bit is to walking back from rounds
+// rounds to d.Rounds, as in Aumasson et al., "New Features of Latin Dances"
+// (2008). The bits with a neutrality of at least gamma are neutral; Estimate
+// tries gamma from 1 down to 0.05 and returns the attack with the least time.
+// The data and time follow section 3.4 of the paper, with the false alarm
+// probability that minimises the time. Biases within 3 standard deviations of
+// zero are taken as no bias at all.
+func (d Differential) Estimate(rounds, samples int, seed uint64) Estimate {
+	rng := rand.New(rand.NewPCG(seed, 0))
+
+	pairs := make([]neutral.Pair, samples)
+	for i := range pairs {
+		p := &pairs[i]
+		p.X = neutral.RandomState(&design, rng)
+		p.Y = p.X
+		p.Y[d.In.Word] ^= 1 << d.In.Bit
+		p.ZX, p.ZY = forward(p.X, rounds, true), forward(p.Y, rounds, true)
+	}
+
+	walk := neutral.Walk{Design: &design, Rounds: rounds, Middle: d.Rounds, Word: d.Out.Word, Bit: d.Out.Bit}
+	neutrality := walk.Neutrality(pairs)
+
+	noise := 3 / math.Sqrt(float64(samples))
+	best := Estimate{Differential: d, Rounds: rounds, Gamma: 1, Data: math.Inf(1), Time: math.Inf(1)}
+
+	for g := 20; g >= 1; g-- {
+		gamma := float64(g) / 20
+
+		bits := neutral.NeutralBits(neutrality, gamma)
+
+		// the bias with the right significant bits and the neutral ones zeroed
+		count := 0
+		for _, p := range pairs {
+			neutral.SetKeyBits(&design, &p.X, bits, 0)
+			neutral.SetKeyBits(&design, &p.Y, bits, 0)
+			count += int(walk.Difference(&p.X, &p.Y, &p))
+		}
+
+		e := Estimate{Differential: d, Rounds: rounds, Gamma: gamma, Neutral: len(bits), Bias: neutral.Bias(count, samples)}
+		if math.Abs(e.Bias) < noise {
+			continue
+		}
+
+		e.Data, e.Time = complexity(256-len(bits), e.Bias)
+		if e.Time < best.Time {
+			best = e
+		}
+	}
+
+	return best
+}
+
+// complexity returns log2 of the data and time of an attack guessing m bits
+// with a bias of eps: N = ((sqrt(α log 4) + 3 sqrt(1 - ε²)) / ε)² pairs
+// and 2^m N + 2^(256-α) time, for the false alarm probability 2^-α that
+// minimises the time.
+func complexity(m int, eps float64) (float64, float64) {
+	if eps == 0 {
+		return math.Inf(1), math.Inf(1)
+	}
+
+	data, time := math.Inf(1), math.Inf(1)
+	for alpha := 0; alpha <= m; alpha++ {
+		n := max(1, math.Pow((math.Sqrt(float64(alpha)*math.Log(4))+3*math.Sqrt(1-eps*eps))/eps, 2))
+		// log2(2^m N + 2^(256-α))
+		a, b := float64(m)+math.Log2(n), float64(256-alpha)
+		t := max(a, b) + math.Log2(1+math.Pow(2, min(a, b)-max(a, b)))
+		if t < time {
+			data, time = math.Log2(n), t
+		}
+	}
+
+	return data, time
+}
@@ -0,0 +1,84 @@
+package truncated
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	// after 2 rounds some output bits still always differ, or never do
+	if d := Search(2, true, 256, 1)[0]; math.Abs(d.Bias) != 1 {
+		t.Errorf("2 rounds: best differential %s has bias %f, expected 1", d, d.Bias)
+	}
+
+	if d := Search(4, true, 1<<12, 1)[0]; math.Abs(d.Bias) < 0.1 {
+		t.Errorf("4 rounds: best differential %s has bias %f, expected at least 0.1", d, d.Bias)
+	}
+
+	// the best of 128 * 512 biases of nothing stays within 5 standard deviations
+	samples := 1 << 10
+	if d := Search(6, true, samples, 1)[0]; math.Abs(d.Bias) > 5/math.Sqrt(float64(samples)) {
+		t.Errorf("6 rounds: best differential %s has bias %f, expected none", d, d.Bias)
+	}
+}
+
+// The differential Aumasson et al. extend to 8 rounds, ε = -0.131.
+func TestMeasure(t *testing.T) {
+	d := Differential{In: Bit{7, 31}, Out: Bit{1, 14}, Rounds: 4}
+	if e := d.Measure(1<<16, 1); math.Abs(e+0.131) > 0.015 {
+		t.Errorf("Measure: expected bias -0.131, got %f", e)
+	}
+
+	// the feed-forward weakens it but doesn't hide it
+	d.FeedForward = true
+	if e := d.Measure(1<<16, 1); e > -0.05 {
+		t.Errorf("Measure with the feed-forward: expected a bias, got %f", e)
+	}
+}
+
+func TestDistinguish(t *testing.T) {
+	d := Search(3, true, 1<<10, 1)[0]
+	d.Bias = d.Measure(1<<12, 2)
+	pairs := int(DataNeeded(d.Bias))
+
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	if e, salsa := d.Distinguish(NewOracle(&key, 3), pairs, 3); !salsa {
+		t.Errorf("Distinguish: 3 round Salsa taken as random, bias %f of %f", e, d.Bias)
+	}
+
+	if e, salsa := d.Distinguish(RandomOracle(4), pairs, 3); salsa {
+		t.Errorf("Distinguish: random taken as Salsa, bias %f of %f", e, d.Bias)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	d := Differential{In: Bit{7, 31}, Out: Bit{1, 14}, Rounds: 4}
+
+	// the paper gets 2^151 on 7 rounds
+	e7 := d.Estimate(7, 1<<13, 1)
+	if e7.Neutral == 0 || e7.Time > 200 {
+		t.Errorf("Estimate: %s", e7)
+	}
+
+	e8 := d.Estimate(8, 1<<13, 1)
+	if e8.Time <= e7.Time {
+		t.Errorf("Estimate: 8 rounds (%s) as easy as 7 rounds (%s)", e8, e7)
+	}
+}
+
+func TestComplexity(t *testing.T) {
+	// even with no false alarms, the neutral bits are still brute forced
+	for _, m := range []int{16, 100, 200} {
+		if data, time := complexity(m, 0.1); time < float64(256-m) || data < math.Log2(800) {
+			t.Errorf("complexity(%d, 0.1) = 2^%f data, 2^%f time", m, data, time)
+		}
+	}
+
+	if _, time := complexity(100, 0); !math.IsInf(time, 1) {
+		t.Errorf("complexity without a bias = 2^%f", time)
+	}
+}
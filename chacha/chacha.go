@@ -6,26 +6,24 @@ import (
 	"errors"
 	"math/big"
 	"math/bits"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/trace"
 )
 
 func Encrypt(key [32]byte, nonce [12]byte, message []byte) []byte {
-	counter := uint32(1)
-
-	result := make([]byte, len(message))
-	for i := 0; len(message) >= 64; i += 64 {
-		end := i + 64
-
-		copy(result[i:end], encrypt(key, nonce, counter, message[:64]))
-		counter++
-
-		message = message[64:]
-	}
-
-	if len(message) > 0 {
-		i := len(result) - len(message)
-		end := i + len(message)
+	return EncryptCounter(key, nonce, 1, message)
+}
 
-		copy(result[i:end], encrypt(key, nonce, counter, message))
+// EncryptCounter is Encrypt with the keystream starting at block counter
+// instead of 1. Encrypt starts at 1 because the AEAD keeps block 0 for the
+// Poly1305 key; the test vectors of RFC 8439 Appendix A.2 start at 0 and 42.
+// It panics if the message needs more blocks than the 32 bit counter has
+// left, instead of reusing the keystream from block 0.
+func EncryptCounter(key [32]byte, nonce [12]byte, counter uint32, message []byte) []byte {
+	result, err := design.XORKeyStream(key[:], nonce[:], uint64(counter), message)
+	if err != nil {
+		panic(err)
 	}
 
 	return result
@@ -42,10 +40,13 @@ func EncryptAED(key [32]byte, nonce [12]byte, message, aad []byte) ([]byte, []by
 }
 
 func mac(cipher, aad []byte) []byte {
-	paddedAad := append(aad, padding(aad)...)
-	paddedCipher := append(cipher, padding(cipher)...)
-
-	macData := append(paddedAad, paddedCipher...)
+	// build a new slice, appending to aad or cipher could overwrite whatever
+	// the caller has after them (like the tag)
+	macData := make([]byte, 0, len(aad)+len(cipher)+48)
+	macData = append(macData, aad...)
+	macData = append(macData, padding(aad)...)
+	macData = append(macData, cipher...)
+	macData = append(macData, padding(cipher)...)
 
 	lengthAad := make([]byte, 8)
 	lengthCipher := make([]byte, 8)
@@ -73,19 +74,6 @@ func DecryptAED(key [32]byte, nonce [12]byte, cipher, tag, aad []byte) ([]byte,
 	return message, nil
 }
 
-func encrypt(key [32]byte, nonce [12]byte, counter uint32, message []byte) []byte {
-	result := make([]byte, len(message))
-
-	stream := block(key, counter, nonce)
-	s := wordsToBytes(stream)
-
-	for i, b := range message {
-		result[i] = b ^ s[i]
-	}
-
-	return result
-}
-
 func wordsToBytes(w []uint32) []byte {
 	writer := new(bytes.Buffer)
 
@@ -119,24 +107,31 @@ func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
 	return a, b, c, d
 }
 
-func initState(key [32]byte, counter uint32, nonce [12]byte) []uint32 {
-	var w1, w2, w3, w4 uint32
-	w1 = 0x61707865
-	w2 = 0x3320646e
-	w3 = 0x79622d32
-	w4 = 0x6b206574
-
-	s := make([]uint32, 16)
-	s[0] = w1
-	s[1] = w2
-	s[2] = w3
-	s[3] = w4
-
-	copy(s[4:12], bytesToWords(key[:]))
-	s[12] = counter
-	copy(s[13:16], bytesToWords(nonce[:]))
+// design is ChaCha20 as an arx design, with the layout of RFC 8439: a 32 bit
+// counter in word 12 and a 96 bit nonce after it. It is cloned so nothing
+// outside the package, not even arx.ColumnDiagonal, can change the cipher.
+var design = (&arx.Design{
+	Name:                "chacha",
+	Constants:           [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574},
+	ConstantWords:       [4]int{0, 1, 2, 3},
+	KeyWords:            [8]int{4, 5, 6, 7, 8, 9, 10, 11},
+	NonceWords:          []int{13, 14, 15},
+	CounterWords:        []int{12},
+	QuarterRound:        quarterRound,
+	InverseQuarterRound: inverseQuarterRound,
+	Schedule:            arx.ColumnDiagonal,
+	Rounds:              20,
+}).Clone()
+
+// Design returns a copy of ChaCha20 as an arx design, to analyse or to start
+// a new design from. Changing it doesn't change the cipher.
+func Design() arx.Design {
+	return *design.Clone()
+}
 
-	return s
+func initState(key [32]byte, counter uint32, nonce [12]byte) []uint32 {
+	s := design.State(key[:], nonce[:], uint64(counter))
+	return s[:]
 }
 
 func bytesToWords(b []byte) []uint32 {
@@ -150,31 +145,111 @@ func bytesToWords(b []byte) []uint32 {
 	return w
 }
 
+// State returns the initial state of the block function: constants, key,
+// counter and nonce.
+func State(key [32]byte, counter uint32, nonce [12]byte) [16]uint32 {
+	return [16]uint32(initState(key, counter, nonce))
+}
+
+// Block returns the 64 bytes of keystream the ChaCha20 block function
+// generates for key, counter and nonce.
+func Block(key [32]byte, counter uint32, nonce [12]byte) []byte {
+	return wordsToBytes(block(key, counter, nonce))
+}
+
+// BlockTrace is Block, calling obs with the state after every quarter round,
+// every round and the feed-forward.
+func BlockTrace(key [32]byte, counter uint32, nonce [12]byte, obs trace.Observer) []byte {
+	return wordsToBytes(observedBlock(key, counter, nonce, obs))
+}
+
+// BlockRounds is Block with rounds rounds instead of 20. Odd round counts end
+// with a column round. Reduced rounds are only for cryptanalysis.
+func BlockRounds(key [32]byte, counter uint32, nonce [12]byte, rounds int) []byte {
+	return wordsToBytes(roundsBlock(key, counter, nonce, rounds, nil))
+}
+
 func block(key [32]byte, counter uint32, nonce [12]byte) []uint32 {
-	initState := initState(key, counter, nonce)
-	state := make([]uint32, 16)
-	copy(state, initState)
+	return observedBlock(key, counter, nonce, nil)
+}
 
-	for i := 0; i < 10; i++ {
-		innerBlock(state)
-	}
+func observedBlock(key [32]byte, counter uint32, nonce [12]byte, obs trace.Observer) []uint32 {
+	return roundsBlock(key, counter, nonce, 20, obs)
+}
 
-	for i := 0; i < 16; i++ {
-		state[i] += initState[i]
-	}
+func roundsBlock(key [32]byte, counter uint32, nonce [12]byte, rounds int, obs trace.Observer) []uint32 {
+	state := design.Hash(design.State(key[:], nonce[:], uint64(counter)), rounds, obs)
+	return state[:]
+}
+
+// innerBlock, columnRound and diagonalRound are the rounds of Section 2.3,
+// the schedule of design.
+
+func innerBlock(state []uint32) {
+	columnRound(state)
+	diagonalRound(state)
+}
+
+func columnRound(state []uint32) {
+	design.Round((*[16]uint32)(state), 0)
+}
+
+func diagonalRound(state []uint32) {
+	design.Round((*[16]uint32)(state), 1)
+}
+
+// Permutation is the ChaCha permutation on its own, without the feed-forward
+// that block adds at the end.
+type Permutation struct{}
+
+// Permute applies rounds rounds to state. Rounds alternate between column and
+// diagonal rounds, so 20 rounds are the 10 innerBlocks of ChaCha20.
+func (Permutation) Permute(state *[16]uint32, rounds int) {
+	design.Permute(state, rounds)
+}
 
+// Inverse undoes rounds of Permute: it takes the state after from rounds back
+// to the state after to rounds.
+func (Permutation) Inverse(state *[16]uint32, from, to int) {
+	design.Inverse(state, from, to)
+}
+
+// InvertPermutation returns the state Permute started from, given the state
+// after rounds rounds. It is why block adds the initial state at the end:
+// without the feed-forward a single block gives the key away.
+func InvertPermutation(state [16]uint32, rounds int) [16]uint32 {
+	Permutation{}.Inverse(&state, rounds, 0)
 	return state
 }
 
-func innerBlock(state []uint32) {
-	state[0], state[4], state[8], state[12] = quarterRound(state[0], state[4], state[8], state[12])
-	state[1], state[5], state[9], state[13] = quarterRound(state[1], state[5], state[9], state[13])
-	state[2], state[6], state[10], state[14] = quarterRound(state[2], state[6], state[10], state[14])
-	state[3], state[7], state[11], state[15] = quarterRound(state[3], state[7], state[11], state[15])
-	state[0], state[5], state[10], state[15] = quarterRound(state[0], state[5], state[10], state[15])
-	state[1], state[6], state[11], state[12] = quarterRound(state[1], state[6], state[11], state[12])
-	state[2], state[7], state[8], state[13] = quarterRound(state[2], state[7], state[8], state[13])
-	state[3], state[4], state[9], state[14] = quarterRound(state[3], state[4], state[9], state[14])
+func inverseQuarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	b = bits.RotateLeft32(b, -7)
+	b ^= c
+	c -= d
+	d = bits.RotateLeft32(d, -8)
+	d ^= a
+	a -= b
+	b = bits.RotateLeft32(b, -12)
+	b ^= c
+	c -= d
+	d = bits.RotateLeft32(d, -16)
+	d ^= a
+	a -= b
+
+	return a, b, c, d
+}
+
+func inverseColumnRound(state []uint32) {
+	design.InverseRound((*[16]uint32)(state), 0)
+}
+
+func inverseDiagonalRound(state []uint32) {
+	design.InverseRound((*[16]uint32)(state), 1)
+}
+
+func inverseInnerBlock(state []uint32) {
+	inverseDiagonalRound(state)
+	inverseColumnRound(state)
 }
 
 func clamp(r []byte) []byte {
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"slices"
 	"testing"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/trace"
 )
 
 // Tests got from here https://www.rfc-editor.org/rfc/rfc8439#section-2.1
@@ -94,6 +97,16 @@ func TestBlock(t *testing.T) {
 	if !slices.Equal(b, expectedBlock[:]) {
 		t.Errorf("Block: Expected %s, got %s", printWords(expectedBlock[:]), printWords(b))
 	}
+
+	// and serialized, as in the keystream
+	expectedStream := []byte{0x10, 0xf1, 0xe7, 0xe4, 0xd1, 0x3b, 0x59, 0x15, 0x50, 0x0f, 0xdd, 0x1f, 0xa3, 0x20, 0x71, 0xc4,
+		0xc7, 0xd1, 0xf4, 0xc7, 0x33, 0xc0, 0x68, 0x03, 0x04, 0x22, 0xaa, 0x9a, 0xc3, 0xd4, 0x6c, 0x4e,
+		0xd2, 0x82, 0x64, 0x46, 0x07, 0x9f, 0xaa, 0x09, 0x14, 0xc2, 0xd7, 0x05, 0xd9, 0x8b, 0x02, 0xa2,
+		0xb5, 0x12, 0x9c, 0xd1, 0xde, 0x16, 0x4e, 0xb9, 0xcb, 0xd0, 0x83, 0xe8, 0xa2, 0x50, 0x3c, 0x4e}
+
+	if stream := Block(key, count, nonce); !slices.Equal(stream, expectedStream) {
+		t.Errorf("Block: Expected %s, got %s", printBytes(expectedStream), printBytes(stream))
+	}
 }
 
 func TestEncrypt(t *testing.T) {
@@ -173,6 +186,26 @@ func TestEncryptAED(t *testing.T) {
 	}
 }
 
+// cipher and tag usually come from the same buffer, computing the mac must
+// not write over the tag
+func TestDecryptAEDSharedBuffer(t *testing.T) {
+	key := [32]byte{0x80, 0x81, 0x82, 0x83}
+	nonce := [12]byte{0x07}
+	aad := []byte("header")
+
+	cipher, tag := EncryptAED(key, nonce, []byte("not a multiple of 16"), aad)
+	buffer := append(slices.Clone(cipher), tag...)
+
+	message, err := DecryptAED(key, nonce, buffer[:len(cipher)], buffer[len(cipher):], aad)
+	if err != nil {
+		t.Errorf("DecryptAED: %s", err)
+	}
+
+	if string(message) != "not a multiple of 16" {
+		t.Errorf("DecryptAED: Expected %s, got %s", "not a multiple of 16", message)
+	}
+}
+
 func printWords(w []uint32) string {
 	s := "\n"
 	for i := 0; i < 16; i++ {
@@ -185,3 +218,188 @@ func printWords(w []uint32) string {
 	s += "\n"
 	return s
 }
+
+func TestPermute(t *testing.T) {
+	state := [16]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574,
+		0x03020100, 0x07060504, 0x0b0a0908, 0x0f0e0d0c,
+		0x13121110, 0x17161514, 0x1b1a1918, 0x1f1e1d1c,
+		0x00000001, 0x09000000, 0x4a000000, 0x00000000}
+
+	// same as 10 rounds of innerBlock in Section 2.3.2
+	expected := [16]uint32{0x837778ab, 0xe238d763, 0xa67ae21e, 0x5950bb2f,
+		0xc4f2d0c7, 0xfc62bb2f, 0x8fa018fc, 0x3f5ec7b7,
+		0x335271c2, 0xf29489f3, 0xeabda8fc, 0x82e46ebd,
+		0xd19c12b4, 0xb04e16de, 0x9e83d0cb, 0x4e3c50a2}
+
+	Permutation{}.Permute(&state, 20)
+
+	if state != expected {
+		t.Errorf("Permute: Expected %s, got %s", printWords(expected[:]), printWords(state[:]))
+	}
+}
+
+func TestInverse(t *testing.T) {
+	key := [32]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+
+	initial := State(key, 1, [12]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x4a})
+
+	state := initial
+	Permutation{}.Permute(&state, 7)
+
+	middle := initial
+	Permutation{}.Permute(&middle, 3)
+
+	// back from round 7 to round 3, then to the start
+	Permutation{}.Inverse(&state, 7, 3)
+	if state != middle {
+		t.Errorf("Inverse(7, 3): Expected %s, got %s", printWords(middle[:]), printWords(state[:]))
+	}
+
+	Permutation{}.Inverse(&state, 3, 0)
+	if state != initial {
+		t.Errorf("Inverse(3, 0): Expected %s, got %s", printWords(initial[:]), printWords(state[:]))
+	}
+}
+
+func TestInverseRounds(t *testing.T) {
+	state := []uint32{0x879531e0, 0xc5ecf37d, 0x516461b1, 0xc9a62f8a,
+		0x44c20ef3, 0x3390af7f, 0xd9fc690b, 0x2a5f714c,
+		0x53372767, 0xb00a5631, 0x974c541a, 0x359e9963,
+		0x5c971061, 0x3d631689, 0x2098d9d6, 0x91dbd320}
+
+	// the quarter round of Section 2.2.1
+	a, b, c, d := quarterRound(state[2], state[7], state[8], state[13])
+	if a, b, c, d := inverseQuarterRound(a, b, c, d); a != state[2] || b != state[7] || c != state[8] || d != state[13] {
+		t.Errorf("inverseQuarterRound: got %08x %08x %08x %08x", a, b, c, d)
+	}
+
+	for _, round := range []struct {
+		name             string
+		forward, inverse func([]uint32)
+	}{
+		{"column", columnRound, inverseColumnRound},
+		{"diagonal", diagonalRound, inverseDiagonalRound},
+		{"inner block", innerBlock, inverseInnerBlock},
+	} {
+		x := slices.Clone(state)
+		round.forward(x)
+		round.inverse(x)
+
+		if !slices.Equal(x, state) {
+			t.Errorf("inverse %s round: Expected %s, got %s", round.name, printWords(state), printWords(x))
+		}
+	}
+}
+
+func TestBlockTrace(t *testing.T) {
+	key := [32]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+
+	nonce := [12]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00,
+		0x00, 0x4a, 0x00, 0x00, 0x00, 0x00}
+
+	var steps []trace.Step
+	out := BlockTrace(key, 1, nonce, trace.Collect(&steps))
+
+	if !slices.Equal(out, Block(key, 1, nonce)) {
+		t.Errorf("BlockTrace: Expected %s, got %s", printBytes(Block(key, 1, nonce)), printBytes(out))
+	}
+
+	// initial, 20 rounds of 4 quarter rounds and the round itself, feed-forward
+	if len(steps) != 1+20*5+1 {
+		t.Fatalf("BlockTrace: Expected %d steps, got %d", 1+20*5+1, len(steps))
+	}
+
+	if steps[0].Kind != trace.Initial || !slices.Equal(steps[0].State[:], initState(key, 1, nonce)) {
+		t.Errorf("BlockTrace: first step should be the initial state, got %v", steps[0])
+	}
+
+	// after 10 rounds of innerBlock, Section 2.3.2
+	expectedRounds := [16]uint32{0x837778ab, 0xe238d763, 0xa67ae21e, 0x5950bb2f,
+		0xc4f2d0c7, 0xfc62bb2f, 0x8fa018fc, 0x3f5ec7b7,
+		0x335271c2, 0xf29489f3, 0xeabda8fc, 0x82e46ebd,
+		0xd19c12b4, 0xb04e16de, 0x9e83d0cb, 0x4e3c50a2}
+
+	if last := steps[len(steps)-2]; last.Kind != trace.DiagonalRound || last.Round != 20 || last.State != expectedRounds {
+		t.Errorf("BlockTrace: Expected round 20 %s, got %v %d %s", printWords(expectedRounds[:]), last.Kind, last.Round, printWords(last.State[:]))
+	}
+
+	if last := steps[len(steps)-1]; last.Kind != trace.FeedForward || !slices.Equal(last.State[:], block(key, 1, nonce)) {
+		t.Errorf("BlockTrace: last step should be the feed-forward, got %v", last)
+	}
+
+	// a quarter round only touches its own words
+	for i := 1; i < len(steps); i++ {
+		if steps[i].Kind != trace.QuarterRound {
+			continue
+		}
+
+		for w := 0; w < 16; w++ {
+			if !slices.Contains(steps[i].Words[:], w) && steps[i].State[w] != steps[i-1].State[w] {
+				t.Errorf("BlockTrace: round %d quarter %d changed word %d", steps[i].Round, steps[i].Quarter, w)
+			}
+		}
+	}
+}
+
+func TestBlockRounds(t *testing.T) {
+	key := [32]byte{0x00, 0x01, 0x02, 0x03}
+	nonce := [12]byte{0x00, 0x00, 0x00, 0x09}
+
+	if out := BlockRounds(key, 1, nonce, 20); !slices.Equal(out, Block(key, 1, nonce)) {
+		t.Errorf("BlockRounds(20): Expected %s, got %s", printBytes(Block(key, 1, nonce)), printBytes(out))
+	}
+
+	// no rounds: the feed-forward doubles the initial state
+	state := initState(key, 1, nonce)
+	for i := range state {
+		state[i] *= 2
+	}
+	if out := BlockRounds(key, 1, nonce, 0); !slices.Equal(out, wordsToBytes(state)) {
+		t.Errorf("BlockRounds(0): Expected %s, got %s", printBytes(wordsToBytes(state)), printBytes(out))
+	}
+
+	// one round is a column round
+	state = initState(key, 1, nonce)
+	init := slices.Clone(state)
+	columnRound(state)
+	for i := range state {
+		state[i] += init[i]
+	}
+	if out := BlockRounds(key, 1, nonce, 1); !slices.Equal(out, wordsToBytes(state)) {
+		t.Errorf("BlockRounds(1): Expected %s, got %s", printBytes(wordsToBytes(state)), printBytes(out))
+	}
+}
+
+// Changing the design Design returns, or the schedule it came from, must not
+// change the cipher.
+func TestDesignIsACopy(t *testing.T) {
+	key, nonce := [32]byte{1, 2, 3}, [12]byte{4, 5, 6}
+	before := Encrypt(key, nonce, make([]byte, 100))
+
+	d := Design()
+	d.Constants[0] = 0
+	d.Schedule[0].Quarters[0][0] = 15
+	d.QuarterRound = func(a, b, c, d uint32) (uint32, uint32, uint32, uint32) { return a, b, c, d }
+	arx.ColumnDiagonal[1].Quarters[0][0] = 1
+	defer func() { arx.ColumnDiagonal[1].Quarters[0][0] = 0 }()
+
+	if after := Encrypt(key, nonce, make([]byte, 100)); !slices.Equal(before, after) {
+		t.Errorf("Encrypt changed with the design: %x, was %x", after, before)
+	}
+}
+
+func TestEncryptCounterOverflow(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("EncryptCounter didn't panic past the last block")
+		}
+	}()
+
+	EncryptCounter([32]byte{}, [12]byte{}, 0xffffffff, make([]byte, 65))
+}
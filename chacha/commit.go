@@ -0,0 +1,50 @@
+package chacha
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// ChaCha20-Poly1305 is not key-committing: an attacker who knows two keys can
+// build a ciphertext and tag that decrypt under both of them. The committing
+// mode derives, from the key and the nonce, a subkey for EncryptAED and a 32
+// byte commitment to the key. The commitment goes in front of the ciphertext
+// and is checked before anything else, so only the key that produced it can
+// decrypt.
+
+// CommitmentSize is the number of bytes the committing mode adds to the cipher.
+const CommitmentSize = 32
+
+// EncryptCommitting is EncryptAED with a commitment to the key prepended to the
+// cipher.
+func EncryptCommitting(key [32]byte, nonce [12]byte, message, aad []byte) ([]byte, []byte) {
+	encKey, commitment := commitKeys(key, nonce)
+
+	cipher, tag := EncryptAED(encKey, nonce, message, aad)
+
+	return append(commitment[:], cipher...), tag
+}
+
+// DecryptCommitting checks the commitment and then decrypts like DecryptAED.
+func DecryptCommitting(key [32]byte, nonce [12]byte, cipher, tag, aad []byte) ([]byte, error) {
+	if len(cipher) < CommitmentSize {
+		return nil, errors.New("cipher too short")
+	}
+
+	encKey, commitment := commitKeys(key, nonce)
+
+	if subtle.ConstantTimeCompare(commitment[:], cipher[:CommitmentSize]) != 1 {
+		return nil, errors.New("invalid key commitment")
+	}
+
+	return DecryptAED(encKey, nonce, cipher[CommitmentSize:], tag, aad)
+}
+
+// commitKeys splits one ChaCha20 block of the master key into the encryption
+// key and the commitment. Finding two keys with the same commitment means
+// finding a 256 bit collision on the block function.
+func commitKeys(key [32]byte, nonce [12]byte) ([32]byte, [CommitmentSize]byte) {
+	stream := Block(key, 0, nonce)
+
+	return [32]byte(stream[0:32]), [CommitmentSize]byte(stream[32:64])
+}
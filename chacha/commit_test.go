@@ -0,0 +1,169 @@
+package chacha
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+func TestEncryptCommitting(t *testing.T) {
+	nonce := [12]byte{0x07, 0x00, 0x00, 0x00, 0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47}
+	aad := []byte("header")
+	plaintext := []byte("Ladies and Gentlemen of the class of '99")
+
+	cipher, tag := EncryptCommitting(sivKey, nonce, plaintext, aad)
+
+	if len(cipher) != len(plaintext)+CommitmentSize {
+		t.Errorf("EncryptCommitting: expected %d bytes, got %d", len(plaintext)+CommitmentSize, len(cipher))
+	}
+
+	message, err := DecryptCommitting(sivKey, nonce, cipher, tag, aad)
+	if err != nil {
+		t.Errorf("DecryptCommitting: %s", err)
+	}
+	if !slices.Equal(message, plaintext) {
+		t.Errorf("DecryptCommitting: Expected %s, got %s", plaintext, message)
+	}
+
+	other := sivKey
+	other[0] ^= 1
+	if _, err := DecryptCommitting(other, nonce, cipher, tag, aad); err == nil {
+		t.Errorf("DecryptCommitting accepted another key")
+	}
+
+	tampered := bytes.Clone(cipher)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := DecryptCommitting(sivKey, nonce, tampered, tag, aad); err == nil {
+		t.Errorf("DecryptCommitting accepted a tampered cipher")
+	}
+
+	if _, err := DecryptCommitting(sivKey, nonce, cipher[:CommitmentSize-1], tag, aad); err == nil {
+		t.Errorf("DecryptCommitting accepted a short cipher")
+	}
+}
+
+// Build a ciphertext and tag that DecryptAED accepts under two different keys,
+// then show the committing mode rejects the same trick.
+func TestMultiKeyCollision(t *testing.T) {
+	nonce := [12]byte{}
+	k1 := randomKey()
+	k2 := randomKey()
+
+	cipher, tag := multiKeyCollision(t, k1, k2, nonce)
+
+	m1, err := DecryptAED(k1, nonce, cipher, tag, nil)
+	if err != nil {
+		t.Fatalf("DecryptAED with first key: %s", err)
+	}
+	m2, err := DecryptAED(k2, nonce, cipher, tag, nil)
+	if err != nil {
+		t.Fatalf("DecryptAED with second key: %s", err)
+	}
+	if slices.Equal(m1, m2) {
+		t.Errorf("both keys decrypted to the same message")
+	}
+
+	// the committing mode uses the derived key for the AEAD, so the attacker
+	// builds the collision on the derived keys and copies k1's commitment
+	e1, c1 := commitKeys(k1, nonce)
+	e2, _ := commitKeys(k2, nonce)
+
+	cipher, tag = multiKeyCollision(t, e1, e2, nonce)
+	committed := append(c1[:], cipher...)
+
+	if _, err := DecryptCommitting(k1, nonce, committed, tag, nil); err != nil {
+		t.Errorf("DecryptCommitting with first key: %s", err)
+	}
+	if _, err := DecryptCommitting(k2, nonce, committed, tag, nil); err == nil {
+		t.Errorf("DecryptCommitting accepted the collision under the second key")
+	}
+}
+
+// multiKeyCollision returns a two block cipher whose Poly1305 tag is the same
+// under both keys. With empty aad the tag is
+//
+//	((c1 + 2^128) r^3 + (c2 + 2^128) r^2 + (lengths + 2^128) r mod p) + s mod 2^128
+//
+// so after picking c2 at random the tags only differ by a linear function of c1
+// mod p. Solving it does not always give a valid 16 byte block, or the final
+// mod 2^128 can still break the equality, so try again until it works.
+func multiKeyCollision(t *testing.T, k1, k2 [32]byte, nonce [12]byte) ([]byte, []byte) {
+	p := constantPrime()
+	two128 := new(big.Int).Lsh(big.NewInt(1), 128)
+
+	r1, s1 := polyKey(k1, nonce)
+	r2, s2 := polyKey(k2, nonce)
+
+	// lengths block: aad length 0, cipher length 32
+	lengths := new(big.Int).Lsh(big.NewInt(32), 64)
+	lengths.Add(lengths, two128)
+
+	for i := 0; i < 1000; i++ {
+		c2 := make([]byte, 16)
+		if _, err := rand.Read(c2); err != nil {
+			panic(err)
+		}
+		m2 := littleEndiaBytesToBigInt(bytes.Clone(c2))
+		m2.Add(&m2, two128)
+
+		// we want acc1 - acc2 = s2 - s1, that is
+		// m1 (r1^3 - r2^3) = s2 - s1 - m2 (r1^2 - r2^2) - lengths (r1 - r2)
+		target := new(big.Int).Sub(s2, s1)
+		target.Sub(target, mulMod(&m2, diffPow(r1, r2, 2, p), p))
+		target.Sub(target, mulMod(lengths, diffPow(r1, r2, 1, p), p))
+		target.Mod(target, p)
+
+		inv := new(big.Int).ModInverse(diffPow(r1, r2, 3, p), p)
+		m1 := mulMod(target, inv, p)
+
+		// m1 must be a 16 byte block with the extra 0x01 byte on top
+		c1 := new(big.Int).Sub(m1, two128)
+		if c1.Sign() < 0 || c1.Cmp(two128) >= 0 {
+			continue
+		}
+
+		block := make([]byte, 16)
+		c1.FillBytes(block)
+		bigToLitleEndian(block)
+
+		cipher := append(block, c2...)
+		tag1 := poly1305Mac(mac(cipher, nil), [32]byte(poly1305KeyGen(k1, nonce)))
+		tag2 := poly1305Mac(mac(cipher, nil), [32]byte(poly1305KeyGen(k2, nonce)))
+
+		if slices.Equal(tag1, tag2) {
+			return cipher, tag1
+		}
+	}
+
+	t.Fatalf("could not find a multi-key collision")
+	return nil, nil
+}
+
+func polyKey(key [32]byte, nonce [12]byte) (*big.Int, *big.Int) {
+	k := poly1305KeyGen(key, nonce)
+	r := littleEndiaBytesToBigInt(clamp(bytes.Clone(k[0:16])))
+	s := littleEndiaBytesToBigInt(bytes.Clone(k[16:32]))
+	return &r, &s
+}
+
+func diffPow(a, b *big.Int, e int64, p *big.Int) *big.Int {
+	x := new(big.Int).Exp(a, big.NewInt(e), p)
+	y := new(big.Int).Exp(b, big.NewInt(e), p)
+	x.Sub(x, y)
+	return x.Mod(x, p)
+}
+
+func mulMod(a, b, p *big.Int) *big.Int {
+	x := new(big.Int).Mul(a, b)
+	return x.Mod(x, p)
+}
+
+func randomKey() [32]byte {
+	key := [32]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	return key
+}
@@ -0,0 +1,59 @@
+package chacha_test
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+// Without the feed-forward a ChaCha20 block is just the permutation of the
+// initial state, and the permutation runs backwards as easily as forwards:
+// one block of keystream is enough to read the key.
+func ExampleInvertPermutation() {
+	key := [32]byte{}
+	copy(key[:], "a key nobody should see, ever!!!")
+	nonce := [12]byte{0, 0, 0, 0x4a}
+
+	// a broken ChaCha20 that leaves out the feed-forward
+	state := chacha.State(key, 1, nonce)
+	chacha.Permutation{}.Permute(&state, 20)
+	block := make([]byte, 64)
+	for i, w := range state {
+		binary.LittleEndian.PutUint32(block[i*4:], w)
+	}
+
+	// the attacker only has the block
+	var output [16]uint32
+	for i := range output {
+		output[i] = binary.LittleEndian.Uint32(block[i*4:])
+	}
+
+	initial := chacha.InvertPermutation(output, 20)
+
+	// words 4 to 11 are the key, 12 the counter, 13 to 15 the nonce
+	recovered := make([]byte, 32)
+	for i := range 8 {
+		binary.LittleEndian.PutUint32(recovered[i*4:], initial[4+i])
+	}
+	// "expand 32-byte k" shows the inversion worked
+	sigma := [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+	fmt.Println("constants:", [4]uint32(initial[:4]) == sigma)
+	fmt.Printf("key: %q\n", recovered)
+	fmt.Printf("counter: %d, nonce: %08x\n", initial[12], initial[13:])
+
+	// with the feed-forward the output is the permutation plus the unknown
+	// initial state, so there is nothing to invert
+	original := chacha.Block(key, 1, nonce)
+	for i := range output {
+		output[i] = binary.LittleEndian.Uint32(original[i*4:])
+	}
+	initial = chacha.InvertPermutation(output, 20)
+	fmt.Println("constants of a real block:", [4]uint32(initial[:4]) == sigma)
+
+	// Output:
+	// constants: true
+	// key: "a key nobody should see, ever!!!"
+	// counter: 1, nonce: [4a000000 00000000 00000000]
+	// constants of a real block: false
+}
@@ -68,8 +68,9 @@ func poly1305Mac(msg []byte, key [32]byte) []byte {
 	a.Add(&a, &sn)
 	// fmt.Printf("Accumulator + s: %8x\n", a.Bytes())
 
-	b := make([]byte, len(a.Bytes()))
-	copy(b, a.Bytes())
+	// the tag is the lower 16 bytes, which can have leading zeros
+	b := make([]byte, max(len(a.Bytes()), 16))
+	a.FillBytes(b)
 	bigToLitleEndian(b)
 
 	return b[:16]
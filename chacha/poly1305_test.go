@@ -1,9 +1,13 @@
 package chacha
 
 import (
+	"bytes"
 	"fmt"
+	"math/rand/v2"
 	"slices"
 	"testing"
+
+	"golang.org/x/crypto/poly1305"
 )
 
 func TestPoly1305Mac(t *testing.T) {
@@ -25,6 +29,18 @@ func TestPoly1305Mac(t *testing.T) {
 	}
 }
 
+func TestPoly1305MacLeadingZeros(t *testing.T) {
+	// with r = 0 and s = 0 the accumulator is zero and so is the whole tag
+	key := [32]byte{}
+	expected := make([]byte, 16)
+
+	tag := poly1305Mac([]byte("Cryptographic Forum Research Group"), key)
+
+	if !slices.Equal(tag, expected) {
+		t.Errorf("Poly1305Mac tag: expected %s, tag %s", printBytes(expected), printBytes(tag))
+	}
+}
+
 func TestPoly1305KeyGen(t *testing.T) {
 	key := [32]byte{0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
 		0x88, 0x89, 0x8a, 0x8b, 0x8c, 0x8d, 0x8e, 0x8f,
@@ -58,3 +74,47 @@ func printBytes(b []byte) string {
 	s += "\n"
 	return s
 }
+
+// Keys and messages of all ones push every limb to its limit, so the
+// reductions modulo 2^130 - 5 and the final addition of s carry all the way.
+func TestPoly1305MacCarries(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 0))
+	saturated := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			switch rng.IntN(4) {
+			case 0:
+				b[i] = byte(rng.Uint32())
+			case 1:
+				b[i] = 0
+			default:
+				b[i] = 0xff
+			}
+		}
+		return b
+	}
+
+	var keys [][32]byte
+	keys = append(keys, [32]byte(bytes.Repeat([]byte{0xff}, 32)))
+	keys = append(keys, [32]byte(append(bytes.Repeat([]byte{0xff}, 16), make([]byte, 16)...)))
+	keys = append(keys, [32]byte(append(make([]byte, 16), bytes.Repeat([]byte{0xff}, 16)...)))
+	for i := 0; i < 200; i++ {
+		keys = append(keys, [32]byte(saturated(32)))
+	}
+
+	for _, key := range keys {
+		for _, size := range []int{0, 1, 15, 16, 17, 32, 48, 63, 64, 65, 128, 200} {
+			msg := saturated(size)
+			if key[0] == 0xff && key[31] == 0xff {
+				msg = bytes.Repeat([]byte{0xff}, size)
+			}
+
+			var expected [16]byte
+			poly1305.Sum(&expected, msg, &key)
+
+			if tag := poly1305Mac(msg, key); !slices.Equal(tag, expected[:]) {
+				t.Fatalf("Poly1305Mac(%x, %x): expected %x, got %x", msg, key, expected, tag)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package chacha
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// ChaCha20-Poly1305-SIV is a synthetic IV mode: the tag is a PRF of the nonce,
+// the aad and the message, and it is used as the ChaCha20 nonce. Repeating a
+// nonce only reveals whether the same message (and aad) was encrypted twice.
+//
+// sivKeys derives three subkeys from ChaCha20 blocks 0 and 1 of the key with
+// the "chacha20-siv" nonce:
+//   - hashKey (block 0, bytes 0-31): Poly1305 key used as a universal hash of
+//     aad and message.
+//   - prfKey: ChaCha20 key that turns the hash (xored with the nonce) into the
+//     tag. It is block 0, bytes 32-63 with a nonce and block 1, bytes 32-63 in
+//     the deterministic mode.
+//   - encKey (block 1, bytes 0-31): ChaCha20 key used to encrypt the message
+//     with the tag as nonce.
+//
+// The hash is not a MAC on its own, because its key is used for many
+// messages. Running it through the ChaCha20 block function makes it a PRF.
+// The deterministic mode has its own prfKey so its tags have nothing to do
+// with the ones of the all zero nonce.
+
+var sivLabel = [12]byte([]byte("chacha20-siv"))
+
+var errSIVNonce = errors.New("nonce must be 12 bytes or nil")
+
+// EncryptSIV encrypts message and authenticates it with aad. nonce is 12
+// bytes or nil, in which case the encryption is deterministic.
+func EncryptSIV(key [32]byte, nonce, message, aad []byte) ([]byte, []byte, error) {
+	hashKey, prfKey, encKey, err := sivKeys(key, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tag := sivTag(hashKey, prfKey, nonce, message, aad)
+	cipher := Encrypt(encKey, [12]byte(tag[:12]), message)
+
+	return cipher, tag, nil
+}
+
+// DecryptSIV is the inverse of EncryptSIV.
+func DecryptSIV(key [32]byte, nonce, cipher, tag, aad []byte) ([]byte, error) {
+	if len(tag) != 16 {
+		return nil, errors.New("invalid tag")
+	}
+
+	hashKey, prfKey, encKey, err := sivKeys(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	message := Encrypt(encKey, [12]byte(tag[:12]), cipher)
+	calculateTag := sivTag(hashKey, prfKey, nonce, message, aad)
+
+	if subtle.ConstantTimeCompare(calculateTag, tag) != 1 {
+		return nil, errors.New("invalid tag")
+	}
+
+	return message, nil
+}
+
+// sivKeys returns the hash, PRF and encryption keys, the PRF key depends on
+// whether there is a nonce.
+func sivKeys(key [32]byte, nonce []byte) ([32]byte, [32]byte, [32]byte, error) {
+	if nonce != nil && len(nonce) != 12 {
+		return [32]byte{}, [32]byte{}, [32]byte{}, errSIVNonce
+	}
+
+	first := Block(key, 0, sivLabel)
+	second := Block(key, 1, sivLabel)
+
+	prfKey := [32]byte(first[32:64])
+	if nonce == nil {
+		prfKey = [32]byte(second[32:64])
+	}
+
+	return [32]byte(first[0:32]), prfKey, [32]byte(second[0:32]), nil
+}
+
+// sivTag is the tag of message and aad, nonce is 12 bytes or nil.
+func sivTag(hashKey, prfKey [32]byte, nonce, message, aad []byte) []byte {
+	h := poly1305Mac(mac(message, aad), hashKey)
+
+	// like AES-GCM-SIV, the nonce is xored into the hash before the PRF
+	for i := range nonce {
+		h[4+i] ^= nonce[i]
+	}
+
+	stream := Block(prfKey, binary.LittleEndian.Uint32(h[0:4]), [12]byte(h[4:16]))
+
+	return stream[0:16]
+}
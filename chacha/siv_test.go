@@ -0,0 +1,291 @@
+package chacha
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/poly1305"
+)
+
+var sivKey = [32]byte{0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+	0x88, 0x89, 0x8a, 0x8b, 0x8c, 0x8d, 0x8e, 0x8f,
+	0x90, 0x91, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97,
+	0x98, 0x99, 0x9a, 0x9b, 0x9c, 0x9d, 0x9e, 0x9f}
+
+// The construction is this package's own, so there are no published vectors:
+// these are produced by this implementation, and checked against the
+// golang.org/x/crypto primitives by TestSIVReference. Key 80..9f and aad
+// 50 51 52 53 c0 c1 c2 c3 c4 c5 c6 c7 like RFC 8439 2.8.2.
+func TestEncryptSIV(t *testing.T) {
+	aad := []byte{0x50, 0x51, 0x52, 0x53, 0xc0, 0xc1, 0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7}
+
+	tests := []struct {
+		name string
+
+		nonce     string
+		plaintext string
+		cipher    string
+		tag       string
+	}{
+		{
+			name:      "deterministic, empty message",
+			plaintext: "",
+			cipher:    "",
+			tag:       "d13b4932c68ea2e0056d085bd6d017fe",
+		},
+		{
+			name:      "deterministic",
+			plaintext: "Ladies and Gentlemen of the class of '99",
+			cipher:    "ed2e414ed0b7f130ea6b92244b50711eeb06f86906bd7a92c65d5335d4154729314755871e03323a",
+			tag:       "4eb58129763825f8c6ca203607fa88e8",
+		},
+		{
+			name:      "nonce, empty message",
+			nonce:     "070000004041424344454647",
+			plaintext: "",
+			cipher:    "",
+			tag:       "02107f554a18a99a7d2dbbef3fc2a223",
+		},
+		{
+			name:      "nonce",
+			nonce:     "070000004041424344454647",
+			plaintext: "Ladies and Gentlemen of the class of '99",
+			cipher:    "41c6e33f2c891a73e3c67efd62bf7a8520fb5af5d14b8720018dab68dec8e05332121468d7d79b57",
+			tag:       "83f5ac8ca528d821e31846d74cb8980b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nonce []byte
+			if tt.nonce != "" {
+				nonce = decodeHex(tt.nonce)
+			}
+
+			cipher, tag, err := EncryptSIV(sivKey, nonce, []byte(tt.plaintext), aad)
+			if err != nil {
+				t.Fatalf("EncryptSIV: %s", err)
+			}
+
+			if hex.EncodeToString(cipher) != tt.cipher {
+				t.Errorf("EncryptSIV cipher: Expected %s, got %x", tt.cipher, cipher)
+			}
+
+			if hex.EncodeToString(tag) != tt.tag {
+				t.Errorf("EncryptSIV tag: Expected %s, got %x", tt.tag, tag)
+			}
+
+			message, err := DecryptSIV(sivKey, nonce, cipher, tag, aad)
+			if err != nil {
+				t.Errorf("DecryptSIV: %s", err)
+			}
+
+			if !slices.Equal(message, []byte(tt.plaintext)) {
+				t.Errorf("DecryptSIV: Expected %s, got %s", tt.plaintext, message)
+			}
+		})
+	}
+}
+
+func TestDecryptSIVTampered(t *testing.T) {
+	nonce := decodeHex("070000004041424344454647")
+	aad := []byte("header")
+
+	cipher, tag, err := EncryptSIV(sivKey, nonce, []byte("attack at dawn"), aad)
+	if err != nil {
+		t.Fatalf("EncryptSIV: %s", err)
+	}
+
+	tampered := bytes.Clone(cipher)
+	tampered[0] ^= 1
+	if _, err := DecryptSIV(sivKey, nonce, tampered, tag, aad); err == nil {
+		t.Errorf("DecryptSIV accepted a tampered cipher")
+	}
+
+	tamperedTag := bytes.Clone(tag)
+	tamperedTag[15] ^= 1
+	if _, err := DecryptSIV(sivKey, nonce, cipher, tamperedTag, aad); err == nil {
+		t.Errorf("DecryptSIV accepted a tampered tag")
+	}
+
+	if _, err := DecryptSIV(sivKey, nonce, cipher, tag, []byte("other")); err == nil {
+		t.Errorf("DecryptSIV accepted a different aad")
+	}
+
+	if _, err := DecryptSIV(sivKey, nil, cipher, tag, aad); err == nil {
+		t.Errorf("DecryptSIV accepted a different nonce")
+	}
+
+	if _, err := DecryptSIV(sivKey, nonce, cipher, tag[:8], aad); err == nil {
+		t.Errorf("DecryptSIV accepted a short tag")
+	}
+
+	if _, err := DecryptSIV(sivKey, nonce[:8], cipher, tag, aad); err == nil {
+		t.Errorf("DecryptSIV accepted a short nonce")
+	}
+}
+
+// A nil nonce and the all zero nonce are different modes and must not give
+// the same tag.
+func TestSIVNonce(t *testing.T) {
+	message := []byte("attack at dawn")
+
+	_, det, err := EncryptSIV(sivKey, nil, message, nil)
+	if err != nil {
+		t.Fatalf("EncryptSIV: %s", err)
+	}
+	_, zero, err := EncryptSIV(sivKey, make([]byte, 12), message, nil)
+	if err != nil {
+		t.Fatalf("EncryptSIV: %s", err)
+	}
+	if slices.Equal(det, zero) {
+		t.Errorf("EncryptSIV: the nil and the zero nonce give the same tag %x", det)
+	}
+
+	for _, nonce := range [][]byte{{}, make([]byte, 8), make([]byte, 24)} {
+		if _, _, err := EncryptSIV(sivKey, nonce, message, nil); err == nil {
+			t.Errorf("EncryptSIV accepted a %d byte nonce", len(nonce))
+		}
+	}
+}
+
+// referenceSIV is EncryptSIV built out of golang.org/x/crypto.
+func referenceSIV(key [32]byte, nonce, message, aad []byte) ([]byte, []byte) {
+	block := func(key []byte, counter uint32, nonce []byte) []byte {
+		c, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+		if err != nil {
+			panic(err)
+		}
+		c.SetCounter(counter)
+		out := make([]byte, 64)
+		c.XORKeyStream(out, out)
+		return out
+	}
+
+	first := block(key[:], 0, sivLabel[:])
+	second := block(key[:], 1, sivLabel[:])
+	hashKey, prfKey, encKey := first[:32], first[32:], second[:32]
+	if nonce == nil {
+		prfKey = second[32:]
+	}
+
+	var input []byte
+	input = append(input, aad...)
+	input = append(input, make([]byte, (16-len(aad)%16)%16)...)
+	input = append(input, message...)
+	input = append(input, make([]byte, (16-len(message)%16)%16)...)
+	input = binary.LittleEndian.AppendUint64(input, uint64(len(aad)))
+	input = binary.LittleEndian.AppendUint64(input, uint64(len(message)))
+
+	var h [16]byte
+	poly1305.Sum(&h, input, (*[32]byte)(hashKey))
+	for i := range nonce {
+		h[4+i] ^= nonce[i]
+	}
+	tag := block(prfKey, binary.LittleEndian.Uint32(h[:4]), h[4:])[:16]
+
+	c, err := chacha20.NewUnauthenticatedCipher(encKey, tag[:12])
+	if err != nil {
+		panic(err)
+	}
+	c.SetCounter(1)
+	cipher := make([]byte, len(message))
+	c.XORKeyStream(cipher, message)
+
+	return cipher, tag
+}
+
+func TestSIVReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 64; i++ {
+		var key [32]byte
+		nonce := make([]byte, 12)
+		message := make([]byte, rng.IntN(200))
+		aad := make([]byte, rng.IntN(40))
+		for _, b := range [][]byte{key[:], nonce, message, aad} {
+			for j := range b {
+				b[j] = byte(rng.Uint32())
+			}
+		}
+		if i%2 == 0 {
+			nonce = nil
+		}
+
+		cipher, tag, err := EncryptSIV(key, nonce, message, aad)
+		if err != nil {
+			t.Fatalf("EncryptSIV: %s", err)
+		}
+
+		expectedCipher, expectedTag := referenceSIV(key, nonce, message, aad)
+		if !slices.Equal(cipher, expectedCipher) || !slices.Equal(tag, expectedTag) {
+			t.Errorf("EncryptSIV(%x, %x): got %x %x, want %x %x", key, nonce, cipher, tag, expectedCipher, expectedTag)
+		}
+	}
+}
+
+// With EncryptAED a repeated nonce gives away the xor of the plaintexts,
+// with EncryptSIV it does not.
+func TestNonceReuse(t *testing.T) {
+	nonce := [12]byte{0x07, 0x00, 0x00, 0x00, 0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47}
+
+	m1 := []byte("transfer 100 to alice")
+	m2 := []byte("transfer 999 to mallo")
+
+	plainXor := xorBytes(m1, m2)
+
+	c1, _ := EncryptAED(sivKey, nonce, m1, nil)
+	c2, _ := EncryptAED(sivKey, nonce, m2, nil)
+
+	if !slices.Equal(xorBytes(c1, c2), plainXor) {
+		t.Errorf("EncryptAED: expected the ciphertexts to leak the xor of the plaintexts")
+	}
+
+	s1, t1, _ := EncryptSIV(sivKey, nonce[:], m1, nil)
+	s2, t2, _ := EncryptSIV(sivKey, nonce[:], m2, nil)
+
+	if slices.Equal(xorBytes(s1, s2), plainXor) {
+		t.Errorf("EncryptSIV: ciphertexts leak the xor of the plaintexts")
+	}
+
+	if slices.Equal(t1, t2) {
+		t.Errorf("EncryptSIV: different messages got the same tag")
+	}
+
+	// the only thing a repeated nonce leaks is that the same message was sent again
+	s3, t3, _ := EncryptSIV(sivKey, nonce[:], m1, nil)
+	if !slices.Equal(s1, s3) || !slices.Equal(t1, t3) {
+		t.Errorf("EncryptSIV: same message, nonce and aad should encrypt the same")
+	}
+
+	for _, c := range []struct{ cipher, tag, expected []byte }{{s1, t1, m1}, {s2, t2, m2}} {
+		message, err := DecryptSIV(sivKey, nonce[:], c.cipher, c.tag, nil)
+		if err != nil {
+			t.Errorf("DecryptSIV: %s", err)
+		}
+		if !slices.Equal(message, c.expected) {
+			t.Errorf("DecryptSIV: Expected %s, got %s", c.expected, message)
+		}
+	}
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,268 @@
+package chacha
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AEAD test vectors from Project Wycheproof (https://github.com/C2SP/wycheproof),
+// vendored in testdata/wycheproof. testdata/aead_edge_cases.json is not from
+// Wycheproof: it has local edge cases generated with golang.org/x/crypto,
+// stored in the same JSON layout so the same code runs them (go test -run
+// TestAEADEdgeCases -update regenerates it).
+
+var update = flag.Bool("update", false, "regenerate testdata/aead_edge_cases.json with golang.org/x/crypto")
+
+type vectorFile struct {
+	Algorithm     string        `json:"algorithm"`
+	Header        []string      `json:"header,omitempty"`
+	NumberOfTests int           `json:"numberOfTests"`
+	TestGroups    []vectorGroup `json:"testGroups"`
+}
+
+type vectorGroup struct {
+	Algorithm string       `json:"algorithm,omitempty"`
+	IvSize    int          `json:"ivSize"`
+	KeySize   int          `json:"keySize"`
+	TagSize   int          `json:"tagSize"`
+	Tests     []vectorTest `json:"tests"`
+}
+
+type vectorTest struct {
+	TcID    int      `json:"tcId"`
+	Comment string   `json:"comment"`
+	Flags   []string `json:"flags,omitempty"`
+	Key     string   `json:"key"`
+	Iv      string   `json:"iv"`
+	Aad     string   `json:"aad"`
+	Msg     string   `json:"msg"`
+	Ct      string   `json:"ct"`
+	Tag     string   `json:"tag"`
+	// Result is valid, invalid or acceptable.
+	Result string `json:"result"`
+}
+
+func readVectors(t *testing.T, name string) vectorFile {
+	t.Helper()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var f vectorFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	return f
+}
+
+func unhex(t *testing.T, tc vectorTest, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("tcId %d: %s", tc.TcID, err)
+	}
+	return b
+}
+
+// runVectors runs every test of f through EncryptAED and DecryptAED, or their
+// X versions. Valid tests must encrypt to their ciphertext and tag and
+// decrypt back; invalid ones must not decrypt. Nonces of the wrong size can't
+// even be passed in.
+func runVectors(t *testing.T, f vectorFile) int {
+	var n int
+
+	for _, g := range f.TestGroups {
+		algorithm := f.Algorithm
+		if g.Algorithm != "" {
+			algorithm = g.Algorithm
+		}
+
+		var nonceSize int
+		switch algorithm {
+		case "CHACHA20-POLY1305":
+			nonceSize = 12
+		case "XCHACHA20-POLY1305":
+			nonceSize = 24
+		default:
+			t.Fatalf("unknown algorithm %s", algorithm)
+		}
+
+		for _, tc := range g.Tests {
+			n++
+			key, iv, aad := unhex(t, tc, tc.Key), unhex(t, tc, tc.Iv), unhex(t, tc, tc.Aad)
+			msg, ct, tag := unhex(t, tc, tc.Msg), unhex(t, tc, tc.Ct), unhex(t, tc, tc.Tag)
+
+			if len(key) != 32 || len(iv) != nonceSize {
+				if tc.Result == "valid" {
+					t.Errorf("%s tcId %d: valid with a %d byte key and a %d byte nonce", algorithm, tc.TcID, len(key), len(iv))
+				}
+				continue
+			}
+
+			var cipher, tag2, plain []byte
+			var err error
+			if nonceSize == 12 {
+				cipher, tag2 = EncryptAED([32]byte(key), [12]byte(iv), msg, aad)
+				plain, err = DecryptAED([32]byte(key), [12]byte(iv), ct, tag, aad)
+			} else {
+				cipher, tag2 = EncryptXAED([32]byte(key), [24]byte(iv), msg, aad)
+				plain, err = DecryptXAED([32]byte(key), [24]byte(iv), ct, tag, aad)
+			}
+
+			switch tc.Result {
+			case "valid":
+				if !slices.Equal(cipher, ct) || !slices.Equal(tag2, tag) {
+					t.Errorf("%s tcId %d (%s): encrypted to %x %x, expected %x %x", algorithm, tc.TcID, tc.Comment, cipher, tag2, ct, tag)
+				}
+				if err != nil || !slices.Equal(plain, msg) {
+					t.Errorf("%s tcId %d (%s): decrypted to %x, %v", algorithm, tc.TcID, tc.Comment, plain, err)
+				}
+			case "invalid":
+				if err == nil {
+					t.Errorf("%s tcId %d (%s): decrypted an invalid ciphertext", algorithm, tc.TcID, tc.Comment)
+				}
+			}
+		}
+	}
+
+	return n
+}
+
+func TestWycheproof(t *testing.T) {
+	files, _ := filepath.Glob("testdata/wycheproof/*.json")
+	if len(files) != 2 {
+		t.Fatalf("expected the ChaCha20 and XChaCha20 files in testdata/wycheproof, found %v", files)
+	}
+
+	for _, name := range files {
+		f := readVectors(t, name)
+		if n := runVectors(t, f); n != f.NumberOfTests {
+			t.Errorf("%s: ran %d tests of %d", name, n, f.NumberOfTests)
+		}
+	}
+}
+
+func TestAEADEdgeCases(t *testing.T) {
+	const name = "testdata/aead_edge_cases.json"
+	if *update {
+		generateVectors(t, name)
+	}
+
+	f := readVectors(t, name)
+	if n := runVectors(t, f); n != f.NumberOfTests || n == 0 {
+		t.Errorf("%s: ran %d tests of %d", name, n, f.NumberOfTests)
+	}
+}
+
+// generateVectors writes edge cases encrypted with golang.org/x/crypto:
+// lengths around the 16 byte Poly1305 blocks and the 64 byte ChaCha blocks,
+// tags that end in zero bytes, the high bytes of the Poly1305 accumulator,
+// and modified tags, ciphertexts and additional data.
+func generateVectors(t *testing.T, name string) {
+	rng := rand.New(rand.NewPCG(49, 0))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(rng.Uint32())
+		}
+		return b
+	}
+
+	f := vectorFile{
+		Algorithm: "CHACHA20-POLY1305",
+		Header: []string{
+			"Local edge cases, not Project Wycheproof vectors.",
+			"Generated with golang.org/x/crypto/chacha20poly1305, go test -run TestAEADEdgeCases -update",
+		},
+	}
+
+	for _, nonceSize := range []int{12, 24} {
+		g := vectorGroup{Algorithm: "CHACHA20-POLY1305", IvSize: 96, KeySize: 256, TagSize: 128}
+		newAEAD := chacha20poly1305.New
+		if nonceSize == 24 {
+			g = vectorGroup{Algorithm: "XCHACHA20-POLY1305", IvSize: 192, KeySize: 256, TagSize: 128}
+			newAEAD = chacha20poly1305.NewX
+		}
+
+		add := func(comment string, key, iv, aad, msg, ct, tag []byte, result string) {
+			g.Tests = append(g.Tests, vectorTest{
+				TcID: len(f.TestGroups)*1000 + len(g.Tests) + 1, Comment: comment,
+				Key: hex.EncodeToString(key), Iv: hex.EncodeToString(iv), Aad: hex.EncodeToString(aad),
+				Msg: hex.EncodeToString(msg), Ct: hex.EncodeToString(ct), Tag: hex.EncodeToString(tag),
+				Result: result,
+			})
+		}
+		seal := func(key, iv, aad, msg []byte) ([]byte, []byte) {
+			aead, err := newAEAD(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sealed := aead.Seal(nil, iv, msg, aad)
+			return sealed[:len(msg)], sealed[len(msg):]
+		}
+
+		aadSizes := []int{0, 1, 12, 16, 17}
+		for i, size := range []int{0, 1, 15, 16, 17, 31, 32, 33, 63, 64, 65, 127, 128, 129, 255, 256, 257, 1024} {
+			key, iv, aad, msg := random(32), random(nonceSize), random(aadSizes[i%len(aadSizes)]), random(size)
+			ct, tag := seal(key, iv, aad, msg)
+			add("message and additional data lengths", key, iv, aad, msg, ct, tag, "valid")
+		}
+
+		key, iv, msg := make([]byte, 32), make([]byte, nonceSize), []byte{}
+		ct, tag := seal(key, iv, nil, msg)
+		add("zero key and nonce, empty message", key, iv, nil, msg, ct, tag, "valid")
+
+		// tags whose last bytes, the most significant of the accumulator, are 0
+		for zeros, found := 1, 0; zeros <= 2; {
+			key, iv, msg := random(32), random(nonceSize), random(16)
+			ct, tag := seal(key, iv, nil, msg)
+			if !slices.Equal(tag[16-zeros:], make([]byte, zeros)) {
+				continue
+			}
+			add("tag ending in zero bytes", key, iv, nil, msg, ct, tag, "valid")
+			if found++; found == 2 {
+				zeros, found = zeros+1, 0
+			}
+		}
+
+		key, iv, aad, msg := random(32), random(nonceSize), random(12), random(40)
+		ct, tag = seal(key, iv, aad, msg)
+		flip := func(b []byte, i int, bit byte) []byte {
+			b = slices.Clone(b)
+			b[i] ^= bit
+			return b
+		}
+
+		add("first tag bit flipped", key, iv, aad, msg, ct, flip(tag, 0, 1), "invalid")
+		add("last tag bit flipped", key, iv, aad, msg, ct, flip(tag, 15, 0x80), "invalid")
+		add("tag of zeros", key, iv, aad, msg, ct, make([]byte, 16), "invalid")
+		add("truncated tag", key, iv, aad, msg, ct, tag[:15], "invalid")
+		add("tag with an extra byte", key, iv, aad, msg, ct, append(slices.Clone(tag), 0), "invalid")
+		add("ciphertext bit flipped", key, iv, aad, msg, flip(ct, 20, 4), tag, "invalid")
+		add("truncated ciphertext", key, iv, aad, msg[:39], ct[:39], tag, "invalid")
+		add("additional data bit flipped", key, iv, flip(aad, 3, 2), msg, ct, tag, "invalid")
+		add("nonce bit flipped", key, flip(iv, 0, 1), aad, msg, ct, tag, "invalid")
+
+		f.TestGroups = append(f.TestGroups, g)
+		f.NumberOfTests += len(g.Tests)
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, append(data, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
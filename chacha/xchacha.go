@@ -0,0 +1,47 @@
+package chacha
+
+// XChaCha20-Poly1305 extends the nonce to 24 bytes so it can be picked at
+// random. HChaCha20 derives a subkey from the key and the first 16 bytes of
+// the nonce, and the last 8 bytes are used as a regular nonce with that key.
+// Spec: https://datatracker.ietf.org/doc/html/draft-irtf-cfrg-xchacha
+
+// EncryptXAED is EncryptAED with a 24 byte nonce.
+func EncryptXAED(key [32]byte, nonce [24]byte, message, aad []byte) ([]byte, []byte) {
+	subKey, subNonce := xchachaKeys(key, nonce)
+
+	return EncryptAED(subKey, subNonce, message, aad)
+}
+
+// DecryptXAED is the inverse of EncryptXAED.
+func DecryptXAED(key [32]byte, nonce [24]byte, cipher, tag, aad []byte) ([]byte, error) {
+	subKey, subNonce := xchachaKeys(key, nonce)
+
+	return DecryptAED(subKey, subNonce, cipher, tag, aad)
+}
+
+func xchachaKeys(key [32]byte, nonce [24]byte) ([32]byte, [12]byte) {
+	subKey := hChaCha20(key, [16]byte(nonce[0:16]))
+
+	var subNonce [12]byte
+	copy(subNonce[4:12], nonce[16:24])
+
+	return subKey, subNonce
+}
+
+// hChaCha20 runs the ChaCha20 rounds over a state where the 16 byte nonce
+// takes the place of counter and nonce. There is no feed-forward, the output
+// is the first and last rows of the state.
+func hChaCha20(key [32]byte, nonce [16]byte) [32]byte {
+	counter := bytesToWords(nonce[0:4])[0]
+	state := initState(key, counter, [12]byte(nonce[4:16]))
+
+	for i := 0; i < 10; i++ {
+		innerBlock(state)
+	}
+
+	// a fresh slice, appending to state[0:4] would overwrite state[4:8]
+	out := make([]uint32, 0, 8)
+	out = append(out, state[0:4]...)
+	out = append(out, state[12:16]...)
+	return [32]byte(wordsToBytes(out))
+}
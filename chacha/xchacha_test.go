@@ -0,0 +1,82 @@
+package chacha
+
+import (
+	"crypto/rand"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Test got from https://datatracker.ietf.org/doc/html/draft-irtf-cfrg-xchacha#section-2.2.1
+// and checked against Go's implementation.
+func TestHChaCha20(t *testing.T) {
+	key := [32]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+
+	nonce := [16]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x4a,
+		0x00, 0x00, 0x00, 0x00, 0x31, 0x41, 0x59, 0x27}
+
+	expected := [32]byte{0x82, 0x41, 0x3b, 0x42, 0x27, 0xb2, 0x7b, 0xfe,
+		0xd3, 0x0e, 0x42, 0x50, 0x8a, 0x87, 0x7d, 0x73,
+		0xa0, 0xf9, 0xe4, 0xd5, 0x8a, 0x74, 0xa8, 0x53,
+		0xc1, 0x2e, 0xc4, 0x13, 0x26, 0xd3, 0xec, 0xdc}
+
+	out := hChaCha20(key, nonce)
+
+	if out != expected {
+		t.Errorf("HChaCha20: expected %s, got %s", printBytes(expected[:]), printBytes(out[:]))
+	}
+
+	std, err := chacha20.HChaCha20(key[:], nonce[:])
+	if err != nil {
+		t.Fatalf("chacha20.HChaCha20: %s", err)
+	}
+
+	if !slices.Equal(out[:], std) {
+		t.Errorf("HChaCha20: Go's implementation got %s", printBytes(std))
+	}
+}
+
+func TestEncryptXAED(t *testing.T) {
+	key := [32]byte{}
+	nonce := [24]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		panic(err)
+	}
+
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	aad := []byte{0x50, 0x51, 0x52, 0x53, 0xc0, 0xc1, 0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7}
+
+	cipher, tag := EncryptXAED(key, nonce, plaintext, aad)
+
+	// check if my implementation and Go's implementation encrypt the same
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		t.Fatalf("chacha20poly1305.NewX: %s", err)
+	}
+	std := aead.Seal(nil, nonce[:], plaintext, aad)
+
+	if !slices.Equal(append(cipher, tag...), std) {
+		t.Errorf("EncryptXAED: Expected %x, got %x%x", std, cipher, tag)
+	}
+
+	message, err := DecryptXAED(key, nonce, cipher, tag, aad)
+	if err != nil {
+		t.Errorf("DecryptXAED: %s", err)
+	}
+	if !slices.Equal(message, plaintext) {
+		t.Errorf("DecryptXAED: Expected %s, got %s", plaintext, message)
+	}
+
+	nonce[23] ^= 1
+	if _, err := DecryptXAED(key, nonce, cipher, tag, aad); err == nil {
+		t.Errorf("DecryptXAED accepted a different nonce")
+	}
+}
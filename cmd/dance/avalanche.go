@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/analysis/avalanche"
+)
+
+// avalancheCommand prints the SAC score per round count and optionally writes
+// a heatmap for each of them.
+func avalancheCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance avalanche", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cipher := fs.String("cipher", "chacha", "chacha, salsa or a toy with 4, 8 or 16 bit words like chacha8")
+	part := fs.String("input", "all", "input bits to flip: key, nonce, counter or all")
+	rounds := fs.String("rounds", "1-20", "round count or range of round counts, like 4 or 1-8")
+	samples := fs.Int("samples", 64, "random inputs per round count")
+	seed := fs.Uint64("seed", 1, "seed of the random inputs")
+	dir := fs.String("heatmap", "", "directory to write one heatmap per round count")
+	format := fs.String("format", "png", "heatmap format: png or csv")
+	scale := fs.Int("scale", 1, "pixels per bit in png heatmaps")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	c, err := analysis.Lookup(*cipher)
+	if err != nil {
+		return errUsage{err}
+	}
+
+	minRounds, maxRounds, err := parseRounds(*rounds)
+	if err != nil {
+		return err
+	}
+
+	if *samples < 1 || *scale < 1 {
+		return usageError("samples and scale must be positive")
+	}
+	if *format != "png" && *format != "csv" {
+		return usageError("unknown heatmap format %q", *format)
+	}
+
+	results, err := avalanche.Sweep(c, analysis.Part(*part), minRounds, maxRounds, *samples, *seed)
+	if err != nil {
+		return errUsage{err}
+	}
+
+	if err := avalanche.WriteSummaryCSV(stdout, results); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return nil
+	}
+
+	for _, r := range results {
+		name := filepath.Join(*dir, fmt.Sprintf("%s-%s-r%02d.%s", r.Cipher, r.Part, r.Rounds, *format))
+		if err := writeHeatmap(name, r, *format, *scale); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeHeatmap(name string, r *avalanche.Result, format string, scale int) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if format == "csv" {
+		err = r.WriteCSV(f)
+	} else {
+		err = r.WritePNG(f, scale)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// parseRounds accepts a single round count or a range like 1-8.
+func parseRounds(s string) (int, int, error) {
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		to = from
+	}
+
+	lo, err1 := strconv.Atoi(from)
+	hi, err2 := strconv.Atoi(to)
+	if err1 != nil || err2 != nil || lo < 0 || hi < lo {
+		return 0, 0, usageError("invalid rounds %q, use a number or a range like 1-8", s)
+	}
+
+	return lo, hi, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAvalanche(t *testing.T) {
+	dir := t.TempDir()
+
+	out, code := dance(t, nil, "avalanche", "-cipher", "salsa", "-input", "counter", "-rounds", "1-2", "-samples", "4", "-heatmap", dir)
+	if code != exitOK {
+		t.Fatalf("avalanche: exit code %d", code)
+	}
+
+	lines, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil || len(lines) != 3 {
+		t.Errorf("avalanche: expected header and 2 rounds, got %v (%v)", lines, err)
+	}
+
+	for _, name := range []string{"salsa-counter-r01.png", "salsa-counter-r02.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("avalanche: %s", err)
+		}
+	}
+
+	// the toys work as well
+	out, code = dance(t, nil, "avalanche", "-cipher", "chacha4", "-rounds", "20", "-samples", "4")
+	if lines, err := csv.NewReader(bytes.NewReader(out)).ReadAll(); code != exitOK || err != nil || len(lines) != 2 {
+		t.Errorf("avalanche -cipher chacha4: exit code %d, %v (%v)", code, lines, err)
+	}
+
+	for _, args := range [][]string{
+		{"avalanche", "-cipher", "rumba"},
+		{"avalanche", "-rounds", "8-1"},
+		{"avalanche", "-input", "iv", "-rounds", "1"},
+		{"avalanche", "-format", "gif"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
+
+func TestParseRounds(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		lo, hi int
+	}{{"4", 4, 4}, {"1-8", 1, 8}, {"0-0", 0, 0}} {
+		lo, hi, err := parseRounds(tt.input)
+		if err != nil || lo != tt.lo || hi != tt.hi {
+			t.Errorf("parseRounds(%s) = %d, %d (%v), want %d, %d", tt.input, lo, hi, err, tt.lo, tt.hi)
+		}
+	}
+
+	for _, input := range []string{"", "a", "3-", "-3", "5-2"} {
+		if _, _, err := parseRounds(input); err == nil {
+			t.Errorf("parseRounds(%q) accepted", input)
+		}
+	}
+}
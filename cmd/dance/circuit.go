@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/mario-areias/latin-dances-go/analysis/circuit"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// circuitCommand writes the bit-level circuit of the ChaCha block or Salsa
+// hash function, as ANF or DIMACS CNF, after checking it against the real
+// function.
+func circuitCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance circuit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cipher := fs.String("cipher", "chacha", "chacha or salsa")
+	rounds := fs.Int("rounds", 4, "rounds")
+	format := fs.String("format", "cnf", "anf or cnf")
+	noFeedForward := fs.Bool("no-feed-forward", false, "leave out the feed-forward")
+	samples := fs.Int("check", 16, "random inputs to check the circuit with before writing it")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+	if *format != "anf" && *format != "cnf" {
+		return usageError("unknown format %q", *format)
+	}
+	if *rounds < 0 || *rounds > 20 {
+		return usageError("rounds must be between 0 and 20")
+	}
+
+	var c *circuit.Circuit
+	switch *cipher {
+	case "chacha":
+		d := chacha.Design()
+		c = circuit.New(&d, circuit.ChaChaQuarterRound, *rounds, !*noFeedForward)
+	case "salsa":
+		d := salsa.Design()
+		c = circuit.New(&d, circuit.SalsaQuarterRound, *rounds, !*noFeedForward)
+	default:
+		return usageError("unknown cipher %q", *cipher)
+	}
+
+	if err := circuit.Check(c, *samples, 1); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "%s: %d gates, checked on %d random inputs\n", c.Name, len(c.Gates)-2, *samples)
+
+	if *format == "anf" {
+		return c.WriteANF(stdout)
+	}
+	return c.WriteCNF(stdout)
+}
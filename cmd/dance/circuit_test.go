@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/analysis/circuit"
+)
+
+func TestCircuit(t *testing.T) {
+	out, code := dance(t, nil, "circuit", "-cipher", "salsa", "-rounds", "2", "-format", "anf")
+	if code != exitOK {
+		t.Fatalf("circuit: exit code %d", code)
+	}
+	c, err := circuit.ReadANF(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "salsa, 2 rounds" || len(c.Outputs) != 512 {
+		t.Errorf("circuit: %q with %d outputs", c.Name, len(c.Outputs))
+	}
+
+	out, code = dance(t, nil, "circuit", "-rounds", "1", "-no-feed-forward")
+	if code != exitOK || !strings.HasPrefix(string(out), "c chacha, 1 rounds, no feed-forward\n") {
+		t.Errorf("circuit: exit code %d, output starts with %.40q", code, out)
+	}
+
+	for _, args := range [][]string{
+		{"circuit", "-cipher", "rumba"},
+		{"circuit", "-format", "aig"},
+		{"circuit", "-rounds", "21"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mario-areias/latin-dances-go/attacks/crib"
+)
+
+const cribHelp = `commands:
+  drag CRIB           try CRIB at every position of every text, best first
+  place N POS TEXT    text N reads TEXT from position POS on
+  guess               fill the unknown bytes with the likeliest English
+  show                print the texts recovered so far
+  xor                 print text 1 XOR text 2 in hex
+  help                print this
+  quit
+`
+
+// cribCommand attacks ciphertexts encrypted with the same key and nonce by
+// crib dragging, reading commands from stdin.
+func cribCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance crib", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	isHex := fs.Bool("hex", false, "the files have the ciphertexts in hex")
+	top := fs.Int("top", 10, "candidates drag prints")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: dance crib [-hex] [-top n] cipher1 cipher2 [cipher...]")
+		fs.PrintDefaults()
+		fmt.Fprint(stderr, "\n", cribHelp)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() < 2 {
+		return usageError("need at least two ciphertexts encrypted with the same key and nonce")
+	}
+
+	var ciphertexts [][]byte
+	for _, name := range fs.Args() {
+		c, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		if *isHex {
+			c, err = hex.DecodeString(strings.TrimSpace(string(c)))
+			if err != nil {
+				return usageError("%s: %s", name, err)
+			}
+		}
+		ciphertexts = append(ciphertexts, c)
+	}
+
+	a := crib.New(ciphertexts...)
+	lines := bufio.NewScanner(stdin)
+
+	for fmt.Fprint(stderr, "> "); lines.Scan(); fmt.Fprint(stderr, "> ") {
+		command, rest, _ := strings.Cut(lines.Text(), " ")
+
+		switch command {
+		case "drag":
+			if rest == "" {
+				fmt.Fprintln(stdout, "usage: drag CRIB")
+				continue
+			}
+
+			for i, c := range a.Drag([]byte(rest)) {
+				if i == *top {
+					break
+				}
+
+				fmt.Fprintf(stdout, "%7.3f  text %d at %d:", c.Score, c.Text+1, c.Position)
+				for j, other := range c.Others {
+					if other != nil {
+						fmt.Fprintf(stdout, "  %d %q", j+1, printable(other))
+					}
+				}
+				fmt.Fprintln(stdout)
+			}
+
+		case "place":
+			fields := strings.SplitN(rest, " ", 3)
+			if len(fields) < 3 {
+				fmt.Fprintln(stdout, "usage: place N POS TEXT")
+				continue
+			}
+
+			n, err1 := strconv.Atoi(fields[0])
+			pos, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil {
+				fmt.Fprintln(stdout, "usage: place N POS TEXT")
+				continue
+			}
+
+			if err := a.Place(n-1, pos, []byte(fields[2])); err != nil {
+				fmt.Fprintln(stdout, err)
+				continue
+			}
+			show(stdout, a)
+
+		case "guess":
+			a.Guess()
+			show(stdout, a)
+
+		case "show":
+			show(stdout, a)
+
+		case "xor":
+			fmt.Fprintln(stdout, hex.EncodeToString(crib.XOR(ciphertexts[0], ciphertexts[1])))
+
+		case "help":
+			fmt.Fprint(stdout, cribHelp)
+
+		case "quit":
+			return nil
+
+		case "":
+
+		default:
+			fmt.Fprintf(stdout, "unknown command %q\n", command)
+		}
+	}
+
+	return lines.Err()
+}
+
+func show(w io.Writer, a *crib.Attack) {
+	for i := range a.Ciphertexts {
+		fmt.Fprintf(w, "%d  %s\n", i+1, printable(a.Plaintext(i, '_')))
+	}
+}
+
+// printable replaces the bytes that would garble the terminal with dots.
+func printable(b []byte) string {
+	out := bytes.Clone(b)
+	for i, c := range out {
+		if c < ' ' || c > '~' {
+			out[i] = '.'
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+func TestCrib(t *testing.T) {
+	key := [32]byte{1}
+	nonce := [12]byte{2}
+	dir := t.TempDir()
+
+	var files []string
+	for i, text := range []string{
+		"Meet me at the old bridge at midnight.",
+		"The attack starts at dawn, be ready.",
+	} {
+		name := filepath.Join(dir, string(rune('a'+i)))
+		c := chacha.Encrypt(key, nonce, []byte(text))
+		if err := os.WriteFile(name, []byte(hex.EncodeToString(c)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+
+	script := "drag  the \nplace 1 10  the \nplace 2 0 The attack starts at dawn, be ready.\nquit\n"
+	out, code := dance(t, []byte(script), append([]string{"crib", "-hex", "-top", "1"}, files...)...)
+	if code != exitOK {
+		t.Fatalf("crib: exit code %d", code)
+	}
+
+	for _, expected := range []string{
+		`text 1 at 10:  2 " star"`,
+		"2  __________ star_____________________",
+		// text 2 is too short for the end of text 1
+		"1  Meet me at the old bridge at midnigh__",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("crib: no %q in\n%s", expected, out)
+		}
+	}
+
+	if _, code := dance(t, nil, "crib", files[0]); code != exitUsage {
+		t.Errorf("crib with one file: expected exit code %d, got %d", exitUsage, code)
+	}
+	if _, code := dance(t, nil, "crib", files[0], filepath.Join(dir, "missing")); code != exitIO {
+		t.Errorf("crib with a missing file: expected exit code %d, got %d", exitIO, code)
+	}
+}
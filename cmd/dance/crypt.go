@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+
+	"github.com/mario-areias/latin-dances-go/container"
+)
+
+type cryptFlags struct {
+	keyFile string
+	kdf     string
+}
+
+func parseCryptFlags(name string, args []string, stderr io.Writer, withKDF bool) (*cryptFlags, error) {
+	f := &cryptFlags{}
+
+	fs := flag.NewFlagSet("dance "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&f.keyFile, "key-file", "", "file with a 32 byte key in hex or base64, instead of a passphrase")
+	if withKDF {
+		fs.StringVar(&f.kdf, "kdf", "scrypt", "passphrase KDF: scrypt or argon2id")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return nil, usageError("unexpected arguments %v, data is read from stdin", fs.Args())
+	}
+
+	return f, nil
+}
+
+func (f *cryptFlags) params() (container.Params, error) {
+	if f.keyFile != "" {
+		return container.RawKeyParams, nil
+	}
+
+	switch f.kdf {
+	case "scrypt":
+		return container.DefaultParams, nil
+	case "argon2id":
+		return container.DefaultArgon2Params, nil
+	default:
+		return container.Params{}, usageError("unknown kdf %q", f.kdf)
+	}
+}
+
+func encryptCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f, err := parseCryptFlags("encrypt", args, stderr, true)
+	if err != nil {
+		return err
+	}
+
+	return encrypt(f, stdin, stdout, stderr)
+}
+
+func decryptCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f, err := parseCryptFlags("decrypt", args, stderr, false)
+	if err != nil {
+		return err
+	}
+
+	return decrypt(f, stdin, stdout, stderr)
+}
+
+// pipeCommand looks at the first bytes of stdin to decide what to do, so the
+// same command goes on both ends of a pipeline.
+func pipeCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f, err := parseCryptFlags("pipe", args, stderr, true)
+	if err != nil {
+		return err
+	}
+
+	in := bufio.NewReader(stdin)
+	start, err := in.Peek(len(container.Magic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if string(start) == container.Magic {
+		return decrypt(f, in, stdout, stderr)
+	}
+
+	return encrypt(f, in, stdout, stderr)
+}
+
+func encrypt(f *cryptFlags, stdin io.Reader, stdout, stderr io.Writer) error {
+	params, err := f.params()
+	if err != nil {
+		return err
+	}
+
+	secret, err := f.secret(stderr, true)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(stdout)
+	if err := container.Encrypt(out, stdin, secret, params); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
+
+func decrypt(f *cryptFlags, stdin io.Reader, stdout, stderr io.Writer) error {
+	secret, err := f.secret(stderr, false)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(stdout)
+	if err := container.Decrypt(out, stdin, secret); err != nil {
+		out.Flush()
+		return err
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/attacks/cube"
+)
+
+// cubeCommand runs cube testers on every round count, with the cube of -cube
+// or one grown greedily to -size bits, and prints how many superpolies of
+// the output bits are constant, linear, biased or balanced.
+func cubeCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance cube", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cipherName := fs.String("cipher", "salsa", "chacha, salsa or a toy with 4, 8 or 16 bit words like chacha8")
+	rounds := fs.String("rounds", "1-4", "round count or range of round counts, like 3 or 1-4")
+	cubeBits := fs.String("cube", "", "cube as bits of nonce || counter, like 0,5,64; without it one is grown greedily")
+	size := fs.Int("size", 6, "bits of the cube to grow")
+	tests := fs.Int("tests", 16, "pairs of keys to test the superpolies with")
+	seed := fs.Uint64("seed", 1, "seed of the random keys, nonce and counter")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	c, err := analysis.Lookup(*cipherName)
+	if err != nil {
+		return errUsage{err}
+	}
+	minRounds, maxRounds, err := parseRounds(*rounds)
+	if err != nil {
+		return err
+	}
+	if *tests < 1 {
+		return usageError("tests must be at least 1")
+	}
+
+	var bits []int
+	if *cubeBits != "" {
+		for _, field := range strings.Split(*cubeBits, ",") {
+			b, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return usageError("bad cube bit %q", field)
+			}
+			bits = append(bits, b)
+		}
+	} else if *size < 1 || *size > cube.MaxCube {
+		return usageError("size must be between 1 and %d", cube.MaxCube)
+	}
+
+	out := csv.NewWriter(stdout)
+	out.Write([]string{"rounds", "cube", "constant", "linear", "biased", "balanced"})
+
+	for r := minRounds; r <= maxRounds; r++ {
+		t := cube.Tester{Cipher: c, Rounds: r, Cube: bits}
+		if bits == nil {
+			if err := t.Grow(*size, max(*tests/4, 1), *seed); err != nil {
+				return err
+			}
+		}
+
+		result, err := t.Test(*tests, *seed+1)
+		if err != nil {
+			return errUsage{err}
+		}
+
+		out.Write([]string{fmt.Sprint(r), strings.Trim(fmt.Sprint(t.Cube), "[]"),
+			fmt.Sprint(result.Count(cube.Constant)), fmt.Sprint(result.Count(cube.Linear)),
+			fmt.Sprint(result.Count(cube.Biased)), fmt.Sprint(result.Count(cube.Balanced))})
+		out.Flush()
+	}
+
+	return out.Error()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestCube(t *testing.T) {
+	out, code := dance(t, nil, "cube", "-cipher", "chacha", "-rounds", "1-2", "-cube", "0,3,6,9,12,15,18,21,24,27,30,33", "-tests", "4")
+	if code != exitOK {
+		t.Fatalf("cube: exit code %d", code)
+	}
+
+	lines, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || lines[1][2] != "512" || lines[2][2] == "0" {
+		t.Errorf("cube: expected all constant superpolies after 1 round and some after 2, got %v", lines)
+	}
+
+	out, code = dance(t, nil, "cube", "-cipher", "salsa", "-rounds", "3", "-size", "3", "-tests", "4")
+	if lines, _ := csv.NewReader(bytes.NewReader(out)).ReadAll(); code != exitOK || len(lines) != 2 {
+		t.Errorf("cube growing a cube: exit code %d, %v", code, lines)
+	}
+
+	for _, args := range [][]string{
+		{"cube", "-cipher", "rumba"},
+		{"cube", "-cube", "0,x"},
+		{"cube", "-cube", "0,0"},
+		{"cube", "-size", "33"},
+		{"cube", "-tests", "0"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const passphraseEnv = "DANCE_PASSPHRASE"
+
+// secret returns the key from the key file, or the passphrase from the
+// environment or the terminal. When encrypting the terminal asks twice.
+func (f *cryptFlags) secret(stderr io.Writer, confirm bool) ([]byte, error) {
+	if f.keyFile != "" {
+		return readKeyFile(f.keyFile)
+	}
+
+	if p, ok := os.LookupEnv(passphraseEnv); ok {
+		if p == "" {
+			return nil, usageError("%s is empty", passphraseEnv)
+		}
+		return []byte(p), nil
+	}
+
+	return readPassphrase(stderr, confirm)
+}
+
+func readKeyFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKey(b)
+}
+
+// parseKey accepts a 32 byte key written in hex or standard base64.
+func parseKey(b []byte) ([]byte, error) {
+	s := string(bytes.TrimSpace(b))
+
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+
+	return nil, usageError("key file must have a 32 byte key in hex or base64")
+}
+
+func readPassphrase(stderr io.Writer, confirm bool) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to read the passphrase from, set %s or use -key-file", passphraseEnv)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, "passphrase: ")
+	p, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p) == 0 {
+		return nil, usageError("empty passphrase")
+	}
+
+	if confirm {
+		fmt.Fprint(tty, "again: ")
+		again, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(p, again) {
+			return nil, usageError("passphrases do not match")
+		}
+	}
+
+	return p, nil
+}
+
+func keygenCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance keygen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	useBase64 := fs.Bool("base64", false, "print the key in base64 instead of hex")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return errors.Join(errors.New("could not generate a key"), err)
+	}
+
+	s := hex.EncodeToString(key)
+	if *useBase64 {
+		s = base64.StdEncoding.EncodeToString(key)
+	}
+
+	_, err := fmt.Fprintln(stdout, s)
+	return err
+}
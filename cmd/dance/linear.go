@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/mario-areias/latin-dances-go/analysis/linear"
+)
+
+// linearCommand measures and estimates the correlation of linear
+// approximations of the ChaCha or Salsa permutation: the best trails Search
+// finds, the trail back from -out, or the pair of masks -in and -out. The
+// estimate of -in and -out needs a greedy trail from one to the other, when
+// there is none it prints "no trail".
+func linearCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance linear", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cipherName := fs.String("cipher", "chacha", "chacha or salsa")
+	rounds := fs.Int("rounds", 1, "rounds")
+	in := fs.String("in", "", "input mask as word:bit pairs, like 0:0,12:7 (estimated only when a greedy trail joins it to -out)")
+	out := fs.String("out", "", "output mask as word:bit pairs, without it the best trails are searched")
+	trails := fs.Int("trails", 5, "trails to print when searching")
+	samples := fs.Int("samples", 1<<20, "random states to measure the correlation on")
+	seed := fs.Uint64("seed", 1, "seed of the random states")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+	if *rounds < 1 || *rounds > 20 {
+		return usageError("rounds must be between 1 and 20")
+	}
+	if *samples < 1 {
+		return usageError("samples must be at least 1")
+	}
+	if *in != "" && *out == "" {
+		return usageError("-in needs -out")
+	}
+
+	var cipher linear.Cipher
+	switch *cipherName {
+	case "chacha":
+		cipher = linear.ChaCha
+	case "salsa":
+		cipher = linear.Salsa
+	default:
+		return usageError("unknown cipher %q", *cipherName)
+	}
+
+	var pairs [][2]linear.Mask
+	switch {
+	case *out == "":
+		for _, t := range cipher.Search(*rounds, *trails) {
+			pairs = append(pairs, [2]linear.Mask{t.In(), t.Out()})
+		}
+
+	case *in == "":
+		o, err := linear.ParseMask(*out)
+		if err != nil {
+			return errUsage{err}
+		}
+		pairs = append(pairs, [2]linear.Mask{cipher.Back(o, *rounds).In(), o})
+
+	default:
+		i, err := linear.ParseMask(*in)
+		if err != nil {
+			return errUsage{err}
+		}
+		o, err := linear.ParseMask(*out)
+		if err != nil {
+			return errUsage{err}
+		}
+		pairs = append(pairs, [2]linear.Mask{i, o})
+	}
+
+	w := csv.NewWriter(stdout)
+	w.Write([]string{"rounds", "in", "out", "log2 |estimated|", "measured", "log2 noise"})
+	noise := strconv.FormatFloat(math.Log2(1/math.Sqrt(float64(*samples))), 'f', 1, 64)
+
+	for _, p := range pairs {
+		estimate := "no trail"
+		if c, ok := cipher.Theoretical(p[0], p[1], *rounds); ok {
+			estimate = strconv.FormatFloat(math.Log2(math.Abs(c)), 'f', 1, 64)
+		}
+		measured, err := cipher.Correlation(p[0], p[1], *rounds, *samples, *seed)
+		if err != nil {
+			return err
+		}
+
+		w.Write([]string{fmt.Sprint(*rounds), p[0].String(), p[1].String(), estimate, strconv.FormatFloat(measured, 'f', 5, 64), noise})
+		w.Flush()
+	}
+
+	return w.Error()
+}
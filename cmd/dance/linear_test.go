@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+)
+
+func TestLinear(t *testing.T) {
+	out, code := dance(t, nil, "linear", "-cipher", "chacha", "-rounds", "1", "-trails", "2", "-samples", "16384")
+	if code != exitOK {
+		t.Fatalf("linear: exit code %d", code)
+	}
+
+	lines, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("linear: expected 2 trails, got %v", lines)
+	}
+
+	for _, line := range lines[1:] {
+		measured, _ := strconv.ParseFloat(line[4], 64)
+		if line[3] != "-2.0" || measured < 0.2 {
+			t.Errorf("linear: trail %v, expected a correlation of 1/4", line)
+		}
+	}
+
+	// no greedy trail joins these masks, so only the measurement is there
+	out, code = dance(t, nil, "linear", "-rounds", "1", "-in", "0:0", "-out", "0:0", "-samples", "1024")
+	if lines, _ := csv.NewReader(bytes.NewReader(out)).ReadAll(); code != exitOK || len(lines) != 2 || lines[1][3] != "no trail" {
+		t.Errorf("linear -in -out: exit code %d, %v", code, lines)
+	}
+
+	for _, args := range [][]string{
+		{"linear", "-cipher", "rumba"},
+		{"linear", "-in", "0:0"},
+		{"linear", "-out", "0:32"},
+		{"linear", "-rounds", "0"},
+		{"linear", "-samples", "0"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
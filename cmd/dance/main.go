@@ -0,0 +1,152 @@
+// Command dance encrypts and decrypts files and pipes with ChaCha20-Poly1305,
+// and has a few tools to look inside ChaCha and Salsa.
+//
+// Usage:
+//
+//	dance encrypt [-key-file file] [-kdf scrypt|argon2id] < plain > cipher
+//	dance decrypt [-key-file file] < cipher > plain
+//	dance pipe    [-key-file file] [-kdf scrypt|argon2id] < in > out
+//	dance keygen  [-base64] > key
+//	dance trace   [-cipher chacha|salsa] [-key hex] [-nonce hex] [-counter n] [-format text|json|html]
+//	dance avalanche [-cipher chacha|salsa] [-input key|nonce|counter|all] [-rounds 1-20] [-heatmap dir]
+//	dance nist    [-cipher chacha|salsa] [-rounds 20] [-bytes n] [-stdin]
+//	dance pnb     [-rounds 6] [-middle 3] [-significant 16] [-neutral 20]
+//	dance truncated [-rounds 1-8] [-samples n]
+//	dance crib    [-hex] cipher1 cipher2 [cipher...]
+//	dance circuit [-cipher chacha|salsa] [-rounds 4] [-format anf|cnf] [-no-feed-forward]
+//	dance linear  [-cipher chacha|salsa] [-rounds 1] [-in mask] [-out mask] [-samples n]
+//	dance cube    [-cipher chacha|salsa] [-rounds 1-4] [-cube bits | -size n] [-tests n]
+//
+// Without -key-file the passphrase is read from the DANCE_PASSPHRASE
+// environment variable or, if it is not set, from the terminal.
+//
+// Exit codes:
+//
+//	0  success
+//	1  bad usage
+//	2  I/O error (reading, writing, no terminal for the passphrase...)
+//	3  authentication failure: wrong passphrase or key, modified or truncated input
+//	4  the input is not a dance file
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mario-areias/latin-dances-go/container"
+	// registers the toy ciphers for -cipher
+	_ "github.com/mario-areias/latin-dances-go/toy"
+)
+
+const (
+	exitOK = iota
+	exitUsage
+	exitIO
+	exitAuth
+	exitFormat
+)
+
+const usage = `usage: dance <command> [flags]
+
+commands:
+  encrypt     encrypt stdin to stdout
+  decrypt     decrypt stdin to stdout
+  pipe        decrypt stdin if it is a dance file, encrypt it otherwise
+  keygen      print a new random key
+  trace       show the state of a ChaCha20 or Salsa20 block after every round
+  avalanche   measure how one flipped input bit spreads with each round count
+  nist        run the NIST SP 800-22 randomness tests on the keystream
+  pnb         recover part of a reduced-round ChaCha key with neutral bits
+  truncated   find truncated differentials of reduced-round Salsa20 and estimate attacks
+  crib        recover texts encrypted with the same key and nonce by crib dragging
+  circuit     write reduced-round ChaCha or Salsa as ANF or CNF for algebraic attacks
+  linear      search linear trails and measure their correlation
+  cube        run cube testers on reduced-round ChaCha or Salsa
+
+Run "dance <command> -h" for the flags of a command.
+
+exit codes:
+  0  success
+  1  bad usage
+  2  I/O error
+  3  authentication failure (wrong passphrase or key, modified or truncated input)
+  4  input is not a dance file
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+var commands = map[string]command{
+	"encrypt":   encryptCommand,
+	"decrypt":   decryptCommand,
+	"pipe":      pipeCommand,
+	"keygen":    keygenCommand,
+	"trace":     traceCommand,
+	"avalanche": avalancheCommand,
+	"nist":      nistCommand,
+	"pnb":       pnbCommand,
+	"truncated": truncatedCommand,
+	"crib":      cribCommand,
+	"circuit":   circuitCommand,
+	"linear":    linearCommand,
+	"cube":      cubeCommand,
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "dance: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	err := cmd(args[1:], stdin, stdout, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "dance %s: %s\n", args[0], err)
+	}
+
+	return exitCode(err)
+}
+
+// errUsage wraps errors caused by how the command was called.
+type errUsage struct{ err error }
+
+func (e errUsage) Error() string { return e.err.Error() }
+
+func (e errUsage) Unwrap() error { return e.err }
+
+func usageError(format string, a ...any) error {
+	return errUsage{fmt.Errorf(format, a...)}
+}
+
+func exitCode(err error) int {
+	var u errUsage
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &u):
+		return exitUsage
+	case errors.Is(err, container.ErrPassphrase),
+		errors.Is(err, container.ErrTampered),
+		errors.Is(err, container.ErrTruncated):
+		return exitAuth
+	case errors.Is(err, container.ErrFormat):
+		return exitFormat
+	default:
+		return exitIO
+	}
+}
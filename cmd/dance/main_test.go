@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func dance(t *testing.T, stdin []byte, args ...string) ([]byte, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(stdin), &stdout, &stderr)
+
+	return stdout.Bytes(), code
+}
+
+func TestPassphrase(t *testing.T) {
+	t.Setenv(passphraseEnv, "correct horse battery staple")
+
+	plaintext := []byte("Ladies and Gentlemen of the class of '99")
+
+	cipher, code := dance(t, plaintext, "encrypt")
+	if code != exitOK {
+		t.Fatalf("encrypt: exit code %d", code)
+	}
+
+	out, code := dance(t, cipher, "decrypt")
+	if code != exitOK || !bytes.Equal(out, plaintext) {
+		t.Errorf("decrypt: expected %s, got %s (exit code %d)", plaintext, out, code)
+	}
+
+	// pipe decrypts dance files and encrypts everything else
+	out, code = dance(t, cipher, "pipe")
+	if code != exitOK || !bytes.Equal(out, plaintext) {
+		t.Errorf("pipe: expected %s, got %s (exit code %d)", plaintext, out, code)
+	}
+
+	again, code := dance(t, plaintext, "pipe", "-kdf", "argon2id")
+	if code != exitOK || !strings.HasPrefix(string(again), "LDGF") {
+		t.Errorf("pipe: expected a dance file, got %x (exit code %d)", again, code)
+	}
+
+	tampered := bytes.Clone(cipher)
+	tampered[len(tampered)-1] ^= 1
+	if _, code := dance(t, tampered, "decrypt"); code != exitAuth {
+		t.Errorf("decrypt tampered: expected exit code %d, got %d", exitAuth, code)
+	}
+
+	if _, code := dance(t, cipher[:len(cipher)-20], "decrypt"); code != exitAuth {
+		t.Errorf("decrypt truncated: expected exit code %d, got %d", exitAuth, code)
+	}
+
+	if _, code := dance(t, plaintext, "decrypt"); code != exitFormat {
+		t.Errorf("decrypt plaintext: expected exit code %d, got %d", exitFormat, code)
+	}
+
+	t.Setenv(passphraseEnv, "Tr0ub4dor&3")
+	if _, code := dance(t, cipher, "decrypt"); code != exitAuth {
+		t.Errorf("decrypt wrong passphrase: expected exit code %d, got %d", exitAuth, code)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, format := range [][]string{{"keygen"}, {"keygen", "-base64"}} {
+		key, code := dance(t, nil, format...)
+		if code != exitOK {
+			t.Fatalf("%v: exit code %d", format, code)
+		}
+
+		name := filepath.Join(dir, "key")
+		if err := os.WriteFile(name, key, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		cipher, code := dance(t, []byte("message"), "encrypt", "-key-file", name)
+		if code != exitOK {
+			t.Fatalf("encrypt: exit code %d", code)
+		}
+
+		out, code := dance(t, cipher, "decrypt", "-key-file", name)
+		if code != exitOK || string(out) != "message" {
+			t.Errorf("decrypt: expected message, got %s (exit code %d)", out, code)
+		}
+	}
+
+	if _, code := dance(t, nil, "decrypt", "-key-file", filepath.Join(dir, "missing")); code != exitIO {
+		t.Errorf("missing key file: expected exit code %d, got %d", exitIO, code)
+	}
+
+	// a passphrase is the wrong secret for a file encrypted with a key
+	cipher, code := dance(t, []byte("message"), "encrypt", "-key-file", filepath.Join(dir, "key"))
+	if code != exitOK {
+		t.Fatalf("encrypt: exit code %d", code)
+	}
+	t.Setenv(passphraseEnv, "correct horse battery staple")
+	if _, code := dance(t, cipher, "decrypt"); code != exitAuth {
+		t.Errorf("decrypt with a passphrase: expected exit code %d, got %d", exitAuth, code)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name string
+
+		input string
+		ok    bool
+	}{
+		{name: "hex", input: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f\n", ok: true},
+		{name: "base64", input: "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", ok: true},
+		{name: "short hex", input: "000102030405060708090a0b0c0d0e0f", ok: false},
+		{name: "garbage", input: "not a key", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseKey([]byte(tt.input))
+			if tt.ok && (err != nil || key[31] != 0x1f) {
+				t.Errorf("parseKey: expected key, got %x (%v)", key, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("parseKey: accepted %q", tt.input)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"dance"}, {"encrypt", "file.txt"}, {"encrypt", "-kdf", "md5"}, {"keygen", "-nope"}} {
+		t.Setenv(passphraseEnv, "x")
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+
+	if _, code := dance(t, nil, "encrypt", "-h"); code != exitOK {
+		t.Errorf("-h: expected exit code %d, got %d", exitOK, code)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/analysis/nist"
+)
+
+// nistCommand runs the SP 800-22 tests on the keystream of every round count,
+// or on stdin with -stdin, and prints one CSV line per p-value.
+func nistCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance nist", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cipher := fs.String("cipher", "chacha", "chacha, salsa or a toy with 4, 8 or 16 bit words like chacha8")
+	rounds := fs.String("rounds", "20", "round count or range of round counts, like 4 or 1-8")
+	size := fs.Int("bytes", 125000, "bytes of keystream to test")
+	seed := fs.Uint64("seed", 1, "seed of the random key and nonce")
+	fromStdin := fs.Bool("stdin", false, "test stdin instead of a keystream")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	out := csv.NewWriter(stdout)
+	out.Write([]string{"source", "rounds", "test", "p-value", "pass"})
+
+	write := func(source, rounds string, data []byte) {
+		for _, r := range nist.Suite(nist.Bits(data), nist.DefaultConfig) {
+			for _, p := range r.PValues {
+				out.Write([]string{source, rounds, r.Name, strconv.FormatFloat(p, 'f', 6, 64), strconv.FormatBool(p >= nist.Alpha)})
+			}
+		}
+	}
+
+	if *fromStdin {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		if len(data) < 16 {
+			return usageError("stdin has %d bytes, the tests need at least 16", len(data))
+		}
+
+		write("stdin", "", data)
+		out.Flush()
+		return out.Error()
+	}
+
+	c, err := analysis.Lookup(*cipher)
+	if err != nil {
+		return errUsage{err}
+	}
+
+	minRounds, maxRounds, err := parseRounds(*rounds)
+	if err != nil {
+		return err
+	}
+
+	if *size < 16 {
+		return usageError("bytes must be at least 16")
+	}
+
+	for r := minRounds; r <= maxRounds; r++ {
+		write(c.Name, fmt.Sprint(r), nist.RandomKeystream(c, r, *size, *seed))
+	}
+
+	out.Flush()
+	return out.Error()
+}
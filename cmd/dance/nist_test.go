@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestNIST(t *testing.T) {
+	out, code := dance(t, nil, "nist", "-cipher", "salsa", "-rounds", "1-2", "-bytes", "4096")
+	if code != exitOK {
+		t.Fatalf("nist: exit code %d", code)
+	}
+
+	// 9 p-values per round count
+	lines, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil || len(lines) != 1+2*9 {
+		t.Errorf("nist: expected header and 18 p-values, got %v (%v)", lines, err)
+	}
+
+	out, code = dance(t, make([]byte, 1024), "nist", "-stdin")
+	if code != exitOK {
+		t.Fatalf("nist -stdin: exit code %d", code)
+	}
+
+	// zeros fail everything
+	lines, _ = csv.NewReader(bytes.NewReader(out)).ReadAll()
+	for _, line := range lines[1:] {
+		if line[4] != "false" {
+			t.Errorf("nist -stdin: zeros passed %v", line)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"nist", "-cipher", "rumba"},
+		{"nist", "-rounds", "8-1"},
+		{"nist", "-bytes", "8"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+
+	"github.com/mario-areias/latin-dances-go/attacks/pnb"
+)
+
+// pnbCommand recovers the unknown bits of a random key of reduced-round
+// ChaCha with the probabilistic neutral bits attack.
+func pnbCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance pnb", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	rounds := fs.Int("rounds", 6, "rounds of ChaCha to attack")
+	middle := fs.Int("middle", 3, "round of the differential")
+	significant := fs.Int("significant", 16, "unknown key bits to guess")
+	neutral := fs.Int("neutral", 20, "unknown neutral key bits to brute force")
+	seed := fs.Uint64("seed", 1, "seed of the key and the samples")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	if *middle < 1 || *rounds <= *middle {
+		return usageError("middle must be at least 1 and less than rounds")
+	}
+	if *significant < 1 || *neutral < 0 || *significant+*neutral > 40 {
+		return usageError("significant and neutral must be positive and add up to 40 at most")
+	}
+
+	biases := pnb.FindBiases(*middle, 1<<12, 0.1, *seed)
+	if len(biases) == 0 {
+		return fmt.Errorf("no differential with a bias of 0.1 after %d rounds", *middle)
+	}
+
+	// the strongest differential is not always the best once the neutral bits
+	// are taken into account
+	var attack *pnb.Attack
+	var planErr error
+	for _, d := range biases[:min(8, len(biases))] {
+		a, err := pnb.Plan(d, *rounds, *significant, *neutral, *seed)
+		if err != nil {
+			planErr = err
+			continue
+		}
+		if attack == nil || a.Threshold > attack.Threshold {
+			attack = a
+		}
+	}
+	if attack == nil {
+		return fmt.Errorf("%w: try fewer neutral bits or fewer rounds", planErr)
+	}
+
+	fmt.Fprintf(stdout, "differential: %s\n", attack.Differential)
+	fmt.Fprintf(stdout, "unknown bits: %d significant, %d neutral\n", *significant, *neutral)
+	fmt.Fprintf(stdout, "expected bias %.4f, %d pairs, %.0f guesses\n", 2*attack.Threshold, attack.Samples, math.Pow(2, float64(*significant)))
+
+	rng := rand.New(rand.NewPCG(*seed, 1))
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(rng.Uint32())
+	}
+
+	known := key
+	for _, k := range attack.Unknown {
+		known[k/8] &^= 1 << (k % 8)
+	}
+
+	fmt.Fprintf(stdout, "secret key:   %x\n", key)
+	fmt.Fprintf(stdout, "known key:    %x\n", known)
+
+	found, err := attack.Run(pnb.NewOracle(key, *rounds), known, *seed)
+	if errors.Is(err, pnb.ErrNotFound) {
+		return fmt.Errorf("%w, the neutral bits may not be neutral enough for this key: try fewer of them or another seed", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "recovered:    %x\n", found)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPNB(t *testing.T) {
+	out, code := dance(t, nil, "pnb", "-rounds", "6", "-significant", "6", "-neutral", "6")
+	if code != exitOK {
+		t.Fatalf("pnb: exit code %d", code)
+	}
+
+	var secret, recovered []byte
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if v, ok := bytes.CutPrefix(line, []byte("secret key:")); ok {
+			secret = bytes.TrimSpace(v)
+		}
+		if v, ok := bytes.CutPrefix(line, []byte("recovered:")); ok {
+			recovered = bytes.TrimSpace(v)
+		}
+	}
+
+	if len(secret) != 64 || !bytes.Equal(secret, recovered) {
+		t.Errorf("pnb: secret key %s, recovered %s", secret, recovered)
+	}
+
+	for _, args := range [][]string{
+		{"pnb", "-rounds", "3"},
+		{"pnb", "-significant", "0"},
+		{"pnb", "-significant", "30", "-neutral", "30"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
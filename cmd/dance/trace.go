@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+	"github.com/mario-areias/latin-dances-go/trace"
+	"golang.org/x/term"
+)
+
+// traceCommand shows how the state of one block evolves, round by round.
+func traceCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance trace", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cipher := fs.String("cipher", "chacha", "chacha or salsa")
+	keyHex := fs.String("key", "", "key in hex, 32 bytes (default all zeros)")
+	nonceHex := fs.String("nonce", "", "nonce in hex, 12 bytes for chacha and 8 for salsa (default all zeros)")
+	counter := fs.Uint64("counter", 0, "block counter")
+	format := fs.String("format", "text", "text, json or html")
+	level := fs.String("level", "quarter", "quarter to show every quarter round, round to show only full rounds")
+	color := fs.String("color", "auto", "colours in text output: auto, always or never")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	key, err := decodeFixed("key", *keyHex, 32)
+	if err != nil {
+		return err
+	}
+
+	var steps []trace.Step
+	var title string
+
+	switch *cipher {
+	case "chacha":
+		nonce, err := decodeFixed("nonce", *nonceHex, 12)
+		if err != nil {
+			return err
+		}
+		if *counter > 1<<32-1 {
+			return usageError("chacha counter must fit in 32 bits")
+		}
+
+		chacha.BlockTrace([32]byte(key), uint32(*counter), [12]byte(nonce), trace.Collect(&steps))
+		title = fmt.Sprintf("ChaCha20 block %d", *counter)
+	case "salsa":
+		nonce, err := decodeFixed("nonce", *nonceHex, 8)
+		if err != nil {
+			return err
+		}
+
+		salsa.BlockTrace((*[32]byte)(key), nonce, *counter, trace.Collect(&steps))
+		title = fmt.Sprintf("Salsa20 block %d", *counter)
+	default:
+		return usageError("unknown cipher %q", *cipher)
+	}
+
+	switch *level {
+	case "quarter":
+	case "round":
+		steps = trace.Rounds(steps)
+	default:
+		return usageError("unknown level %q", *level)
+	}
+
+	switch *format {
+	case "text":
+		useColor, err := colorOutput(*color, stdout)
+		if err != nil {
+			return err
+		}
+		return trace.WriteText(stdout, steps, useColor)
+	case "json":
+		return trace.WriteJSON(stdout, steps)
+	case "html":
+		return trace.WriteHTML(stdout, title, steps)
+	default:
+		return usageError("unknown format %q", *format)
+	}
+}
+
+// decodeFixed decodes a hex flag of exactly size bytes, empty means zeros.
+func decodeFixed(name, s string, size int) ([]byte, error) {
+	if s == "" {
+		return make([]byte, size), nil
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != size {
+		return nil, usageError("%s must be %d bytes in hex", name, size)
+	}
+
+	return b, nil
+}
+
+func colorOutput(mode string, stdout io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		f, ok := stdout.(*os.File)
+		return ok && term.IsTerminal(int(f.Fd())), nil
+	default:
+		return false, usageError("unknown color mode %q", mode)
+	}
+}
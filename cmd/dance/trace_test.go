@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	// RFC 8439 section 2.3.2
+	out, code := dance(t, nil, "trace", "-format", "json",
+		"-key", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		"-nonce", "000000090000004a00000000", "-counter", "1")
+	if code != exitOK {
+		t.Fatalf("trace: exit code %d", code)
+	}
+
+	var steps []struct {
+		Kind  string   `json:"kind"`
+		State []string `json:"state"`
+	}
+	if err := json.Unmarshal(out, &steps); err != nil {
+		t.Fatalf("trace: invalid JSON: %s", err)
+	}
+
+	last := steps[len(steps)-1]
+	if last.Kind != "feed-forward" || last.State[0] != "e4e7f110" || last.State[15] != "4e3c50a2" {
+		t.Errorf("trace: unexpected last step %+v", last)
+	}
+
+	out, code = dance(t, nil, "trace", "-cipher", "salsa", "-level", "round", "-color", "never")
+	if code != exitOK || strings.Count(string(out), "row round") != 10 {
+		t.Errorf("trace salsa: expected 10 row rounds, got %s (exit code %d)", out, code)
+	}
+
+	out, code = dance(t, nil, "trace", "-format", "html")
+	if code != exitOK || !strings.Contains(string(out), "<title>ChaCha20 block 0</title>") {
+		t.Errorf("trace html: unexpected output (exit code %d)", code)
+	}
+
+	for _, args := range [][]string{
+		{"trace", "-cipher", "rumba"},
+		{"trace", "-key", "00"},
+		{"trace", "-cipher", "salsa", "-nonce", "000000090000004a00000000"},
+		{"trace", "-counter", "4294967296"},
+		{"trace", "-format", "pdf"},
+		{"trace", "-level", "bit"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
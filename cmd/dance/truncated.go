@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/mario-areias/latin-dances-go/attacks/truncated"
+)
+
+// truncatedCommand prints, for each round count of Salsa20, the best truncated
+// differential on the output and the estimated key recovery attacks that walk
+// back to a differential after 3 or 4 rounds.
+func truncatedCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dance truncated", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	rounds := fs.String("rounds", "1-8", "round count or range of round counts, like 5 or 5-8")
+	samples := fs.Int("samples", 1<<14, "random keys per measurement")
+	seed := fs.Uint64("seed", 1, "seed of the random keys and nonces")
+
+	if err := fs.Parse(args); err != nil {
+		return errUsage{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	minRounds, maxRounds, err := parseRounds(*rounds)
+	if err != nil {
+		return err
+	}
+	if minRounds < 1 {
+		return usageError("rounds must be at least 1")
+	}
+	if *samples < 16 {
+		return usageError("samples must be at least 16")
+	}
+
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 4, 64)
+	}
+
+	out := csv.NewWriter(stdout)
+	out.Write([]string{"rounds", "attack", "differential", "middle", "bias", "neutral", "log2 pairs", "log2 time"})
+
+	// the differentials to walk back to only depend on their rounds
+	middles := map[int]truncated.Differential{}
+	noise := 3 / math.Sqrt(float64(*samples))
+
+	for r := minRounds; r <= maxRounds; r++ {
+		d := truncated.Search(r, true, *samples, *seed)[0]
+		d.Bias = d.Measure(*samples, *seed+1)
+
+		if math.Abs(d.Bias) >= noise {
+			data := format(math.Log2(truncated.DataNeeded(d.Bias)))
+			out.Write([]string{fmt.Sprint(r), "distinguisher", d.String(), fmt.Sprint(r), format(d.Bias), "", data, data})
+		}
+
+		for m := 3; m <= 4 && m < r; m++ {
+			if _, ok := middles[m]; !ok {
+				middles[m] = truncated.Search(m, false, *samples, *seed)[0]
+			}
+
+			// no better than trying every key
+			e := middles[m].Estimate(r, *samples, *seed+2)
+			if e.Time >= 256 {
+				continue
+			}
+
+			out.Write([]string{fmt.Sprint(r), "key recovery", e.Differential.String(), fmt.Sprint(m),
+				format(e.Bias), fmt.Sprint(e.Neutral), format(e.Data), format(e.Time)})
+		}
+
+		out.Flush()
+	}
+
+	return out.Error()
+}
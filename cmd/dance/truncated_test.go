@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestTruncated(t *testing.T) {
+	out, code := dance(t, nil, "truncated", "-rounds", "2-5", "-samples", "4096")
+	if code != exitOK {
+		t.Fatalf("truncated: exit code %d", code)
+	}
+
+	lines, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attacks := map[string]int{}
+	for _, line := range lines[1:] {
+		attacks[line[0]+" "+line[1]]++
+	}
+
+	// distinguishers up to 4 rounds, key recovery after
+	for _, attack := range []string{"2 distinguisher", "3 distinguisher", "4 distinguisher", "5 key recovery"} {
+		if attacks[attack] == 0 {
+			t.Errorf("truncated: no %s in %v", attack, lines)
+		}
+	}
+	if attacks["5 distinguisher"] != 0 {
+		t.Errorf("truncated: distinguisher on 5 rounds in %v", lines)
+	}
+
+	for _, args := range [][]string{
+		{"truncated", "-rounds", "0-2"},
+		{"truncated", "-samples", "1"},
+	} {
+		if _, code := dance(t, nil, args...); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
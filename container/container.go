@@ -0,0 +1,286 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+// A container file is a header followed by ChaCha20-Poly1305 chunks.
+//
+// Header (little endian):
+//
+//	magic "LDGF"          4 bytes
+//	version               1 byte
+//	kdf                   1 byte
+//	kdf parameters        3 x 4 bytes (see Params)
+//	chunk size            4 bytes
+//	salt                  16 bytes
+//	nonce prefix          7 bytes
+//	key check             32 bytes
+//
+// Every chunk holds chunk size bytes of plaintext plus a 16 byte tag, except
+// the last one which can be shorter (or empty). Chunks follow the STREAM
+// construction: the nonce of each chunk is the nonce prefix, the chunk number
+// (4 bytes, big endian) and a byte that is 1 only for the last chunk. So
+// chunks can't be reordered, and a file cut at a chunk boundary is detected.
+// The whole header is the aad of every chunk.
+
+// Version of the format written by Encrypt.
+const Version = 1
+
+const (
+	headerSize  = 77
+	saltSize    = 16
+	prefixSize  = 7
+	checkSize   = 32
+	tagSize     = 16
+	maxChunk    = 16 << 20
+	maxChunkNum = 1<<32 - 1
+)
+
+// Magic is how every container file starts.
+const Magic = "LDGF"
+
+var magic = []byte(Magic)
+
+var (
+	// ErrFormat means the input is not a container this package can read.
+	ErrFormat = errors.New("not a container file")
+	// ErrPassphrase means the passphrase does not match the key check.
+	ErrPassphrase = errors.New("wrong passphrase")
+	// ErrTruncated means the file ended before its last chunk, or in the
+	// middle of a chunk.
+	ErrTruncated = errors.New("file is truncated")
+	// ErrTampered means a chunk failed authentication. A short last chunk that
+	// fails could have been cut or modified, nothing tells them apart, so
+	// Decrypt returns an error that is both ErrTruncated and ErrTampered.
+	ErrTampered = errors.New("file was modified")
+)
+
+type header struct {
+	params Params
+	salt   [saltSize]byte
+	prefix [prefixSize]byte
+	check  [checkSize]byte
+}
+
+func (h *header) marshal() []byte {
+	b := make([]byte, 0, headerSize)
+	b = append(b, magic...)
+	b = append(b, Version, byte(h.params.KDF))
+	b = binary.LittleEndian.AppendUint32(b, h.params.Cost[0])
+	b = binary.LittleEndian.AppendUint32(b, h.params.Cost[1])
+	b = binary.LittleEndian.AppendUint32(b, h.params.Cost[2])
+	b = binary.LittleEndian.AppendUint32(b, h.params.ChunkSize)
+	b = append(b, h.salt[:]...)
+	b = append(b, h.prefix[:]...)
+	return append(b, h.check[:]...)
+}
+
+func unmarshal(b []byte) (*header, error) {
+	if !bytes.Equal(b[0:4], magic) {
+		return nil, ErrFormat
+	}
+
+	if b[4] != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, b[4])
+	}
+
+	h := &header{}
+	h.params.KDF = KDF(b[5])
+	h.params.Cost[0] = binary.LittleEndian.Uint32(b[6:10])
+	h.params.Cost[1] = binary.LittleEndian.Uint32(b[10:14])
+	h.params.Cost[2] = binary.LittleEndian.Uint32(b[14:18])
+	h.params.ChunkSize = binary.LittleEndian.Uint32(b[18:22])
+	copy(h.salt[:], b[22:38])
+	copy(h.prefix[:], b[38:45])
+	copy(h.check[:], b[45:77])
+
+	if err := h.params.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFormat, err)
+	}
+
+	return h, nil
+}
+
+// Encrypt reads plaintext from r and writes a container to w.
+func Encrypt(w io.Writer, r io.Reader, passphrase []byte, params Params) error {
+	if err := params.validate(); err != nil {
+		return err
+	}
+
+	h := &header{params: params}
+	if _, err := rand.Read(h.salt[:]); err != nil {
+		return err
+	}
+	if _, err := rand.Read(h.prefix[:]); err != nil {
+		return err
+	}
+
+	key, check, err := params.derive(passphrase, h.salt[:])
+	if err != nil {
+		return err
+	}
+	h.check = check
+
+	aad := h.marshal()
+	if _, err := w.Write(aad); err != nil {
+		return err
+	}
+
+	size := int(params.ChunkSize)
+	current := make([]byte, size)
+	next := make([]byte, size)
+
+	n, err := readChunk(r, current)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); ; i++ {
+		if i > maxChunkNum {
+			return errors.New("file too big")
+		}
+
+		// only a full chunk can be followed by more data
+		m := 0
+		if n == size {
+			m, err = readChunk(r, next)
+			if err != nil {
+				return err
+			}
+		}
+
+		last := m == 0
+		cipher, tag := chacha.EncryptAED(key, chunkNonce(h.prefix, uint32(i), last), current[:n], aad)
+
+		if _, err := w.Write(cipher); err != nil {
+			return err
+		}
+		if _, err := w.Write(tag); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+
+		current, next = next, current
+		n = m
+	}
+}
+
+// Decrypt reads a container from r and writes the plaintext to w. Chunks are
+// written as soon as they are authenticated, so on ErrTruncated or
+// ErrTampered w may already have part of the plaintext.
+func Decrypt(w io.Writer, r io.Reader, passphrase []byte) error {
+	b := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrFormat
+		}
+		return err
+	}
+
+	h, err := unmarshal(b)
+	if err != nil {
+		return err
+	}
+
+	key, check, err := h.params.derive(passphrase, h.salt[:])
+	if errors.Is(err, errKeySize) {
+		// a passphrase given for a raw key file is just the wrong secret
+		return fmt.Errorf("%w: %w", ErrPassphrase, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(check[:], h.check[:]) != 1 {
+		return ErrPassphrase
+	}
+
+	reader := bufio.NewReader(r)
+	chunk := make([]byte, int(h.params.ChunkSize)+tagSize)
+
+	for i := uint64(0); ; i++ {
+		if i > maxChunkNum {
+			return ErrFormat
+		}
+
+		n, err := readChunk(reader, chunk)
+		if err != nil {
+			return err
+		}
+
+		if n < tagSize {
+			return ErrTruncated
+		}
+
+		// a short chunk must be the last one, a full one is the last one only
+		// if nothing comes after it
+		last := n < len(chunk)
+		if !last {
+			_, err := reader.Peek(1)
+			last = err == io.EOF
+		}
+
+		message, err := openChunk(key, h.prefix, uint32(i), last, chunk[:n], b)
+		if err != nil && last && n == len(chunk) {
+			// cut exactly after a full chunk that was not meant to be the last
+			if _, err := openChunk(key, h.prefix, uint32(i), false, chunk[:n], b); err == nil {
+				return ErrTruncated
+			}
+		}
+		if err != nil && n < len(chunk) {
+			// the input ran out in the middle of a chunk
+			return fmt.Errorf("%w: %w", ErrTruncated, ErrTampered)
+		}
+		if err != nil {
+			return ErrTampered
+		}
+
+		if _, err := w.Write(message); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+func openChunk(key [32]byte, prefix [prefixSize]byte, i uint32, last bool, chunk, aad []byte) ([]byte, error) {
+	cipher := chunk[:len(chunk)-tagSize]
+	tag := chunk[len(chunk)-tagSize:]
+
+	return chacha.DecryptAED(key, chunkNonce(prefix, i, last), cipher, tag, aad)
+}
+
+func chunkNonce(prefix [prefixSize]byte, i uint32, last bool) [12]byte {
+	var nonce [12]byte
+	copy(nonce[:prefixSize], prefix[:])
+	binary.BigEndian.PutUint32(nonce[prefixSize:prefixSize+4], i)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// readChunk fills b as much as possible and returns how many bytes it read.
+// Running out of input is not an error.
+func readChunk(r io.Reader, b []byte) (int, error) {
+	n, err := io.ReadFull(r, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, nil
+	}
+	return n, err
+}
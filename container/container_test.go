@@ -0,0 +1,211 @@
+package container
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// cheap parameters, the tests are not about the KDF cost
+var (
+	testScrypt = Params{KDF: Scrypt, Cost: [3]uint32{1 << 4, 1, 1}, ChunkSize: 64}
+	testArgon2 = Params{KDF: Argon2id, Cost: [3]uint32{1, 8, 1}, ChunkSize: 64}
+)
+
+var passphrase = []byte("correct horse battery staple")
+
+func encrypt(t *testing.T, plaintext []byte, params Params) []byte {
+	var out bytes.Buffer
+	if err := Encrypt(&out, bytes.NewReader(plaintext), passphrase, params); err != nil {
+		t.Fatalf("Encrypt: %s", err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(file, passphrase []byte) ([]byte, error) {
+	var out bytes.Buffer
+	err := Decrypt(&out, bytes.NewReader(file), passphrase)
+	return out.Bytes(), err
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, params := range []Params{testScrypt, testArgon2} {
+		for _, size := range []int{0, 1, 63, 64, 65, 128, 200} {
+			plaintext := make([]byte, size)
+			if _, err := rand.Read(plaintext); err != nil {
+				panic(err)
+			}
+
+			file := encrypt(t, plaintext, params)
+
+			// a file that fills its last chunk does not get an extra empty one
+			chunks := max((size+63)/64, 1)
+			if expected := headerSize + size + chunks*tagSize; len(file) != expected {
+				t.Errorf("kdf %d, %d bytes: expected file of %d bytes, got %d", params.KDF, size, expected, len(file))
+			}
+
+			message, err := decrypt(file, passphrase)
+			if err != nil {
+				t.Errorf("kdf %d, %d bytes: Decrypt: %s", params.KDF, size, err)
+			}
+			if !bytes.Equal(message, plaintext) {
+				t.Errorf("kdf %d, %d bytes: Decrypt: expected %x, got %x", params.KDF, size, plaintext, message)
+			}
+		}
+	}
+}
+
+func TestRawKey(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	params := RawKeyParams
+	params.ChunkSize = 64
+
+	var file bytes.Buffer
+	if err := Encrypt(&file, bytes.NewReader([]byte("message")), key, params); err != nil {
+		t.Fatalf("Encrypt: %s", err)
+	}
+
+	message, err := decrypt(file.Bytes(), key)
+	if err != nil || string(message) != "message" {
+		t.Errorf("Decrypt: expected message, got %s (%v)", message, err)
+	}
+
+	key[0] ^= 1
+	if _, err := decrypt(file.Bytes(), key); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("Decrypt: expected %s, got %v", ErrPassphrase, err)
+	}
+
+	if _, err := decrypt(file.Bytes(), key[:16]); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("Decrypt with a 16 byte key: expected %s, got %v", ErrPassphrase, err)
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), 10)
+	file := encrypt(t, plaintext, testScrypt)
+
+	chunk := 64 + tagSize
+
+	tests := []struct {
+		name string
+
+		change     func(f []byte) []byte
+		passphrase string
+		err        error
+	}{
+		{
+			name:       "wrong passphrase",
+			change:     func(f []byte) []byte { return f },
+			passphrase: "Tr0ub4dor&3",
+			err:        ErrPassphrase,
+		},
+		{
+			name:   "not a container",
+			change: func(f []byte) []byte { f[0] = 'X'; return f },
+			err:    ErrFormat,
+		},
+		{
+			name:   "unknown version",
+			change: func(f []byte) []byte { f[4] = 9; return f },
+			err:    ErrFormat,
+		},
+		{
+			name:   "crazy kdf cost",
+			change: func(f []byte) []byte { f[9] = 0x40; return f },
+			err:    ErrFormat,
+		},
+		{
+			name:   "truncated header",
+			change: func(f []byte) []byte { return f[:headerSize-1] },
+			err:    ErrFormat,
+		},
+		{
+			name:   "no chunks",
+			change: func(f []byte) []byte { return f[:headerSize] },
+			err:    ErrTruncated,
+		},
+		{
+			name:   "cut at a chunk boundary",
+			change: func(f []byte) []byte { return f[:headerSize+chunk] },
+			err:    ErrTruncated,
+		},
+		{
+			name:   "cut inside a tag",
+			change: func(f []byte) []byte { return f[:headerSize+2*chunk+10] },
+			err:    ErrTruncated,
+		},
+		{
+			name:   "cut inside a chunk",
+			change: func(f []byte) []byte { return f[:headerSize+chunk+40] },
+			err:    ErrTruncated,
+		},
+		{
+			name:   "modified chunk",
+			change: func(f []byte) []byte { f[headerSize+chunk+3] ^= 1; return f },
+			err:    ErrTampered,
+		},
+		{
+			name:   "modified nonce prefix",
+			change: func(f []byte) []byte { f[40] ^= 1; return f },
+			err:    ErrTampered,
+		},
+		{
+			name:   "modified key check",
+			change: func(f []byte) []byte { f[headerSize-1] ^= 1; return f },
+			err:    ErrPassphrase,
+		},
+		{
+			name: "reordered chunks",
+			change: func(f []byte) []byte {
+				first := bytes.Clone(f[headerSize : headerSize+chunk])
+				copy(f[headerSize:], f[headerSize+chunk:headerSize+2*chunk])
+				copy(f[headerSize+chunk:], first)
+				return f
+			},
+			err: ErrTampered,
+		},
+		{
+			name:   "extra data",
+			change: func(f []byte) []byte { return append(f, 0) },
+			err:    ErrTampered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := passphrase
+			if tt.passphrase != "" {
+				p = []byte(tt.passphrase)
+			}
+
+			_, err := decrypt(tt.change(bytes.Clone(file)), p)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Decrypt: expected %s, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	for _, params := range []Params{
+		{KDF: Scrypt, Cost: [3]uint32{1 << 4, 1, 1}, ChunkSize: 0},
+		{KDF: Scrypt, Cost: [3]uint32{3, 1, 1}, ChunkSize: 64},
+		{KDF: Scrypt, Cost: [3]uint32{1 << 4, 0, 1}, ChunkSize: 64},
+		{KDF: Scrypt, Cost: [3]uint32{1 << 4, 1 << 16, 1 << 16}, ChunkSize: 64},
+		{KDF: Scrypt, Cost: [3]uint32{1 << 22, 8, 1}, ChunkSize: 64},
+		{KDF: Argon2id, Cost: [3]uint32{1, 8, 0}, ChunkSize: 64},
+		{KDF: Argon2id, Cost: [3]uint32{1, 4 << 20, 1}, ChunkSize: 64},
+		{KDF: Argon2id, Cost: [3]uint32{1 << 10, 8, 1}, ChunkSize: 64},
+		{KDF: RawKey, Cost: [3]uint32{1, 0, 0}, ChunkSize: 64},
+		{KDF: 9, Cost: [3]uint32{1, 1, 1}, ChunkSize: 64},
+	} {
+		if err := Encrypt(&bytes.Buffer{}, bytes.NewReader(nil), passphrase, params); err == nil {
+			t.Errorf("Encrypt accepted %+v", params)
+		}
+	}
+}
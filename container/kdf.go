@@ -0,0 +1,131 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF turns the passphrase into the key.
+type KDF byte
+
+const (
+	Scrypt   KDF = 1
+	Argon2id KDF = 2
+	// RawKey is for when the "passphrase" is already a random 32 byte key.
+	// It is only expanded with ChaCha20 and the salt into a per-file key.
+	RawKey KDF = 3
+)
+
+// Params are stored in the header, so Decrypt only needs the passphrase.
+type Params struct {
+	KDF KDF
+	// Cost is N, r and p for scrypt, and time, memory (in KiB) and threads
+	// for Argon2id.
+	Cost [3]uint32
+	// ChunkSize is how much plaintext goes in each chunk.
+	ChunkSize uint32
+}
+
+// DefaultParams follow the recommendations of each KDF for interactive use.
+var DefaultParams = Params{KDF: Scrypt, Cost: [3]uint32{1 << 15, 8, 1}, ChunkSize: 64 << 10}
+
+// errKeySize is returned by derive for a raw key that isn't 32 bytes.
+var errKeySize = errors.New("key must be 32 bytes")
+
+// RawKeyParams are the parameters to use with a 32 byte key.
+var RawKeyParams = Params{KDF: RawKey, ChunkSize: 64 << 10}
+
+// DefaultArgon2Params are the Argon2id recommendation from RFC 9106.
+var DefaultArgon2Params = Params{KDF: Argon2id, Cost: [3]uint32{3, 64 << 10, 4}, ChunkSize: 64 << 10}
+
+// limits on what Decrypt accepts, so a crafted header can't make it use
+// all the memory or CPU of the machine. Scrypt needs 128·N·r bytes, Argon2id
+// its memory parameter in KiB; both are capped at 1 GiB. The time limits keep
+// the work to a few passes over that memory.
+const (
+	maxKDFMemory    = 1 << 30
+	maxScryptN      = 1 << 22
+	maxScryptR      = 32
+	maxScryptP      = 16
+	maxArgon2Time   = 16
+	maxArgon2Memory = maxKDFMemory >> 10
+)
+
+func (p Params) validate() error {
+	if p.ChunkSize == 0 || p.ChunkSize > maxChunk {
+		return fmt.Errorf("chunk size must be between 1 and %d", maxChunk)
+	}
+
+	switch p.KDF {
+	case Scrypt:
+		n, r, q := p.Cost[0], p.Cost[1], p.Cost[2]
+		if n < 2 || n&(n-1) != 0 || n > maxScryptN {
+			return errors.New("scrypt N must be a power of two up to 2^22")
+		}
+		if r == 0 || r > maxScryptR {
+			return fmt.Errorf("scrypt r must be between 1 and %d", maxScryptR)
+		}
+		if q == 0 || q > maxScryptP {
+			return fmt.Errorf("scrypt p must be between 1 and %d", maxScryptP)
+		}
+		if 128*uint64(n)*uint64(r) > maxKDFMemory {
+			return errors.New("scrypt N and r need more than 1 GiB of memory")
+		}
+	case Argon2id:
+		t, m, threads := p.Cost[0], p.Cost[1], p.Cost[2]
+		if t == 0 || t > maxArgon2Time {
+			return fmt.Errorf("argon2 time must be between 1 and %d", maxArgon2Time)
+		}
+		if threads == 0 || threads > 255 {
+			return errors.New("invalid argon2 threads")
+		}
+		if m < 8*threads || m > maxArgon2Memory {
+			return errors.New("argon2 memory must be at least 8 KiB per thread and at most 1 GiB")
+		}
+	case RawKey:
+		if p.Cost != [3]uint32{} {
+			return errors.New("a raw key has no cost parameters")
+		}
+	default:
+		return fmt.Errorf("unknown kdf %d", p.KDF)
+	}
+
+	return nil
+}
+
+// derive returns the data key and the key check value. Both come from the
+// same KDF output, the check is stored in the header so a wrong passphrase is
+// reported before decrypting anything.
+func (p Params) derive(passphrase, salt []byte) ([32]byte, [checkSize]byte, error) {
+	var out []byte
+
+	switch p.KDF {
+	case Scrypt:
+		k, err := scrypt.Key(passphrase, salt, int(p.Cost[0]), int(p.Cost[1]), int(p.Cost[2]), 32+checkSize)
+		if err != nil {
+			return [32]byte{}, [checkSize]byte{}, err
+		}
+		out = k
+	case Argon2id:
+		out = argon2.IDKey(passphrase, salt, p.Cost[0], p.Cost[1], uint8(p.Cost[2]), 32+checkSize)
+	case RawKey:
+		if len(passphrase) != 32 {
+			return [32]byte{}, [checkSize]byte{}, errKeySize
+		}
+		out = chacha.Block([32]byte(passphrase), 0, [12]byte(salt[:12]))
+	default:
+		return [32]byte{}, [checkSize]byte{}, fmt.Errorf("unknown kdf %d", p.KDF)
+	}
+
+	key := [32]byte(out[:32])
+	check := [checkSize]byte(out[32:])
+
+	// don't keep a copy of the key around longer than needed
+	clear(out)
+
+	return key, check, nil
+}
@@ -0,0 +1,113 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// An envelope is
+//
+//	magic "LDGO" (4 bytes) || version (1 byte) || algorithm (1 byte) || nonce || cipher || tag
+//
+// Nonce and tag sizes depend on the algorithm. Everything before the cipher is
+// the header, and it is authenticated as aad together with the caller's aad.
+
+// Version of the envelope format written by Seal.
+const Version = 1
+
+var magic = []byte("LDGO")
+
+const headerSize = 6
+
+var (
+	ErrFormat     = errors.New("not an envelope")
+	ErrVersion    = errors.New("unsupported envelope version")
+	ErrUnknown    = errors.New("unknown algorithm")
+	ErrDeprecated = errors.New("deprecated algorithm")
+)
+
+// Seal encrypts plaintext with the algorithm id from the default registry.
+func Seal(id ID, key, plaintext, aad []byte) ([]byte, error) {
+	return Default.Seal(id, key, plaintext, aad)
+}
+
+// Open decrypts an envelope with the algorithm from its header, using the
+// default registry.
+func Open(key, envelope, aad []byte) ([]byte, error) {
+	return Default.Open(key, envelope, aad)
+}
+
+// Seal encrypts plaintext with a random nonce and wraps it in an envelope.
+func (r *Registry) Seal(id ID, key, plaintext, aad []byte) ([]byte, error) {
+	a, err := r.lookup(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != a.KeySize {
+		return nil, fmt.Errorf("%s needs a %d byte key", a.Name, a.KeySize)
+	}
+
+	nonce := make([]byte, a.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append(bytes.Clone(magic), Version, byte(id)), nonce...)
+
+	cipher, tag := a.Seal(key, nonce, plaintext, bindHeader(header, aad))
+
+	out := append(header, cipher...)
+	return append(out, tag...), nil
+}
+
+// Open decrypts an envelope with the algorithm from its header. Deprecated
+// algorithms are refused, unless r is a legacy registry.
+func (r *Registry) Open(key, envelope, aad []byte) ([]byte, error) {
+	if len(envelope) < headerSize || !bytes.Equal(envelope[:len(magic)], magic) {
+		return nil, ErrFormat
+	}
+
+	if envelope[4] != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, envelope[4])
+	}
+
+	id := ID(envelope[5])
+	a, err := r.lookup(id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != a.KeySize {
+		return nil, fmt.Errorf("%s needs a %d byte key", a.Name, a.KeySize)
+	}
+
+	if len(envelope) < headerSize+a.NonceSize+a.TagSize {
+		return nil, ErrFormat
+	}
+
+	end := headerSize + a.NonceSize
+	header := envelope[:end]
+	nonce := envelope[headerSize:end]
+	cipher := envelope[end : len(envelope)-a.TagSize]
+	tag := envelope[len(envelope)-a.TagSize:]
+
+	return a.Open(key, nonce, cipher, tag, bindHeader(header, aad))
+}
+
+// Algorithm reads the algorithm id of an envelope without decrypting it.
+func Algorithm(envelope []byte) (ID, error) {
+	if len(envelope) < headerSize || !bytes.Equal(envelope[:len(magic)], magic) {
+		return 0, ErrFormat
+	}
+
+	return ID(envelope[5]), nil
+}
+
+func bindHeader(header, aad []byte) []byte {
+	out := make([]byte, 0, len(header)+len(aad))
+	out = append(out, header...)
+	return append(out, aad...)
+}
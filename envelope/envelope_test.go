@@ -0,0 +1,211 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	key := randomKey()
+	plaintext := []byte("Ladies and Gentlemen of the class of '99")
+	aad := []byte("backup-2024")
+
+	for _, id := range []ID{ChaCha20Poly1305, XChaCha20Poly1305} {
+		envelope, err := Seal(id, key, plaintext, aad)
+		if err != nil {
+			t.Fatalf("Seal(%d): %s", id, err)
+		}
+
+		if got, err := Algorithm(envelope); err != nil || got != id {
+			t.Errorf("Algorithm: expected %d, got %d (%v)", id, got, err)
+		}
+
+		message, err := Open(key, envelope, aad)
+		if err != nil {
+			t.Errorf("Open(%d): %s", id, err)
+		}
+		if !bytes.Equal(message, plaintext) {
+			t.Errorf("Open(%d): expected %s, got %s", id, plaintext, message)
+		}
+
+		if _, err := Open(key, envelope, []byte("backup-2025")); err == nil {
+			t.Errorf("Open(%d) accepted a different aad", id)
+		}
+
+		if _, err := Open(randomKey(), envelope, aad); err == nil {
+			t.Errorf("Open(%d) accepted a different key", id)
+		}
+	}
+}
+
+func TestOpenHeader(t *testing.T) {
+	key := randomKey()
+
+	envelope, err := Seal(ChaCha20Poly1305, key, []byte("message"), nil)
+	if err != nil {
+		t.Fatalf("Seal: %s", err)
+	}
+
+	tests := []struct {
+		name string
+
+		change func(e []byte) []byte
+		err    error
+	}{
+		{
+			name:   "magic",
+			change: func(e []byte) []byte { e[0] = 'X'; return e },
+			err:    ErrFormat,
+		},
+		{
+			name:   "version",
+			change: func(e []byte) []byte { e[4] = 2; return e },
+			err:    ErrVersion,
+		},
+		{
+			name:   "unknown algorithm",
+			change: func(e []byte) []byte { e[5] = 0x7f; return e },
+			err:    ErrUnknown,
+		},
+		{
+			name:   "deprecated algorithm",
+			change: func(e []byte) []byte { e[5] = byte(Salsa20); return e },
+			err:    ErrDeprecated,
+		},
+		{
+			name:   "truncated header",
+			change: func(e []byte) []byte { return e[:5] },
+			err:    ErrFormat,
+		},
+		{
+			name:   "truncated body",
+			change: func(e []byte) []byte { return e[:headerSize+12+15] },
+			err:    ErrFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(key, tt.change(bytes.Clone(envelope)), nil)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Open: expected %s, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// the header is part of the aad, so moving the ciphertext to another
+// algorithm with the same sizes must fail authentication
+func TestHeaderIsAuthenticated(t *testing.T) {
+	key := randomKey()
+
+	r := NewRegistry()
+	r.Register(ChaCha20Poly1305, chacha20Poly1305)
+	r.Register(0x42, chacha20Poly1305)
+
+	envelope, err := r.Seal(ChaCha20Poly1305, key, []byte("message"), nil)
+	if err != nil {
+		t.Fatalf("Seal: %s", err)
+	}
+
+	envelope[5] = 0x42
+	if _, err := r.Open(key, envelope, nil); err == nil {
+		t.Errorf("Open accepted a changed algorithm id")
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	key := randomKey()
+
+	if _, err := Seal(Salsa20, key, []byte("message"), nil); !errors.Is(err, ErrDeprecated) {
+		t.Errorf("Seal(Salsa20): expected %s, got %v", ErrDeprecated, err)
+	}
+
+	// a registry can still read old Salsa20 envelopes
+	r := NewRegistry()
+	old := salsa20
+	old.Deprecated = false
+	r.Register(Salsa20, old)
+
+	envelope, err := r.Seal(Salsa20, key, []byte("old data"), nil)
+	if err != nil {
+		t.Fatalf("Seal(Salsa20): %s", err)
+	}
+
+	message, err := r.Open(key, envelope, nil)
+	if err != nil || string(message) != "old data" {
+		t.Errorf("Open(Salsa20): expected old data, got %s (%v)", message, err)
+	}
+
+	if err := r.Deprecate(Salsa20); err != nil {
+		t.Fatalf("Deprecate: %s", err)
+	}
+	if _, err := r.Open(key, envelope, nil); !errors.Is(err, ErrDeprecated) {
+		t.Errorf("Open(Salsa20): expected %s, got %v", ErrDeprecated, err)
+	}
+
+	if err := r.Deprecate(0x7f); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Deprecate: expected %s, got %v", ErrUnknown, err)
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	key := randomKey()
+
+	if _, err := Legacy.Seal(Salsa20, key, []byte("message"), nil); !errors.Is(err, ErrDeprecated) {
+		t.Errorf("Seal(Salsa20): expected %s, got %v", ErrDeprecated, err)
+	}
+
+	r := NewRegistry()
+	old := salsa20
+	old.Deprecated = false
+	r.Register(Salsa20, old)
+
+	envelope, err := r.Seal(Salsa20, key, []byte("old data"), nil)
+	if err != nil {
+		t.Fatalf("Seal(Salsa20): %s", err)
+	}
+
+	if _, err := Open(key, envelope, nil); !errors.Is(err, ErrDeprecated) {
+		t.Errorf("Open(Salsa20): expected %s, got %v", ErrDeprecated, err)
+	}
+
+	message, err := Legacy.Open(key, envelope, nil)
+	if err != nil || string(message) != "old data" {
+		t.Errorf("Legacy.Open(Salsa20): expected old data, got %s (%v)", message, err)
+	}
+
+	// opened envelopes can be sealed again with an authenticated algorithm
+	envelope, err = Legacy.Seal(ChaCha20Poly1305, key, message, nil)
+	if err != nil {
+		t.Fatalf("Seal(ChaCha20Poly1305): %s", err)
+	}
+	message, err = Open(key, envelope, nil)
+	if err != nil || string(message) != "old data" {
+		t.Errorf("Open(ChaCha20Poly1305): expected old data, got %s (%v)", message, err)
+	}
+}
+
+func TestKeySize(t *testing.T) {
+	if _, err := Seal(ChaCha20Poly1305, make([]byte, 16), []byte("message"), nil); err == nil {
+		t.Errorf("Seal accepted a 16 byte key")
+	}
+
+	envelope, err := Seal(ChaCha20Poly1305, randomKey(), []byte("message"), nil)
+	if err != nil {
+		t.Fatalf("Seal: %s", err)
+	}
+	if _, err := Open(make([]byte, 16), envelope, nil); err == nil {
+		t.Errorf("Open accepted a 16 byte key")
+	}
+}
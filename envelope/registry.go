@@ -0,0 +1,144 @@
+package envelope
+
+import (
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// ID identifies an algorithm in the envelope header.
+type ID byte
+
+const (
+	Salsa20           ID = 0x01
+	ChaCha20Poly1305  ID = 0x02
+	XChaCha20Poly1305 ID = 0x03
+)
+
+// AEAD is an algorithm that can be stored in an envelope. Algorithms without
+// authentication ignore aad and return an empty tag.
+type AEAD struct {
+	Name      string
+	KeySize   int
+	NonceSize int
+	TagSize   int
+
+	// Deprecated algorithms are rejected by Seal and Open. Only the Open of
+	// a legacy registry reads them.
+	Deprecated bool
+
+	Seal func(key, nonce, plaintext, aad []byte) ([]byte, []byte)
+	Open func(key, nonce, cipher, tag, aad []byte) ([]byte, error)
+}
+
+// Registry maps algorithm ids to their implementation.
+type Registry struct {
+	algorithms map[ID]AEAD
+
+	// legacy registries open envelopes of deprecated algorithms
+	legacy bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{algorithms: map[ID]AEAD{}}
+}
+
+// NewLegacyRegistry returns an empty registry whose Open also reads envelopes
+// of deprecated algorithms, Seal still refuses them. Deprecated algorithms may
+// have no authentication, so anyone can write an envelope that opens: only
+// use it to migrate envelopes from a place an attacker cannot write to.
+func NewLegacyRegistry() *Registry {
+	return &Registry{algorithms: map[ID]AEAD{}, legacy: true}
+}
+
+// Default knows about every algorithm in this repository. Salsa20 is there so
+// old envelopes are recognised, but it is deprecated because it has no
+// authentication.
+var Default = NewRegistry()
+
+// Legacy knows the same algorithms as Default, and opens Salsa20 envelopes so
+// they can be sealed again with an authenticated algorithm.
+var Legacy = NewLegacyRegistry()
+
+func init() {
+	for _, r := range []*Registry{Default, Legacy} {
+		r.Register(Salsa20, salsa20)
+		r.Register(ChaCha20Poly1305, chacha20Poly1305)
+		r.Register(XChaCha20Poly1305, xchacha20Poly1305)
+	}
+}
+
+// Register adds or replaces the algorithm for id.
+func (r *Registry) Register(id ID, a AEAD) {
+	r.algorithms[id] = a
+}
+
+// Deprecate stops id from being used by Seal, and by Open unless r is a legacy
+// registry.
+func (r *Registry) Deprecate(id ID) error {
+	a, ok := r.algorithms[id]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknown, id)
+	}
+
+	a.Deprecated = true
+	r.algorithms[id] = a
+	return nil
+}
+
+// lookup returns the algorithm for id, deprecated ones only when open is
+// set and r is a legacy registry.
+func (r *Registry) lookup(id ID, open bool) (AEAD, error) {
+	a, ok := r.algorithms[id]
+	if !ok {
+		return AEAD{}, fmt.Errorf("%w: %d", ErrUnknown, id)
+	}
+
+	if a.Deprecated && !(open && r.legacy) {
+		return AEAD{}, fmt.Errorf("%w: %s", ErrDeprecated, a.Name)
+	}
+
+	return a, nil
+}
+
+var salsa20 = AEAD{
+	Name:       "Salsa20",
+	KeySize:    32,
+	NonceSize:  8,
+	TagSize:    0,
+	Deprecated: true,
+	Seal: func(key, nonce, plaintext, aad []byte) ([]byte, []byte) {
+		return salsa.Encrypt((*[32]byte)(key), nonce, plaintext), nil
+	},
+	Open: func(key, nonce, cipher, tag, aad []byte) ([]byte, error) {
+		return salsa.Encrypt((*[32]byte)(key), nonce, cipher), nil
+	},
+}
+
+var chacha20Poly1305 = AEAD{
+	Name:      "ChaCha20-Poly1305",
+	KeySize:   32,
+	NonceSize: 12,
+	TagSize:   16,
+	Seal: func(key, nonce, plaintext, aad []byte) ([]byte, []byte) {
+		return chacha.EncryptAED([32]byte(key), [12]byte(nonce), plaintext, aad)
+	},
+	Open: func(key, nonce, cipher, tag, aad []byte) ([]byte, error) {
+		return chacha.DecryptAED([32]byte(key), [12]byte(nonce), cipher, tag, aad)
+	},
+}
+
+var xchacha20Poly1305 = AEAD{
+	Name:      "XChaCha20-Poly1305",
+	KeySize:   32,
+	NonceSize: 24,
+	TagSize:   16,
+	Seal: func(key, nonce, plaintext, aad []byte) ([]byte, []byte) {
+		return chacha.EncryptXAED([32]byte(key), [24]byte(nonce), plaintext, aad)
+	},
+	Open: func(key, nonce, cipher, tag, aad []byte) ([]byte, error) {
+		return chacha.DecryptXAED([32]byte(key), [24]byte(nonce), cipher, tag, aad)
+	},
+}
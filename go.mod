@@ -2,4 +2,9 @@ module github.com/mario-areias/latin-dances-go
 
 go 1.22.5
 
-require golang.org/x/crypto v0.31.0
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/term v0.27.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,261 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+// A ciphertext produced by the keyring is
+//
+//	key id (4 bytes, little endian) || nonce (12 bytes) || cipher || tag (16 bytes)
+//
+// The key id is prepended to the aad given to chacha.EncryptAED, so moving a
+// ciphertext to another id breaks the tag.
+
+const (
+	idSize    = 4
+	nonceSize = 12
+	tagSize   = 16
+
+	// Overhead is how many bytes Encrypt adds to a message.
+	Overhead = idSize + nonceSize + tagSize
+
+	// MaxRecord is the largest ciphertext WriteRecord writes and ReadRecord
+	// reads, so a corrupt length can't make ReadRecord allocate 4 GiB.
+	MaxRecord = 64 << 20
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown key id")
+	ErrNoPrimary  = errors.New("keyring has no primary key")
+	ErrRecordSize = fmt.Errorf("record larger than %d bytes", MaxRecord)
+	ErrTruncated  = errors.New("truncated record")
+)
+
+// Keyring holds versioned keys, one of them is the primary used to encrypt.
+type Keyring struct {
+	keys    map[uint32][32]byte
+	primary uint32
+	ok      bool
+}
+
+// New returns an empty keyring.
+func New() *Keyring {
+	return &Keyring{keys: map[uint32][32]byte{}}
+}
+
+// Add stores key under id. Ids can't be reused.
+func (k *Keyring) Add(id uint32, key [32]byte) error {
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key id %d already in use", id)
+	}
+
+	k.keys[id] = key
+	return nil
+}
+
+// Remove forgets the key id, ciphertexts made with it can't be decrypted anymore.
+// The primary key can't be removed.
+func (k *Keyring) Remove(id uint32) error {
+	if _, ok := k.keys[id]; !ok {
+		return ErrUnknownKey
+	}
+
+	if k.ok && k.primary == id {
+		return errors.New("can't remove the primary key")
+	}
+
+	delete(k.keys, id)
+	return nil
+}
+
+// SetPrimary makes id the key used by Encrypt.
+func (k *Keyring) SetPrimary(id uint32) error {
+	if _, ok := k.keys[id]; !ok {
+		return ErrUnknownKey
+	}
+
+	k.primary = id
+	k.ok = true
+	return nil
+}
+
+// Primary returns the id of the primary key.
+func (k *Keyring) Primary() (uint32, error) {
+	if !k.ok {
+		return 0, ErrNoPrimary
+	}
+
+	return k.primary, nil
+}
+
+// Rotate adds key under id and makes it the primary.
+func (k *Keyring) Rotate(id uint32, key [32]byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+
+	return k.SetPrimary(id)
+}
+
+// Encrypt encrypts message with the primary key and a random nonce.
+func (k *Keyring) Encrypt(message, aad []byte) ([]byte, error) {
+	if !k.ok {
+		return nil, ErrNoPrimary
+	}
+
+	nonce := [nonceSize]byte{}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, idSize, Overhead+len(message))
+	binary.LittleEndian.PutUint32(header, k.primary)
+	header = append(header, nonce[:]...)
+
+	cipher, tag := chacha.EncryptAED(k.keys[k.primary], nonce, message, bindID(header[:idSize], aad))
+
+	out := append(header, cipher...)
+	return append(out, tag...), nil
+}
+
+// Decrypt finds the key that encrypted the ciphertext and decrypts it.
+func (k *Keyring) Decrypt(ciphertext, aad []byte) ([]byte, error) {
+	id, err := KeyID(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKey, id)
+	}
+
+	nonce := [nonceSize]byte(ciphertext[idSize : idSize+nonceSize])
+	cipher := ciphertext[idSize+nonceSize : len(ciphertext)-tagSize]
+	tag := ciphertext[len(ciphertext)-tagSize:]
+
+	return chacha.DecryptAED(key, nonce, cipher, tag, bindID(ciphertext[:idSize], aad))
+}
+
+// KeyID returns the id of the key that produced ciphertext.
+func KeyID(ciphertext []byte) (uint32, error) {
+	if len(ciphertext) < Overhead {
+		return 0, errors.New("ciphertext too short")
+	}
+
+	return binary.LittleEndian.Uint32(ciphertext[:idSize]), nil
+}
+
+// ReEncrypt moves ciphertext to the primary key. Every ciphertext is
+// authenticated first, the ones already under the primary key are then
+// returned unchanged.
+func (k *Keyring) ReEncrypt(ciphertext, aad []byte) ([]byte, error) {
+	message, err := k.Decrypt(ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt checked the length, so the key id is there
+	if id, _ := KeyID(ciphertext); k.ok && id == k.primary {
+		return ciphertext, nil
+	}
+
+	return k.Encrypt(message, aad)
+}
+
+// ReEncryptStream reads records written by WriteRecord from r, moves each
+// ciphertext to the primary key and writes it to w. It returns how many
+// ciphertexts were moved.
+func (k *Keyring) ReEncryptStream(r io.Reader, w io.Writer, aad []byte) (int, error) {
+	moved := 0
+
+	for {
+		ciphertext, err := ReadRecord(r)
+		if err == io.EOF {
+			return moved, nil
+		}
+		if err != nil {
+			return moved, err
+		}
+
+		id, err := KeyID(ciphertext)
+		if err != nil {
+			return moved, err
+		}
+
+		out, err := k.ReEncrypt(ciphertext, aad)
+		if err != nil {
+			return moved, err
+		}
+
+		if err := WriteRecord(w, out); err != nil {
+			return moved, err
+		}
+
+		if id != k.primary {
+			moved++
+		}
+	}
+}
+
+// WriteRecord writes ciphertext prefixed by its length (4 bytes, little endian).
+func WriteRecord(w io.Writer, ciphertext []byte) error {
+	if len(ciphertext) > MaxRecord {
+		return ErrRecordSize
+	}
+
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(ciphertext)))
+
+	if _, err := w.Write(length); err != nil {
+		return err
+	}
+
+	_, err := w.Write(ciphertext)
+	return err
+}
+
+// ReadRecord reads a ciphertext written by WriteRecord. It returns io.EOF when
+// there are no more records, ErrTruncated when r ends partway through a
+// record and ErrRecordSize when the length is over MaxRecord.
+func ReadRecord(r io.Reader) ([]byte, error) {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r, length); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, readError(err)
+	}
+
+	size := binary.LittleEndian.Uint32(length)
+	if size > MaxRecord {
+		return nil, ErrRecordSize
+	}
+
+	ciphertext := make([]byte, size)
+	if _, err := io.ReadFull(r, ciphertext); err != nil {
+		return nil, readError(err)
+	}
+
+	return ciphertext, nil
+}
+
+// readError reports the end of r partway through a record as ErrTruncated.
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return fmt.Errorf("reading record: %w", err)
+}
+
+func bindID(id, aad []byte) []byte {
+	out := make([]byte, 0, len(id)+len(aad))
+	out = append(out, id...)
+	return append(out, aad...)
+}
@@ -0,0 +1,239 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+var errBroken = errors.New("broken reader")
+
+func randomKey() [32]byte {
+	key := [32]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := New()
+
+	if _, err := k.Encrypt([]byte("message"), nil); !errors.Is(err, ErrNoPrimary) {
+		t.Errorf("Encrypt without primary: expected %s, got %v", ErrNoPrimary, err)
+	}
+
+	if err := k.Rotate(1, randomKey()); err != nil {
+		t.Fatalf("Rotate: %s", err)
+	}
+
+	aad := []byte("header")
+	c1, err := k.Encrypt([]byte("first quarter"), aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %s", err)
+	}
+
+	if err := k.Rotate(2, randomKey()); err != nil {
+		t.Fatalf("Rotate: %s", err)
+	}
+
+	c2, err := k.Encrypt([]byte("second quarter"), aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %s", err)
+	}
+
+	for _, tt := range []struct {
+		ciphertext []byte
+		id         uint32
+		message    string
+	}{{c1, 1, "first quarter"}, {c2, 2, "second quarter"}} {
+		id, err := KeyID(tt.ciphertext)
+		if err != nil || id != tt.id {
+			t.Errorf("KeyID: expected %d, got %d (%v)", tt.id, id, err)
+		}
+
+		message, err := k.Decrypt(tt.ciphertext, aad)
+		if err != nil {
+			t.Errorf("Decrypt: %s", err)
+		}
+		if string(message) != tt.message {
+			t.Errorf("Decrypt: expected %s, got %s", tt.message, message)
+		}
+	}
+
+	if len(c1) != len("first quarter")+Overhead {
+		t.Errorf("Encrypt: expected %d bytes, got %d", len("first quarter")+Overhead, len(c1))
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	k := New()
+	if err := k.Rotate(1, randomKey()); err != nil {
+		t.Fatalf("Rotate: %s", err)
+	}
+	if err := k.Add(2, randomKey()); err != nil {
+		t.Fatalf("Add: %s", err)
+	}
+
+	c, err := k.Encrypt([]byte("message"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt: %s", err)
+	}
+
+	// the id is bound in the aad, pointing to another key must fail
+	moved := bytes.Clone(c)
+	moved[0] = 2
+	if _, err := k.Decrypt(moved, nil); err == nil {
+		t.Errorf("Decrypt accepted a ciphertext with a changed key id")
+	}
+
+	unknown := bytes.Clone(c)
+	unknown[0] = 9
+	if _, err := k.Decrypt(unknown, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt: expected %s, got %v", ErrUnknownKey, err)
+	}
+
+	if _, err := k.Decrypt(c, []byte("other")); err == nil {
+		t.Errorf("Decrypt accepted a different aad")
+	}
+
+	if _, err := k.Decrypt(c[:Overhead-1], nil); err == nil {
+		t.Errorf("Decrypt accepted a short ciphertext")
+	}
+
+	if err := k.Add(1, randomKey()); err == nil {
+		t.Errorf("Add accepted a duplicated id")
+	}
+
+	if err := k.Remove(1); err == nil {
+		t.Errorf("Remove accepted the primary key")
+	}
+
+	if err := k.Remove(2); err != nil {
+		t.Errorf("Remove: %s", err)
+	}
+
+	if err := k.SetPrimary(2); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("SetPrimary: expected %s, got %v", ErrUnknownKey, err)
+	}
+}
+
+func TestReEncryptStream(t *testing.T) {
+	k := New()
+	if err := k.Rotate(1, randomKey()); err != nil {
+		t.Fatalf("Rotate: %s", err)
+	}
+
+	messages := []string{"january", "february", "march", ""}
+
+	var old bytes.Buffer
+	for i, m := range messages {
+		if i == 2 {
+			if err := k.Rotate(2, randomKey()); err != nil {
+				t.Fatalf("Rotate: %s", err)
+			}
+		}
+
+		c, err := k.Encrypt([]byte(m), nil)
+		if err != nil {
+			t.Fatalf("Encrypt: %s", err)
+		}
+		if err := WriteRecord(&old, c); err != nil {
+			t.Fatalf("WriteRecord: %s", err)
+		}
+	}
+
+	if err := k.Rotate(3, randomKey()); err != nil {
+		t.Fatalf("Rotate: %s", err)
+	}
+
+	var current bytes.Buffer
+	moved, err := k.ReEncryptStream(&old, &current, nil)
+	if err != nil {
+		t.Fatalf("ReEncryptStream: %s", err)
+	}
+	if moved != len(messages) {
+		t.Errorf("ReEncryptStream: expected %d moved, got %d", len(messages), moved)
+	}
+
+	// old keys are not needed anymore
+	for _, id := range []uint32{1, 2} {
+		if err := k.Remove(id); err != nil {
+			t.Fatalf("Remove: %s", err)
+		}
+	}
+
+	var got []string
+	for {
+		c, err := ReadRecord(&current)
+		if err != nil {
+			break
+		}
+
+		if id, _ := KeyID(c); id != 3 {
+			t.Errorf("ReEncryptStream: expected key 3, got %d", id)
+		}
+
+		m, err := k.Decrypt(c, nil)
+		if err != nil {
+			t.Fatalf("Decrypt: %s", err)
+		}
+		got = append(got, string(m))
+	}
+
+	if !slices.Equal(got, messages) {
+		t.Errorf("ReEncryptStream: expected %v, got %v", messages, got)
+	}
+
+	// everything is on the primary key now, so nothing else moves
+	var again bytes.Buffer
+	c, err := k.Encrypt([]byte("april"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt: %s", err)
+	}
+	if err := WriteRecord(&again, c); err != nil {
+		t.Fatalf("WriteRecord: %s", err)
+	}
+	if moved, err := k.ReEncryptStream(&again, &bytes.Buffer{}, nil); err != nil || moved != 0 {
+		t.Errorf("ReEncryptStream: expected 0 moved, got %d (%v)", moved, err)
+	}
+
+	// but it is still authenticated
+	c[len(c)-1] ^= 1
+	if _, err := k.ReEncrypt(c, nil); err == nil {
+		t.Errorf("ReEncrypt accepted a forged ciphertext under the primary key")
+	}
+
+	var empty bytes.Buffer
+	if err := WriteRecord(&empty, []byte{}); err != nil {
+		t.Fatalf("WriteRecord: %s", err)
+	}
+	if _, err := k.ReEncryptStream(&empty, &bytes.Buffer{}, nil); err == nil {
+		t.Errorf("ReEncryptStream accepted an empty record")
+	}
+
+	for _, record := range [][]byte{{10, 0}, {10, 0, 0, 0}, {10, 0, 0, 0, 1, 2}} {
+		if _, err := k.ReEncryptStream(bytes.NewReader(record), &bytes.Buffer{}, nil); !errors.Is(err, ErrTruncated) {
+			t.Errorf("ReEncryptStream(%x): expected ErrTruncated, got %v", record, err)
+		}
+	}
+
+	// other read errors are passed on
+	broken := io.MultiReader(bytes.NewReader([]byte{10, 0, 0, 0}), iotest.ErrReader(errBroken))
+	if _, err := k.ReEncryptStream(broken, &bytes.Buffer{}, nil); !errors.Is(err, errBroken) {
+		t.Errorf("ReEncryptStream: expected %s, got %v", errBroken, err)
+	}
+
+	// a corrupt length is refused before anything is allocated
+	huge := bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 1, 2})
+	if _, err := k.ReEncryptStream(huge, &bytes.Buffer{}, nil); !errors.Is(err, ErrRecordSize) {
+		t.Errorf("ReEncryptStream: expected ErrRecordSize, got %v", err)
+	}
+	if err := WriteRecord(&bytes.Buffer{}, make([]byte, MaxRecord+1)); !errors.Is(err, ErrRecordSize) {
+		t.Errorf("WriteRecord: expected ErrRecordSize, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package prp
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+)
+
+// Rounds of the Feistel network.
+const Rounds = 8
+
+// PRP is a keyed permutation of the integers 0..n-1.
+//
+// Values are split in two halves of at most 32 bits and go through an
+// (unbalanced when the number of bits is odd) Feistel network whose round
+// function is the ChaCha20 block function. The Feistel network permutes
+// 0..2^k-1, so values that fall outside the domain are encrypted again until
+// they land inside it (cycle walking).
+type PRP struct {
+	key [32]byte
+	n   uint64
+
+	left, right int
+}
+
+// New returns the permutation of 0..n-1 under key.
+func New(key [32]byte, n uint64) *PRP {
+	if n == 0 {
+		panic("domain must not be empty")
+	}
+
+	k := bits.Len64(n - 1)
+	if k < 2 {
+		k = 2
+	}
+
+	return &PRP{key: key, n: n, left: k / 2, right: k - k/2}
+}
+
+// Size returns n, the size of the domain.
+func (p *PRP) Size() uint64 {
+	return p.n
+}
+
+// Permute maps x to its position in the permutation.
+func (p *PRP) Permute(x uint64) uint64 {
+	p.check(x)
+
+	for {
+		x = p.encrypt(x)
+		if x < p.n {
+			return x
+		}
+	}
+}
+
+// Inverse is the inverse of Permute.
+func (p *PRP) Inverse(x uint64) uint64 {
+	p.check(x)
+
+	for {
+		x = p.decrypt(x)
+		if x < p.n {
+			return x
+		}
+	}
+}
+
+func (p *PRP) check(x uint64) {
+	if x >= p.n {
+		panic("x must be smaller than the domain size")
+	}
+}
+
+// Even rounds change the left half using the right one, odd rounds change the
+// right half using the left one. There is no swap, so the halves can have
+// different sizes.
+func (p *PRP) encrypt(x uint64) uint64 {
+	l, r := p.split(x)
+
+	for i := 0; i < Rounds; i++ {
+		if i%2 == 0 {
+			l ^= p.f(i, r, p.left)
+		} else {
+			r ^= p.f(i, l, p.right)
+		}
+	}
+
+	return p.join(l, r)
+}
+
+func (p *PRP) decrypt(x uint64) uint64 {
+	l, r := p.split(x)
+
+	for i := Rounds - 1; i >= 0; i-- {
+		if i%2 == 0 {
+			l ^= p.f(i, r, p.left)
+		} else {
+			r ^= p.f(i, l, p.right)
+		}
+	}
+
+	return p.join(l, r)
+}
+
+func (p *PRP) split(x uint64) (uint32, uint32) {
+	return uint32(x >> p.right), uint32(x & mask(p.right))
+}
+
+func (p *PRP) join(l, r uint32) uint64 {
+	return uint64(l)<<p.right | uint64(r)
+}
+
+// f is the round function. The half goes in the counter, the round and the
+// domain size go in the nonce, so every domain gets unrelated permutations.
+func (p *PRP) f(round int, half uint32, size int) uint32 {
+	var nonce [12]byte
+	binary.LittleEndian.PutUint32(nonce[0:4], uint32(round))
+	binary.LittleEndian.PutUint64(nonce[4:12], p.n)
+
+	stream := chacha.Block(p.key, half, nonce)
+
+	return uint32(binary.LittleEndian.Uint64(stream[0:8]) & mask(size))
+}
+
+func mask(size int) uint64 {
+	return 1<<size - 1
+}
+
+// Shuffle returns a copy of s where the element at i is moved to the position
+// given by the permutation of 0..len(s)-1 under key.
+func Shuffle[T any](key [32]byte, s []T) []T {
+	out := make([]T, len(s))
+	if len(s) == 0 {
+		return out
+	}
+
+	p := New(key, uint64(len(s)))
+	for i, v := range s {
+		out[p.Permute(uint64(i))] = v
+	}
+
+	return out
+}
+
+// Unshuffle undoes Shuffle with the same key.
+func Unshuffle[T any](key [32]byte, s []T) []T {
+	out := make([]T, len(s))
+	if len(s) == 0 {
+		return out
+	}
+
+	p := New(key, uint64(len(s)))
+	for i, v := range s {
+		out[p.Inverse(uint64(i))] = v
+	}
+
+	return out
+}
@@ -0,0 +1,105 @@
+package prp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func randomKey() [32]byte {
+	key := [32]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// every value of small domains must be hit exactly once and come back with Inverse
+func TestBijection(t *testing.T) {
+	key := randomKey()
+
+	for n := uint64(1); n <= 300; n++ {
+		p := New(key, n)
+		seen := make([]bool, n)
+
+		for x := uint64(0); x < n; x++ {
+			y := p.Permute(x)
+			if y >= n {
+				t.Fatalf("n=%d: Permute(%d) = %d is outside the domain", n, x, y)
+			}
+			if seen[y] {
+				t.Fatalf("n=%d: Permute(%d) = %d was already seen", n, x, y)
+			}
+			seen[y] = true
+
+			if back := p.Inverse(y); back != x {
+				t.Fatalf("n=%d: Inverse(Permute(%d)) = %d", n, x, back)
+			}
+		}
+	}
+}
+
+func TestLargeDomains(t *testing.T) {
+	key := randomKey()
+
+	for _, n := range []uint64{1<<32 + 1, 1<<40 + 7, 1<<63 + 12345, ^uint64(0)} {
+		p := New(key, n)
+
+		for i := 0; i < 100; i++ {
+			b := make([]byte, 8)
+			if _, err := rand.Read(b); err != nil {
+				panic(err)
+			}
+			x := binary.LittleEndian.Uint64(b) % n
+
+			y := p.Permute(x)
+			if y >= n {
+				t.Fatalf("n=%d: Permute(%d) = %d is outside the domain", n, x, y)
+			}
+			if back := p.Inverse(y); back != x {
+				t.Fatalf("n=%d: Inverse(Permute(%d)) = %d", n, x, back)
+			}
+		}
+	}
+}
+
+func TestKeyChangesPermutation(t *testing.T) {
+	s := make([]int, 100)
+	for i := range s {
+		s[i] = i
+	}
+
+	a := Shuffle(randomKey(), s)
+	b := Shuffle(randomKey(), s)
+
+	if slices.Equal(a, b) {
+		t.Errorf("two keys gave the same shuffle %v", a)
+	}
+	if slices.Equal(a, s) {
+		t.Errorf("Shuffle did not move anything")
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	key := randomKey()
+
+	s := []string{"salsa", "chacha", "rumba", "tango", "samba", "mambo", "bachata"}
+	shuffled := Shuffle(key, s)
+
+	sorted := slices.Clone(shuffled)
+	slices.Sort(sorted)
+	expected := slices.Clone(s)
+	slices.Sort(expected)
+	if !slices.Equal(sorted, expected) {
+		t.Errorf("Shuffle() = %v is not a permutation of %v", shuffled, s)
+	}
+
+	if out := Unshuffle(key, shuffled); !slices.Equal(out, s) {
+		t.Errorf("Unshuffle() = %v, want %v", out, s)
+	}
+
+	if out := Shuffle(key, []string{}); len(out) != 0 {
+		t.Errorf("Shuffle of empty slice = %v", out)
+	}
+}
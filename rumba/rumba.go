@@ -0,0 +1,124 @@
+package rumba
+
+import (
+	"encoding/binary"
+	"hash"
+
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// Rumba20 is described in https://cr.yp.to/rumba20.html
+// It compresses 1536 bits (four 384-bit chunks) into 512 bits by xoring four
+// Salsa20 expansions that only differ on the constants placed on the diagonal.
+
+const (
+	// Size of a Rumba20 output and of the hash chaining value.
+	Size = 64
+	// InputSize of the compression function.
+	InputSize = 192
+	// BlockSize is the amount of message consumed per compression in the hash mode.
+	BlockSize = InputSize - Size
+)
+
+var constants = [4][16]byte{
+	[16]byte([]byte("firstRumba20bloc")),
+	[16]byte([]byte("secondRumba20blo")),
+	[16]byte([]byte("thirdRumba20bloc")),
+	[16]byte([]byte("fourthRumba20blo")),
+}
+
+// Compress is the Rumba20 compression function.
+func Compress(input [InputSize]byte) [Size]byte {
+	var out [Size]byte
+
+	for i := 0; i < 4; i++ {
+		out = xor(out, expand(i, [48]byte(input[i*48:i*48+48])))
+	}
+
+	return out
+}
+
+func expand(i int, chunk [48]byte) [Size]byte {
+	return [Size]byte(salsa.Expand(constants[i], chunk))
+}
+
+func xor(a, b [Size]byte) [Size]byte {
+	for i := 0; i < Size; i++ {
+		a[i] ^= b[i]
+	}
+	return a
+}
+
+// Sum returns the Merkle–Damgård hash of data built on top of Compress.
+func Sum(data []byte) [Size]byte {
+	d := New()
+	d.Write(data)
+
+	return [Size]byte(d.Sum(nil))
+}
+
+// New returns a hash.Hash computing the Rumba20 Merkle–Damgård hash.
+//
+// Each compression takes the 64 byte chaining value and 128 bytes of message.
+// The chaining value starts as zeros and the message is padded with 0x80, zeros
+// and the message length in bits as a 64 bit little endian integer.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+type digest struct {
+	h      [Size]byte
+	buffer []byte
+	length uint64
+}
+
+func (d *digest) Reset() {
+	d.h = [Size]byte{}
+	d.buffer = nil
+	d.length = 0
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Write(p []byte) (int, error) {
+	d.length += uint64(len(p))
+	d.buffer = append(d.buffer, p...)
+
+	for len(d.buffer) >= BlockSize {
+		d.compress(d.buffer[:BlockSize])
+		d.buffer = d.buffer[BlockSize:]
+	}
+
+	return len(p), nil
+}
+
+func (d *digest) compress(block []byte) {
+	var input [InputSize]byte
+	copy(input[:Size], d.h[:])
+	copy(input[Size:], block)
+
+	d.h = Compress(input)
+}
+
+func (d *digest) Sum(in []byte) []byte {
+	// work on a copy so the caller can keep writing
+	c := *d
+	c.buffer = append([]byte(nil), d.buffer...)
+
+	length := make([]byte, 8)
+	binary.LittleEndian.PutUint64(length, c.length*8)
+
+	padding := []byte{0x80}
+	for (len(c.buffer)+len(padding)+8)%BlockSize != 0 {
+		padding = append(padding, 0x00)
+	}
+
+	c.Write(padding)
+	c.Write(length)
+
+	return append(in, c.h[:]...)
+}
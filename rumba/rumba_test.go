@@ -0,0 +1,182 @@
+package rumba
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+func TestConstants(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		for j := i + 1; j < 4; j++ {
+			if constants[i] == constants[j] {
+				t.Errorf("constants %d and %d are equal: %s", i, j, constants[i])
+			}
+		}
+	}
+}
+
+// Rumba20 is f0(x0) ^ f1(x1) ^ f2(x2) ^ f3(x3), so changing a single chunk must
+// only change its own expansion.
+func TestCompressIsXorOfExpansions(t *testing.T) {
+	var a, b [InputSize]byte
+
+	if _, err := rand.Read(a[:]); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		b = a
+		if _, err := rand.Read(b[i*48 : i*48+48]); err != nil {
+			panic(err)
+		}
+
+		got := xor(Compress(a), Compress(b))
+		expected := xor(expand(i, [48]byte(a[i*48:i*48+48])), expand(i, [48]byte(b[i*48:i*48+48])))
+
+		if got != expected {
+			t.Errorf("chunk %d: Compress difference = %x, want %x", i, got, expected)
+		}
+	}
+}
+
+// There are no published Rumba20 test vectors we know of, the values of
+// TestCompress and TestSum are this implementation's own. TestReference checks
+// Compress against golang.org/x/crypto instead.
+func TestCompress(t *testing.T) {
+	input := [InputSize]byte{}
+	expected := "ccfd24d801aa555b21781b5d9234cb85d378c748eeb3593017ae00c7dd6858f8af2c3499161aea3dffcc97fc455eb7525fa5a13e31d5791662d0a531e32e9e94"
+
+	out := Compress(input)
+	if hex.EncodeToString(out[:]) != expected {
+		t.Errorf("Compress(0) = %x, want %s", out, expected)
+	}
+}
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		name string
+
+		input    string
+		expected string
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: "418b0a89a759d3a310475b211be2366efdee291c76d6062057a2b41b8f771848bf966e25055d61b49496316e467ab10c195a2004e3c4f200a0a67db1fc97a46c",
+		},
+		{
+			name:     "abc",
+			input:    "abc",
+			expected: "644bfdb56161783ad5ed251a552deda9a080d72bcb6989f160f737ae5f2511420c5acb74816ac31658e87dd70d02f52daefcb68218c65433d8ab85e43794ab3c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Sum([]byte(tt.input))
+			if hex.EncodeToString(out[:]) != tt.expected {
+				t.Errorf("Sum(%q) = %x, want %s", tt.input, out, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	message := make([]byte, 3*BlockSize+17)
+	if _, err := rand.Read(message); err != nil {
+		panic(err)
+	}
+
+	expected := Sum(message)
+
+	d := New()
+	for i := 0; i < len(message); i += 7 {
+		end := min(i+7, len(message))
+		d.Write(message[i:end])
+	}
+
+	if out := d.Sum(nil); !bytes.Equal(out, expected[:]) {
+		t.Errorf("Write in chunks = %x, want %x", out, expected)
+	}
+
+	// Sum must not change the state of the hash
+	if out := d.Sum(nil); !bytes.Equal(out, expected[:]) {
+		t.Errorf("second Sum = %x, want %x", out, expected)
+	}
+}
+
+// messages around the padding boundary must all hash differently
+func TestSumPadding(t *testing.T) {
+	seen := map[[Size]byte]int{}
+
+	for n := BlockSize - 10; n <= 2*BlockSize+1; n++ {
+		out := Sum(make([]byte, n))
+		if m, ok := seen[out]; ok {
+			t.Errorf("Sum of %d and %d zero bytes collide", m, n)
+		}
+		seen[out] = n
+	}
+}
+
+// The words of the state HSalsa20 outputs, in order.
+var hsalsaWords = [8]int{0, 5, 10, 15, 6, 7, 8, 9}
+
+// reference computes words 0, 5, 10, 15 and 6 to 9 of Compress with the
+// HSalsa20 of golang.org/x/crypto, which takes any constants: it is the
+// Salsa20 core without the feed-forward, with the 48 byte chunk split into
+// the key (words 1 to 4 and 11 to 14) and the input (words 6 to 9). The
+// other words come out of the same Salsa20 hash, which the salsa package
+// checks against the eSTREAM vectors.
+func reference(input [InputSize]byte) [8]uint32 {
+	var words [8]uint32
+
+	for i := 0; i < 4; i++ {
+		chunk := input[i*48 : i*48+48]
+
+		var in [16]byte
+		var key [32]byte
+		copy(in[:], chunk[16:32])
+		copy(key[:16], chunk[:16])
+		copy(key[16:], chunk[32:])
+
+		var out [32]byte
+		salsa.HSalsa20(&out, &in, &key, &constants[i])
+
+		// the feed-forward adds back the constants and the input
+		initial := [8]uint32{}
+		for j := 0; j < 4; j++ {
+			initial[j] = binary.LittleEndian.Uint32(constants[i][4*j:])
+			initial[4+j] = binary.LittleEndian.Uint32(in[4*j:])
+		}
+		for j := range words {
+			words[j] ^= binary.LittleEndian.Uint32(out[4*j:]) + initial[j]
+		}
+	}
+
+	return words
+}
+
+func TestReference(t *testing.T) {
+	inputs := make([][InputSize]byte, 8)
+	for i := 1; i < len(inputs); i++ {
+		if _, err := rand.Read(inputs[i][:]); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, input := range inputs {
+		out := Compress(input)
+		expected := reference(input)
+
+		for j, w := range hsalsaWords {
+			if got := binary.LittleEndian.Uint32(out[4*w:]); got != expected[j] {
+				t.Errorf("Compress(%x): word %d = %08x, want %08x", input[:8], w, got, expected[j])
+			}
+		}
+	}
+}
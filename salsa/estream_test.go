@@ -0,0 +1,119 @@
+package salsa
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// Values from the eSTREAM (ECRYPT) test vectors for Salsa20, the upstream
+// verified.test-vectors file:
+//
+//	https://www.ecrypt.eu.org/stream/svn/viewcvs.cgi/ecrypt/trunk/submissions/salsa20/full/verified.test-vectors?rev=210
+//
+// Only the vectors below are checked, not the whole file.
+
+// keystream encrypts length zero bytes with a 16 or 32 byte key.
+func keystream(key, iv []byte, length int) []byte {
+	if len(key) == 16 {
+		return Encrypt128((*[16]byte)(key), iv, make([]byte, length))
+	}
+	return Encrypt((*[32]byte)(key), iv, make([]byte, length))
+}
+
+// xorDigest is the xor of the 64 byte blocks of stream.
+func xorDigest(stream []byte) []byte {
+	digest := make([]byte, 64)
+	for i, b := range stream {
+		digest[i%64] ^= b
+	}
+	return digest
+}
+
+// The first vector of set 1 for both key sizes, from the upstream
+// verified.test-vectors. They pin the layout of 16 byte keys, which
+// golang.org/x/crypto can't check.
+func TestEstreamSet1(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		stream string
+		digest string
+	}{
+		{
+			name: "128 bits",
+			key:  "80000000000000000000000000000000",
+			stream: "4DFA5E481DA23EA09A31022050859936DA52FCEE218005164F267CB65F5CFD7F" +
+				"2B4F97E0FF16924A52DF269515110A07F9E460BC65EF95DA58F740B7D1DBB0AA",
+			digest: "F7A274D268316790A67EC058F45C0F2A067A99FCDE6236C0CEF8E056349FE54C" +
+				"5F13AC74D2539570FD34FEAB06C572053949B59585742181A5A760223AFA22D4",
+		},
+		{
+			name: "256 bits",
+			key:  "8000000000000000000000000000000000000000000000000000000000000000",
+			stream: "E3BE8FDD8BECA2E3EA8EF9475B29A6E7003951E1097A5C38D23B7A5FAD9F6844" +
+				"B22C97559E2723C7CBBD3FE4FC8D9A0744652A83E72A9C461876AF4D7EF1A117",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := keystream(decodeHex(tt.key), make([]byte, 8), 512)
+
+			if want := decodeHex(tt.stream); !slices.Equal(stream[:64], want) {
+				t.Errorf("stream[0..63] = %X, want %X", stream[:64], want)
+			}
+			if tt.digest == "" {
+				return
+			}
+			if got, want := xorDigest(stream), decodeHex(tt.digest); !slices.Equal(got, want) {
+				t.Errorf("xor-digest = %X, want %X", got, want)
+			}
+		})
+	}
+}
+
+// Set 6 for 256 bit keys from the upstream verified.test-vectors, as quoted by
+// the golang.org/x/crypto/salsa20 tests: the xor-digest of 131072 bytes of
+// keystream.
+func TestEstreamSet6(t *testing.T) {
+	tests := []struct {
+		key, iv, digest string
+	}{
+		{
+			"0053A6F94C9FF24598EB3E91E4378ADD3083D6297CCF2275C81B6EC11467BA0D", "0D74DB42A91077DE",
+			"C349B6A51A3EC9B712EAED3F90D8BCEE69B7628645F251A996F55260C62EF31FD6C6B0AEA94E136C9D984AD2DF3578F78E457527B03A0450580DD874F63B1AB9",
+		},
+		{
+			"0558ABFE51A4F74A9DF04396E93C8FE23588DB2E81D4277ACD2073C6196CBF12", "167DE44BB21980E7",
+			"C3EAAF32836BACE32D04E1124231EF47E101367D6305413A0EEB07C60698A2876E4D031870A739D6FFDDD208597AFF0A47AC17EDB0167DD67EBA84F1883D4DFD",
+		},
+		{
+			"0A5DB00356A9FC4FA2F5489BEE4194E73A8DE03386D92C7FD22578CB1E71C417", "1F86ED54BB2289F0",
+			"3CD23C3DC90201ACC0CF49B440B6C417F0DC8D8410A716D5314C059E14B1A8D9A9FB8EA3D9C8DAE12B21402F674AA95C67B1FC514E994C9D3F3A6E41DFF5BBA6",
+		},
+		{
+			"0F62B5085BAE0154A7FA4DA0F34699EC3F92E5388BDE3184D72A7DD02376C91C", "288FF65DC42B92F9",
+			"E00EBCCD70D69152725F9987982178A2E2E139C7BCBE04CA8A0E99E318D9AB76F988C8549F75ADD790BA4F81C176DA653C1A043F11A958E169B6D2319F4EEC1A",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("vector# %d", i), func(t *testing.T) {
+			stream := keystream(decodeHex(tt.key), decodeHex(tt.iv), 131072)
+
+			if got, want := xorDigest(stream), decodeHex(tt.digest); !slices.Equal(got, want) {
+				t.Errorf("xor-digest = %X, want %X", got, want)
+			}
+		})
+	}
+}
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+package salsa_test
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+// Without the feed-forward a Salsa20 block is just the permutation of the
+// initial state, and the permutation runs backwards as easily as forwards:
+// one block of keystream is enough to read the key.
+func ExampleInvertPermutation() {
+	key := [32]byte{}
+	copy(key[:], "a key nobody should see, ever!!!")
+	nonce := []byte("nonce 01")
+
+	// a broken Salsa20 that leaves out the feed-forward
+	state := salsa.State(&key, nonce, 7)
+	salsa.Permutation{}.Permute(&state, 20)
+	block := make([]byte, 64)
+	for i, w := range state {
+		binary.LittleEndian.PutUint32(block[i*4:], w)
+	}
+
+	// the attacker only has the block
+	var output [16]uint32
+	for i := range output {
+		output[i] = binary.LittleEndian.Uint32(block[i*4:])
+	}
+
+	initial := salsa.InvertPermutation(output, 20)
+
+	// the key is in words 1 to 4 and 11 to 14, nonce and counter in 6 to 9
+	recovered := make([]byte, 32)
+	for i, w := range []int{1, 2, 3, 4, 11, 12, 13, 14} {
+		binary.LittleEndian.PutUint32(recovered[i*4:], initial[w])
+	}
+	// "expand 32-byte k" on the diagonal shows the inversion worked
+	sigma := [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+	fmt.Println("constants:", [4]uint32{initial[0], initial[5], initial[10], initial[15]} == sigma)
+	fmt.Printf("key: %q\n", recovered)
+	fmt.Printf("nonce: %08x, counter: %d\n", initial[6:8], uint64(initial[9])<<32|uint64(initial[8]))
+
+	// with the feed-forward the output is the permutation plus the unknown
+	// input, so there is nothing to invert
+	original := salsa.BlockRounds(&key, nonce, 7, 20)
+	for i := range output {
+		output[i] = binary.LittleEndian.Uint32(original[i*4:])
+	}
+	initial = salsa.InvertPermutation(output, 20)
+	fmt.Println("constants of a real block:", [4]uint32{initial[0], initial[5], initial[10], initial[15]} == sigma)
+
+	// Output:
+	// constants: true
+	// key: "a key nobody should see, ever!!!"
+	// nonce: [636e6f6e 31302065], counter: 7
+	// constants of a real block: false
+}
@@ -1,40 +1,43 @@
 package salsa
 
 import (
+	"encoding/binary"
 	"math/bits"
+
+	"github.com/mario-areias/latin-dances-go/arx"
+	"github.com/mario-areias/latin-dances-go/trace"
 )
 
 func Encrypt(key *[32]byte, nonce, message []byte) []byte {
+	return xorKeyStream(key[:], nonce, message)
+}
+
+// Encrypt128 is Encrypt with a 16 byte key: the key fills both halves of the
+// key words and the diagonal holds tau, "expand 16-byte k", instead of sigma.
+// Half of the eSTREAM test vectors use it.
+func Encrypt128(key *[16]byte, nonce, message []byte) []byte {
+	return xorKeyStream(key[:], nonce, message)
+}
+
+func xorKeyStream(key, nonce, message []byte) []byte {
 	if len(nonce) != 8 {
 		panic("nonce must be 8 bytes")
 	}
 
-	counter := make([]byte, 8)
-	output := make([]byte, len(message))
-
-	for i := 0; i < len(message); i += 64 {
-		state := initState(key[:], append(nonce, counter...))
-		block := hash(state)
-		for j := 0; j < len(block) && i+j < len(message); j++ {
-			output[i+j] = message[i+j] ^ block[j]
-		}
+	d := design
+	if len(key) == 16 {
+		d, key = design128, append(key[:16:16], key...)
+	}
 
-		incrementByteArray(counter)
+	// the 64 bit counter starts at 0 and can't run out
+	output, err := d.XORKeyStream(key, nonce, 0, message)
+	if err != nil {
+		panic(err)
 	}
 
 	return output
 }
 
-// copy from go.crypto/salsa
-func incrementByteArray(byteArray []byte) {
-	u := uint32(1)
-	for i := 0; i < len(byteArray); i++ {
-		u += uint32(byteArray[i])
-		byteArray[i] = byte(u)
-		u >>= 8
-	}
-}
-
 func quarterRound(y0, y1, y2, y3 uint32) (uint32, uint32, uint32, uint32) {
 	var z0, z1, z2, z3 uint32
 	// z1 = y1 ^ ((y0 + y3) << 7)
@@ -48,18 +51,48 @@ func quarterRound(y0, y1, y2, y3 uint32) (uint32, uint32, uint32, uint32) {
 	return z0, z1, z2, z3
 }
 
+// design is Salsa20 as an arx design: constants on the diagonal, the key in
+// words 1 to 4 and 11 to 14, the nonce in 6 and 7 and a 64 bit counter in 8
+// and 9. It is cloned so nothing outside the package, not even arx.ColumnRow,
+// can change the cipher.
+var design = (&arx.Design{
+	Name:                "salsa",
+	Constants:           [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574},
+	ConstantWords:       [4]int{0, 5, 10, 15},
+	KeyWords:            [8]int{1, 2, 3, 4, 11, 12, 13, 14},
+	NonceWords:          []int{6, 7},
+	CounterWords:        []int{8, 9},
+	QuarterRound:        quarterRound,
+	InverseQuarterRound: inverseQuarterRound,
+	Schedule:            arx.ColumnRow,
+	Rounds:              20,
+}).Clone()
+
+// design128 is design with tau on the diagonal, for 16 byte keys repeated to
+// fill the key words.
+var design128 = func() *arx.Design {
+	d := design.Clone()
+	for i := range d.Constants {
+		d.Constants[i] = littleEndian(tau[4*i:])
+	}
+	return d
+}()
+
+// Design returns a copy of Salsa20 as an arx design, to analyse or to start a
+// new design from. Changing it doesn't change the cipher.
+func Design() arx.Design {
+	return *design.Clone()
+}
+
+// rowRound, columnRound and doubleRound are the functions of the spec, the
+// schedule of design.
+
 func rowRound(y []uint32) {
-	y[0], y[1], y[2], y[3] = quarterRound(y[0], y[1], y[2], y[3])
-	y[5], y[6], y[7], y[4] = quarterRound(y[5], y[6], y[7], y[4])
-	y[10], y[11], y[8], y[9] = quarterRound(y[10], y[11], y[8], y[9])
-	y[15], y[12], y[13], y[14] = quarterRound(y[15], y[12], y[13], y[14])
+	design.Round((*[16]uint32)(y), 1)
 }
 
 func columnRound(x []uint32) {
-	x[0], x[4], x[8], x[12] = quarterRound(x[0], x[4], x[8], x[12])
-	x[5], x[9], x[13], x[1] = quarterRound(x[5], x[9], x[13], x[1])
-	x[10], x[14], x[2], x[6] = quarterRound(x[10], x[14], x[2], x[6])
-	x[15], x[3], x[7], x[11] = quarterRound(x[15], x[3], x[7], x[11])
+	design.Round((*[16]uint32)(x), 0)
 }
 
 func doubleRound(x []uint32) {
@@ -67,49 +100,145 @@ func doubleRound(x []uint32) {
 	rowRound(x)
 }
 
+// Permutation is the Salsa20 permutation on its own, without the feed-forward
+// that hash adds at the end.
+type Permutation struct{}
+
+// Permute applies rounds rounds to state. Rounds alternate between column and
+// row rounds, so 20 rounds are the 10 double rounds of Salsa20.
+func (Permutation) Permute(state *[16]uint32, rounds int) {
+	design.Permute(state, rounds)
+}
+
+// Inverse undoes rounds of Permute: it takes the state after from rounds back
+// to the state after to rounds.
+func (Permutation) Inverse(state *[16]uint32, from, to int) {
+	design.Inverse(state, from, to)
+}
+
+// InvertPermutation returns the state Permute started from, given the state
+// after rounds rounds. It is why hash adds its input at the end: without the
+// feed-forward a single block gives the key away.
+func InvertPermutation(state [16]uint32, rounds int) [16]uint32 {
+	Permutation{}.Inverse(&state, rounds, 0)
+	return state
+}
+
+func inverseQuarterRound(z0, z1, z2, z3 uint32) (uint32, uint32, uint32, uint32) {
+	var y0, y1, y2, y3 uint32
+
+	// the same steps in reverse, each one only needs words it doesn't change
+	y0 = z0 ^ (bits.RotateLeft32(z3+z2, 18))
+	y3 = z3 ^ (bits.RotateLeft32(z2+z1, 13))
+	y2 = z2 ^ (bits.RotateLeft32(z1+y0, 9))
+	y1 = z1 ^ (bits.RotateLeft32(y0+y3, 7))
+
+	return y0, y1, y2, y3
+}
+
+func inverseRowRound(y []uint32) {
+	design.InverseRound((*[16]uint32)(y), 1)
+}
+
+func inverseColumnRound(x []uint32) {
+	design.InverseRound((*[16]uint32)(x), 0)
+}
+
+func inverseDoubleRound(x []uint32) {
+	inverseRowRound(x)
+	inverseColumnRound(x)
+}
+
 func littleEndian(b []byte) uint32 {
 	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
 }
 
+// BlockTrace returns the Salsa20 keystream block for key, nonce (8 bytes) and
+// counter, calling obs with the state after every quarter round, every round
+// and the feed-forward.
+func BlockTrace(key *[32]byte, nonce []byte, counter uint64, obs trace.Observer) []byte {
+	return observedHash(blockState(key, nonce, counter), 20, obs)
+}
+
+// BlockRounds returns the keystream block for key, nonce (8 bytes) and counter
+// using rounds rounds instead of 20. Odd round counts end with a column round.
+// Reduced rounds are only for cryptanalysis.
+func BlockRounds(key *[32]byte, nonce []byte, counter uint64, rounds int) []byte {
+	return observedHash(blockState(key, nonce, counter), rounds, nil)
+}
+
+// State returns the initial state of the block for key, nonce (8 bytes) and
+// counter as words.
+func State(key *[32]byte, nonce []byte, counter uint64) [16]uint32 {
+	return design.State(key[:], nonce, counter)
+}
+
+func blockState(key *[32]byte, nonce []byte, counter uint64) []byte {
+	if len(nonce) != 8 {
+		panic("nonce must be 8 bytes")
+	}
+
+	input := make([]byte, 16)
+	copy(input, nonce)
+	binary.LittleEndian.PutUint64(input[8:], counter)
+
+	return initState(key[:], input)
+}
+
 func hash(input []byte) []byte {
+	return observedHash(input, 20, nil)
+}
+
+func observedHash(input []byte, rounds int, obs trace.Observer) []byte {
 	// transform bytes in words
-	x := make([]uint32, 16)
-	for i := 0; i < 16; i++ {
-		x[i] = littleEndian(input[i*4 : i*4+4])
+	var x [16]uint32
+	for i := range x {
+		x[i] = littleEndian(input[i*4:])
 	}
 
-	// calculate 10 double rounds
-	z := make([]uint32, 16)
-	copy(z, x)
-	for i := 0; i < 10; i++ {
-		doubleRound(z)
-	}
+	// the rounds and the feed-forward, 10 double rounds for Salsa20
+	return arx.Bytes(design.Hash(x, rounds, obs))
+}
 
-	// concatenate the result
-	for i := 0; i < 16; i++ {
-		z[i] += x[i]
+// initState takes a 32 or a 16 byte key. A 16 byte key goes in both halves
+// with tau on the diagonal.
+func initState(key, nonce []byte) []byte {
+	constants, second := sigma, key[16:]
+	if len(key) == 16 {
+		constants, second = tau, key
 	}
 
-	// transform words in bytes
-	output := make([]byte, 64)
-	for i := 0; i < 16; i++ {
-		output[i*4] = byte(z[i])
-		output[i*4+1] = byte(z[i] >> 8)
-		output[i*4+2] = byte(z[i] >> 16)
-		output[i*4+3] = byte(z[i] >> 24)
-	}
+	input := make([]byte, 48)
+	copy(input[0:16], key[0:16])
+	copy(input[16:32], nonce)
+	copy(input[32:48], second)
 
-	return output
+	return layout(constants, input)
 }
 
-func initState(key, nonce []byte) []byte {
+// sigma is "expand 32-byte k", the constants Salsa20 places on the diagonal.
+var sigma = [16]byte{101, 120, 112, 97, 110, 100, 32, 51, 50, 45, 98, 121, 116, 101, 32, 107}
+
+// tau is "expand 16-byte k", the constants for 16 byte keys.
+var tau = [16]byte{101, 120, 112, 97, 110, 100, 32, 49, 54, 45, 98, 121, 116, 101, 32, 107}
+
+// layout places the four words of constants on the diagonal (words 0, 5, 10
+// and 15) and fills the other twelve words with input, in order.
+func layout(constants [16]byte, input []byte) []byte {
 	state := make([]byte, 64)
-	copy(state[0:4], []byte{101, 120, 112, 97})
-	copy(state[4:20], key[0:16])
-	copy(state[20:24], []byte{110, 100, 32, 51})
-	copy(state[24:40], nonce)
-	copy(state[40:44], []byte{50, 45, 98, 121})
-	copy(state[44:60], key[16:])
-	copy(state[60:64], []byte{116, 101, 32, 107})
+	copy(state[0:4], constants[0:4])
+	copy(state[4:20], input[0:16])
+	copy(state[20:24], constants[4:8])
+	copy(state[24:40], input[16:32])
+	copy(state[40:44], constants[8:12])
+	copy(state[44:60], input[32:48])
+	copy(state[60:64], constants[12:16])
 	return state
 }
+
+// Expand runs the Salsa20 hash over a state built from the diagonal constants
+// and 48 bytes of input. With sigma as constants and key||nonce||counter||key
+// as input this is exactly one block of Salsa20 keystream.
+func Expand(constants [16]byte, input [48]byte) []byte {
+	return hash(layout(constants, input[:]))
+}
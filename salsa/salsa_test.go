@@ -7,6 +7,7 @@ import (
 	"slices"
 	"testing"
 
+	"github.com/mario-areias/latin-dances-go/trace"
 	"golang.org/x/crypto/salsa20"
 )
 
@@ -305,6 +306,24 @@ func TestEncrypt(t *testing.T) {
 	}
 }
 
+// the nonce usually comes from a bigger buffer, encrypting must not write
+// the counter after it
+func TestEncryptNonceInBuffer(t *testing.T) {
+	key := [32]byte{1, 2, 3}
+	buffer := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	out := Encrypt(&key, buffer[:8], make([]byte, 100))
+	stdout := stdSalsa(&key, buffer[:8], make([]byte, 100))
+
+	if !bytes.Equal(out, stdout) {
+		t.Errorf("Encrypt() = %x, want %x", out, stdout)
+	}
+
+	if !bytes.Equal(buffer[8:], []byte{9, 10, 11, 12, 13, 14, 15, 16}) {
+		t.Errorf("Encrypt() changed the buffer after the nonce: %v", buffer)
+	}
+}
+
 func stdSalsa(key *[32]byte, nonce, message []byte) []byte {
 	out := make([]byte, len(message))
 
@@ -325,3 +344,205 @@ func printWords(x []uint32) string {
 	s += "\n"
 	return s
 }
+
+func TestExpand(t *testing.T) {
+	key := [32]byte{}
+	nonce := [8]byte{}
+
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		panic(err)
+	}
+
+	// key || nonce || counter 0 || key with sigma is the first keystream block
+	input := [48]byte{}
+	copy(input[0:16], key[0:16])
+	copy(input[16:24], nonce[:])
+	copy(input[32:48], key[16:])
+
+	out := Expand(sigma, input)
+	stdout := stdSalsa(&key, nonce[:], make([]byte, 64))
+	if !bytes.Equal(out, stdout) {
+		t.Errorf("Expand() = %x, want %x", out, stdout)
+	}
+}
+
+func TestPermute(t *testing.T) {
+	x := [16]uint32{0xde501066, 0x6f9eb8f7, 0xe4fbbd9b, 0x454e3f57,
+		0xb75540d3, 0x43e93a4c, 0x3a6f2aa0, 0x726d6b36,
+		0x9243f484, 0x9145d1e8, 0x4fa9d247, 0xdc8dee11,
+		0x054bf545, 0x254dd653, 0xd9421b6d, 0x67b276c1,
+	}
+
+	// two rounds are one double round
+	Permutation{}.Permute(&x, 2)
+
+	expected := [16]uint32{0xccaaf672, 0x23d960f7, 0x9153e63a, 0xcd9a60d0,
+		0x50440492, 0xf07cad19, 0xae344aa0, 0xdf4cfdfc,
+		0xca531c29, 0x8e7943db, 0xac1680cd, 0xd503ca00,
+		0xa74b2ad6, 0xbc331c5c, 0x1dda24c7, 0xee928277}
+
+	if x != expected {
+		t.Errorf("Permute() = %s, want %s", printWords(x[:]), printWords(expected[:]))
+	}
+
+	// one round is a column round
+	x = [16]uint32{0x00000001, 0x00000000, 0x00000000, 0x00000000,
+		0x00000001, 0x00000000, 0x00000000, 0x00000000,
+		0x00000001, 0x00000000, 0x00000000, 0x00000000,
+		0x00000001, 0x00000000, 0x00000000, 0x00000000}
+
+	Permutation{}.Permute(&x, 1)
+
+	expected = [16]uint32{0x10090288, 0x00000000, 0x00000000, 0x00000000,
+		0x00000101, 0x00000000, 0x00000000, 0x00000000,
+		0x00020401, 0x00000000, 0x00000000, 0x00000000,
+		0x40a04001, 0x00000000, 0x00000000, 0x00000000}
+
+	if x != expected {
+		t.Errorf("Permute() = %s, want %s", printWords(x[:]), printWords(expected[:]))
+	}
+}
+
+func TestInverse(t *testing.T) {
+	key := [32]byte{}
+	nonce := [8]byte{}
+
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		panic(err)
+	}
+
+	initial := State(&key, nonce[:], 7)
+
+	// the state is the block without the feed-forward
+	z := initial
+	Permutation{}.Permute(&z, 20)
+	block := BlockRounds(&key, nonce[:], 7, 20)
+	for i := range z {
+		if z[i]+initial[i] != littleEndian(block[i*4:]) {
+			t.Fatalf("State: word %d doesn't match BlockRounds", i)
+		}
+	}
+
+	middle := initial
+	Permutation{}.Permute(&middle, 5)
+
+	Permutation{}.Inverse(&z, 20, 5)
+	if z != middle {
+		t.Errorf("Inverse(20, 5) = %s, want %s", printWords(z[:]), printWords(middle[:]))
+	}
+
+	Permutation{}.Inverse(&z, 5, 0)
+	if z != initial {
+		t.Errorf("Inverse(5, 0) = %s, want %s", printWords(z[:]), printWords(initial[:]))
+	}
+}
+
+func TestInverseRounds(t *testing.T) {
+	state := []uint32{0xde501066, 0x6f9eb8f7, 0xe4fbbd9b, 0x454e3f57,
+		0xb75540d3, 0x43e93a4c, 0x3a6f2aa0, 0x726d6b36,
+		0x9243f484, 0x9145d1e8, 0x4fa9d247, 0xdc8dee11,
+		0x054bf545, 0x254dd653, 0xd9421b6d, 0x67b276c1}
+
+	y0, y1, y2, y3 := quarterRound(state[0], state[1], state[2], state[3])
+	if y0, y1, y2, y3 := inverseQuarterRound(y0, y1, y2, y3); y0 != state[0] || y1 != state[1] || y2 != state[2] || y3 != state[3] {
+		t.Errorf("inverseQuarterRound() = %08x %08x %08x %08x", y0, y1, y2, y3)
+	}
+
+	for _, round := range []struct {
+		name             string
+		forward, inverse func([]uint32)
+	}{
+		{"column", columnRound, inverseColumnRound},
+		{"row", rowRound, inverseRowRound},
+		{"double", doubleRound, inverseDoubleRound},
+	} {
+		x := slices.Clone(state)
+		round.forward(x)
+		round.inverse(x)
+
+		if !slices.Equal(x, state) {
+			t.Errorf("inverse %s round = %s, want %s", round.name, printWords(x), printWords(state))
+		}
+	}
+}
+
+func TestBlockTrace(t *testing.T) {
+	key := [32]byte{}
+	nonce := [8]byte{}
+
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		panic(err)
+	}
+
+	var steps []trace.Step
+	out := BlockTrace(&key, nonce[:], 1, trace.Collect(&steps))
+
+	// second block of keystream
+	stdout := stdSalsa(&key, nonce[:], make([]byte, 128))[64:]
+	if !bytes.Equal(out, stdout) {
+		t.Errorf("BlockTrace() = %x, want %x", out, stdout)
+	}
+
+	if len(steps) != 1+20*5+1 {
+		t.Fatalf("BlockTrace() got %d steps, want %d", len(steps), 1+20*5+1)
+	}
+
+	// every round must match the unobserved version
+	x := steps[0].State
+	for i := 1; i <= 20; i++ {
+		step := steps[i*5]
+		if i%2 == 1 {
+			columnRound(x[:])
+		} else {
+			rowRound(x[:])
+		}
+
+		if step.Round != i || step.State != x {
+			t.Errorf("BlockTrace() round %d = %s, want %s", step.Round, printWords(step.State[:]), printWords(x[:]))
+		}
+	}
+
+	if steps[10].Kind != trace.RowRound || steps[5].Kind != trace.ColumnRound {
+		t.Errorf("BlockTrace() rounds are %v and %v", steps[5].Kind, steps[10].Kind)
+	}
+
+	last := steps[len(steps)-1]
+	if last.Kind != trace.FeedForward {
+		t.Errorf("BlockTrace() last step is %v", last.Kind)
+	}
+}
+
+func TestBlockRounds(t *testing.T) {
+	key := [32]byte{1, 2, 3}
+	nonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	stdout := stdSalsa(&key, nonce, make([]byte, 64*3))[128:]
+	if out := BlockRounds(&key, nonce, 2, 20); !bytes.Equal(out, stdout) {
+		t.Errorf("BlockRounds(20) = %x, want %x", out, stdout)
+	}
+
+	// two rounds are one double round
+	state := blockState(&key, nonce, 2)
+	x := make([]uint32, 16)
+	for i := 0; i < 16; i++ {
+		x[i] = littleEndian(state[i*4 : i*4+4])
+	}
+	z := slices.Clone(x)
+	doubleRound(z)
+
+	out := BlockRounds(&key, nonce, 2, 2)
+	for i := 0; i < 16; i++ {
+		if littleEndian(out[i*4:i*4+4]) != z[i]+x[i] {
+			t.Errorf("BlockRounds(2) word %d = %08x, want %08x", i, littleEndian(out[i*4:i*4+4]), z[i]+x[i])
+		}
+	}
+}
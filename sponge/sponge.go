@@ -0,0 +1,203 @@
+package sponge
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// Permutation is a 512-bit permutation such as chacha.Permutation or
+// salsa.Permutation.
+type Permutation interface {
+	Permute(state *[16]uint32, rounds int)
+}
+
+const (
+	// Rate is how many bytes of the state are read and written between calls
+	// to the permutation. The other 32 bytes are the capacity.
+	Rate = 32
+	// Rounds used by Hash, MAC, Seal and Open.
+	Rounds = 20
+	// TagSize of MAC and Seal.
+	TagSize = 16
+)
+
+// Domain separation bytes, xored into the state together with the padding
+// every time a phase ends.
+const (
+	domainSqueeze byte = 0x01
+	domainHeader  byte = 0x02
+	domainAAD     byte = 0x03
+	domainMessage byte = 0x04
+)
+
+// Sponge is a duplex object over a 512-bit permutation.
+//
+// Data is always xored in or read out of the first Rate bytes of the state
+// (little endian words). Every time the rate is full the state is permuted.
+type Sponge struct {
+	p      Permutation
+	rounds int
+
+	state     [16]uint32
+	pos       int
+	squeezing bool
+}
+
+// New returns an empty sponge using rounds rounds of p.
+func New(p Permutation, rounds int) *Sponge {
+	s := &Sponge{p: p, rounds: rounds}
+
+	// the all zero state is a fixed point of both ChaCha and Salsa, so start
+	// with "expand 32-byte k" in the capacity
+	s.state[12] = 0x61707865
+	s.state[13] = 0x3320646e
+	s.state[14] = 0x79622d32
+	s.state[15] = 0x6b206574
+
+	return s
+}
+
+// Absorb xors data into the state.
+func (s *Sponge) Absorb(data []byte) {
+	if s.squeezing {
+		s.permute()
+		s.squeezing = false
+	}
+
+	for _, b := range data {
+		s.xorByte(s.pos, b)
+		s.advance()
+	}
+}
+
+// Squeeze fills out with output. The first squeeze after absorbing pads what
+// was absorbed.
+func (s *Sponge) Squeeze(out []byte) {
+	if !s.squeezing {
+		s.pad(domainSqueeze)
+		s.squeezing = true
+	}
+
+	for i := range out {
+		out[i] = s.byteAt(s.pos)
+		s.advance()
+	}
+}
+
+// Encrypt xors plaintext with the rate and absorbs the resulting ciphertext.
+func (s *Sponge) Encrypt(plaintext []byte) []byte {
+	cipher := make([]byte, len(plaintext))
+
+	for i, b := range plaintext {
+		cipher[i] = b ^ s.byteAt(s.pos)
+		s.setByte(s.pos, cipher[i])
+		s.advance()
+	}
+
+	return cipher
+}
+
+// Decrypt is the inverse of Encrypt.
+func (s *Sponge) Decrypt(cipher []byte) []byte {
+	plaintext := make([]byte, len(cipher))
+
+	for i, b := range cipher {
+		plaintext[i] = b ^ s.byteAt(s.pos)
+		s.setByte(s.pos, b)
+		s.advance()
+	}
+
+	return plaintext
+}
+
+// pad ends the current phase: it xors the domain byte after the data, sets the
+// last bit of the rate and permutes.
+func (s *Sponge) pad(domain byte) {
+	s.xorByte(s.pos, domain)
+	s.xorByte(Rate-1, 0x80)
+	s.permute()
+}
+
+func (s *Sponge) advance() {
+	s.pos++
+	if s.pos == Rate {
+		s.permute()
+	}
+}
+
+func (s *Sponge) permute() {
+	s.p.Permute(&s.state, s.rounds)
+	s.pos = 0
+}
+
+func (s *Sponge) byteAt(i int) byte {
+	return byte(s.state[i/4] >> (8 * (i % 4)))
+}
+
+func (s *Sponge) xorByte(i int, b byte) {
+	s.state[i/4] ^= uint32(b) << (8 * (i % 4))
+}
+
+func (s *Sponge) setByte(i int, b byte) {
+	s.xorByte(i, s.byteAt(i)^b)
+}
+
+// Hash returns a 32 byte digest of data.
+func Hash(p Permutation, data []byte) [32]byte {
+	s := New(p, Rounds)
+	s.Absorb(data)
+
+	var out [32]byte
+	s.Squeeze(out[:])
+	return out
+}
+
+// MAC returns a tag of data under key.
+func MAC(p Permutation, key [32]byte, data []byte) []byte {
+	s := New(p, Rounds)
+	s.Absorb(key[:])
+	s.pad(domainHeader)
+	s.Absorb(data)
+
+	tag := make([]byte, TagSize)
+	s.Squeeze(tag)
+	return tag
+}
+
+// Seal encrypts and authenticates plaintext and authenticates aad. The nonce
+// must never repeat for the same key.
+func Seal(p Permutation, key [32]byte, nonce [16]byte, plaintext, aad []byte) ([]byte, []byte) {
+	s := start(p, key, nonce, aad)
+	cipher := s.Encrypt(plaintext)
+	s.pad(domainMessage)
+
+	tag := make([]byte, TagSize)
+	s.Squeeze(tag)
+	return cipher, tag
+}
+
+// Open decrypts cipher if tag is valid for it and aad.
+func Open(p Permutation, key [32]byte, nonce [16]byte, cipher, tag, aad []byte) ([]byte, error) {
+	s := start(p, key, nonce, aad)
+	message := s.Decrypt(cipher)
+	s.pad(domainMessage)
+
+	calculateTag := make([]byte, TagSize)
+	s.Squeeze(calculateTag)
+
+	if subtle.ConstantTimeCompare(calculateTag, tag) != 1 {
+		return nil, errors.New("invalid tag")
+	}
+
+	return message, nil
+}
+
+func start(p Permutation, key [32]byte, nonce [16]byte, aad []byte) *Sponge {
+	s := New(p, Rounds)
+	s.Absorb(key[:])
+	s.Absorb(nonce[:])
+	s.pad(domainHeader)
+	s.Absorb(aad)
+	s.pad(domainAAD)
+	return s
+}
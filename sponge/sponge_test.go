@@ -0,0 +1,147 @@
+package sponge
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+var permutations = []struct {
+	name string
+	p    Permutation
+}{
+	{name: "chacha", p: chacha.Permutation{}},
+	{name: "salsa", p: salsa.Permutation{}},
+}
+
+func TestHash(t *testing.T) {
+	for _, tt := range permutations {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := map[[32]byte]int{}
+
+			// lengths around the rate must all hash differently
+			for n := 0; n <= 2*Rate+1; n++ {
+				out := Hash(tt.p, make([]byte, n))
+				if m, ok := seen[out]; ok {
+					t.Errorf("Hash of %d and %d zero bytes collide", m, n)
+				}
+				seen[out] = n
+			}
+
+			if Hash(tt.p, []byte("abc")) != Hash(tt.p, []byte("abc")) {
+				t.Errorf("Hash is not deterministic")
+			}
+		})
+	}
+
+	if Hash(chacha.Permutation{}, []byte("abc")) == Hash(salsa.Permutation{}, []byte("abc")) {
+		t.Errorf("chacha and salsa hashes are equal")
+	}
+}
+
+func TestAbsorbSqueezeInChunks(t *testing.T) {
+	data := make([]byte, 3*Rate+5)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+
+	for _, tt := range permutations {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.p, Rounds)
+			s.Absorb(data)
+			expected := make([]byte, 100)
+			s.Squeeze(expected)
+
+			s = New(tt.p, Rounds)
+			for i := 0; i < len(data); i += 7 {
+				s.Absorb(data[i:min(i+7, len(data))])
+			}
+			out := make([]byte, 100)
+			for i := 0; i < len(out); i += 9 {
+				s.Squeeze(out[i:min(i+9, len(out))])
+			}
+
+			if !bytes.Equal(out, expected) {
+				t.Errorf("Squeeze in chunks = %x, want %x", out, expected)
+			}
+		})
+	}
+}
+
+func TestMAC(t *testing.T) {
+	key := [32]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+
+	for _, tt := range permutations {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := MAC(tt.p, key, []byte("message"))
+
+			if !bytes.Equal(tag, MAC(tt.p, key, []byte("message"))) {
+				t.Errorf("MAC is not deterministic")
+			}
+
+			if bytes.Equal(tag, MAC(tt.p, key, []byte("messagf"))) {
+				t.Errorf("MAC does not depend on the message")
+			}
+
+			other := key
+			other[31] ^= 1
+			if bytes.Equal(tag, MAC(tt.p, other, []byte("message"))) {
+				t.Errorf("MAC does not depend on the key")
+			}
+		})
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := [32]byte{}
+	nonce := [16]byte{}
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		panic(err)
+	}
+
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	aad := []byte{0x50, 0x51, 0x52, 0x53, 0xc0, 0xc1, 0xc2, 0xc3}
+
+	for _, tt := range permutations {
+		t.Run(tt.name, func(t *testing.T) {
+			cipher, tag := Seal(tt.p, key, nonce, plaintext, aad)
+
+			if bytes.Equal(cipher, plaintext) {
+				t.Errorf("Seal did not encrypt")
+			}
+
+			message, err := Open(tt.p, key, nonce, cipher, tag, aad)
+			if err != nil {
+				t.Errorf("Open: %s", err)
+			}
+			if !bytes.Equal(message, plaintext) {
+				t.Errorf("Open = %s, want %s", message, plaintext)
+			}
+
+			tampered := bytes.Clone(cipher)
+			tampered[0] ^= 1
+			if _, err := Open(tt.p, key, nonce, tampered, tag, aad); err == nil {
+				t.Errorf("Open accepted a tampered cipher")
+			}
+
+			if _, err := Open(tt.p, key, nonce, cipher, tag, []byte("other")); err == nil {
+				t.Errorf("Open accepted a different aad")
+			}
+
+			other := nonce
+			other[0] ^= 1
+			if _, err := Open(tt.p, key, other, cipher, tag, aad); err == nil {
+				t.Errorf("Open accepted a different nonce")
+			}
+		})
+	}
+}
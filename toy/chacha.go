@@ -0,0 +1,149 @@
+package toy
+
+import (
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/arx"
+)
+
+// ChaCha with small words, made by NewChaCha. Like the original ChaCha, and
+// unlike RFC 8439, words 12 and 13 are the counter and words 14 and 15 the
+// nonce, so the key is 8 words, the nonce and the counter 2 words each: with
+// 4 bit words a one word counter would only count 16 blocks. The sizes depend
+// on the words, so keys and nonces are slices instead of the arrays of package
+// chacha.
+type ChaCha struct {
+	w words
+	// design runs the rounds with the quarter rounds of w
+	design *arx.Design
+}
+
+// rotations of the 32 bit quarter round
+var chachaRotations = [4]int{16, 12, 8, 7}
+
+// NewChaCha returns ChaCha with bits bit words, 4, 8, 16 or 32.
+func NewChaCha(bits int) ChaCha {
+	c := ChaCha{w: newWords(bits)}
+	c.design = &arx.Design{
+		Name:                fmt.Sprintf("chacha%d", bits),
+		QuarterRound:        c.QuarterRound,
+		InverseQuarterRound: c.InverseQuarterRound,
+		Schedule:            arx.ColumnDiagonal,
+		Rounds:              20,
+	}
+	return c
+}
+
+// QuarterRound is the ChaCha quarter round on the words of c.
+func (c ChaCha) QuarterRound(a, b, cc, d uint32) (uint32, uint32, uint32, uint32) {
+	w := c.w
+	r := w.chacha
+
+	a = w.add(a, b)
+	d = w.rotl(d^a, r[0])
+	cc = w.add(cc, d)
+	b = w.rotl(b^cc, r[1])
+	a = w.add(a, b)
+	d = w.rotl(d^a, r[2])
+	cc = w.add(cc, d)
+	b = w.rotl(b^cc, r[3])
+
+	return a, b, cc, d
+}
+
+// InverseQuarterRound undoes QuarterRound.
+func (c ChaCha) InverseQuarterRound(a, b, cc, d uint32) (uint32, uint32, uint32, uint32) {
+	w := c.w
+	r := w.chacha
+
+	b = w.rotl(b, w.width-r[3]) ^ cc
+	cc = w.sub(cc, d)
+	d = w.rotl(d, w.width-r[2]) ^ a
+	a = w.sub(a, b)
+	b = w.rotl(b, w.width-r[1]) ^ cc
+	cc = w.sub(cc, d)
+	d = w.rotl(d, w.width-r[0]) ^ a
+	a = w.sub(a, b)
+
+	return a, b, cc, d
+}
+
+// InnerBlock is a column round followed by a diagonal round.
+func (c ChaCha) InnerBlock(state *[16]uint32) {
+	c.design.Round(state, 0)
+	c.design.Round(state, 1)
+}
+
+// Permute applies rounds rounds to state, alternating column and diagonal
+// rounds.
+func (c ChaCha) Permute(state *[16]uint32, rounds int) {
+	c.design.Permute(state, rounds)
+}
+
+// Inverse takes the state after from rounds of Permute back to the state
+// after to rounds.
+func (c ChaCha) Inverse(state *[16]uint32, from, to int) {
+	c.design.Inverse(state, from, to)
+}
+
+// State returns the initial state for key (8 words), nonce (2 words) and
+// counter.
+func (c ChaCha) State(key, nonce []byte, counter uint64) [16]uint32 {
+	w := c.w
+
+	sigma := w.sigma()
+
+	var state [16]uint32
+	copy(state[0:4], sigma[:])
+	copy(state[4:12], w.unpack(key, 8))
+	state[12], state[13] = w.counter(counter)
+	copy(state[14:16], w.unpack(nonce, 2))
+
+	return state
+}
+
+// BlockRounds returns the keystream block for key, nonce and counter with
+// rounds rounds.
+func (c ChaCha) BlockRounds(key, nonce []byte, counter uint64, rounds int) []byte {
+	initial := c.State(key, nonce, counter)
+	state := initial
+	c.Permute(&state, rounds)
+
+	return c.w.block(initial, state)
+}
+
+// Block is BlockRounds with 20 rounds.
+func (c ChaCha) Block(key, nonce []byte, counter uint64) []byte {
+	return c.BlockRounds(key, nonce, counter, 20)
+}
+
+// Encrypt XORs message with the keystream, starting at counter 1 like
+// chacha.Encrypt. With 32 bit words it is chacha.Encrypt with four zero bytes
+// before the nonce.
+func (c ChaCha) Encrypt(key, nonce, message []byte) []byte {
+	return xorKeyStream(message, c.w.bytes(16), 1, func(counter uint64) []byte {
+		return c.Block(key, nonce, counter)
+	})
+}
+
+// QuarterRoundCycles returns how many cycles of each length the quarter round
+// has as a permutation of 4 words. Only 4 bit words are small enough.
+func (c ChaCha) QuarterRoundCycles() (map[uint64]uint64, error) {
+	return c.w.quarterRoundCycles(c.QuarterRound)
+}
+
+// Cipher describes the toy for the analysis tools.
+func (c ChaCha) Cipher() analysis.Cipher {
+	w := c.w
+
+	return analysis.Cipher{
+		Name:        c.design.Name,
+		KeySize:     w.bytes(8),
+		NonceSize:   w.bytes(2),
+		CounterSize: w.bytes(2),
+		OutputSize:  w.bytes(16),
+		Rounds:      20,
+		Block:       blockFunc(c.BlockRounds),
+	}
+}
@@ -0,0 +1,137 @@
+package toy
+
+import (
+	"fmt"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/arx"
+)
+
+// Salsa is Salsa20 with small words, made by NewSalsa: the key is 8 words,
+// the nonce and the counter 2 words each.
+type Salsa struct {
+	w words
+	// design runs the rounds with the quarter rounds of w
+	design *arx.Design
+}
+
+// rotations of the 32 bit quarter round
+var salsaRotations = [4]int{7, 9, 13, 18}
+
+// NewSalsa returns Salsa with bits bit words, 4, 8, 16 or 32.
+func NewSalsa(bits int) Salsa {
+	s := Salsa{w: newWords(bits)}
+	s.design = &arx.Design{
+		Name:                fmt.Sprintf("salsa%d", bits),
+		QuarterRound:        s.QuarterRound,
+		InverseQuarterRound: s.InverseQuarterRound,
+		Schedule:            arx.ColumnRow,
+		Rounds:              20,
+	}
+	return s
+}
+
+// QuarterRound is the Salsa20 quarter round on the words of s.
+func (s Salsa) QuarterRound(y0, y1, y2, y3 uint32) (uint32, uint32, uint32, uint32) {
+	w := s.w
+	r := w.salsa
+
+	y1 ^= w.rotl(w.add(y0, y3), r[0])
+	y2 ^= w.rotl(w.add(y1, y0), r[1])
+	y3 ^= w.rotl(w.add(y2, y1), r[2])
+	y0 ^= w.rotl(w.add(y3, y2), r[3])
+
+	return y0, y1, y2, y3
+}
+
+// InverseQuarterRound undoes QuarterRound.
+func (s Salsa) InverseQuarterRound(z0, z1, z2, z3 uint32) (uint32, uint32, uint32, uint32) {
+	w := s.w
+	r := w.salsa
+
+	z0 ^= w.rotl(w.add(z3, z2), r[3])
+	z3 ^= w.rotl(w.add(z2, z1), r[2])
+	z2 ^= w.rotl(w.add(z1, z0), r[1])
+	z1 ^= w.rotl(w.add(z0, z3), r[0])
+
+	return z0, z1, z2, z3
+}
+
+// DoubleRound is a column round followed by a row round.
+func (s Salsa) DoubleRound(state *[16]uint32) {
+	s.design.Round(state, 0)
+	s.design.Round(state, 1)
+}
+
+// Permute applies rounds rounds to state, alternating column and row rounds.
+func (s Salsa) Permute(state *[16]uint32, rounds int) {
+	s.design.Permute(state, rounds)
+}
+
+// Inverse takes the state after from rounds of Permute back to the state
+// after to rounds.
+func (s Salsa) Inverse(state *[16]uint32, from, to int) {
+	s.design.Inverse(state, from, to)
+}
+
+// State returns the initial state for key (8 words), nonce (2 words) and
+// counter: constants on the diagonal, the key in words 1 to 4 and 11 to 14,
+// the nonce in 6 and 7 and the counter in 8 and 9.
+func (s Salsa) State(key, nonce []byte, counter uint64) [16]uint32 {
+	w := s.w
+	k := w.unpack(key, 8)
+	sigma := w.sigma()
+
+	var state [16]uint32
+	state[0], state[5], state[10], state[15] = sigma[0], sigma[1], sigma[2], sigma[3]
+	copy(state[1:5], k[:4])
+	copy(state[6:8], w.unpack(nonce, 2))
+	state[8], state[9] = w.counter(counter)
+	copy(state[11:15], k[4:])
+
+	return state
+}
+
+// BlockRounds returns the keystream block for key, nonce and counter with
+// rounds rounds.
+func (s Salsa) BlockRounds(key, nonce []byte, counter uint64, rounds int) []byte {
+	initial := s.State(key, nonce, counter)
+	state := initial
+	s.Permute(&state, rounds)
+
+	return s.w.block(initial, state)
+}
+
+// Block is BlockRounds with 20 rounds.
+func (s Salsa) Block(key, nonce []byte, counter uint64) []byte {
+	return s.BlockRounds(key, nonce, counter, 20)
+}
+
+// Encrypt XORs message with the keystream, starting at counter 0 like
+// salsa.Encrypt.
+func (s Salsa) Encrypt(key, nonce, message []byte) []byte {
+	return xorKeyStream(message, s.w.bytes(16), 0, func(counter uint64) []byte {
+		return s.Block(key, nonce, counter)
+	})
+}
+
+// QuarterRoundCycles returns how many cycles of each length the quarter round
+// has as a permutation of 4 words. Only 4 bit words are small enough.
+func (s Salsa) QuarterRoundCycles() (map[uint64]uint64, error) {
+	return s.w.quarterRoundCycles(s.QuarterRound)
+}
+
+// Cipher describes the toy for the analysis tools.
+func (s Salsa) Cipher() analysis.Cipher {
+	w := s.w
+
+	return analysis.Cipher{
+		Name:        s.design.Name,
+		KeySize:     w.bytes(8),
+		NonceSize:   w.bytes(2),
+		CounterSize: w.bytes(2),
+		OutputSize:  w.bytes(16),
+		Rounds:      20,
+		Block:       blockFunc(s.BlockRounds),
+	}
+}
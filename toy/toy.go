@@ -0,0 +1,185 @@
+// Package toy has scaled-down ChaCha and Salsa with 4, 8 or 16 bit words, so
+// whole key spaces and permutations are small enough to enumerate. The
+// rotations scale with the word size, and with 32 bit words the toys are the
+// real ciphers.
+//
+// Toys are for cryptanalysis only, 4 bit words mean a 32 bit key.
+package toy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+)
+
+// words does the arithmetic on words of width bits, kept in uint32.
+type words struct {
+	width int
+	mask  uint32
+	// the rotations of the quarter rounds, scaled to width bits
+	chacha, salsa [4]int
+}
+
+// sizes has the words of each width, made once so the quarter rounds don't
+// scale their rotations on every call.
+var sizes = func() map[int]words {
+	sizes := map[int]words{}
+	for _, width := range []int{4, 8, 16, 32} {
+		w := words{width: width, mask: uint32(1<<width - 1)}
+		w.chacha, w.salsa = w.scale(chachaRotations), w.scale(salsaRotations)
+		sizes[width] = w
+	}
+	return sizes
+}()
+
+func newWords(width int) words {
+	w, ok := sizes[width]
+	if !ok {
+		panic(fmt.Sprintf("toy: words of %d bits, must be 4, 8, 16 or 32", width))
+	}
+	return w
+}
+
+func (w words) rotl(x uint32, r int) uint32 {
+	return (x<<r | x>>(w.width-r)) & w.mask
+}
+
+func (w words) add(x, y uint32) uint32 {
+	return (x + y) & w.mask
+}
+
+func (w words) sub(x, y uint32) uint32 {
+	return (x - y) & w.mask
+}
+
+// scale turns the rotations of 32 bit words into rotations of w bits, at
+// least 1. With 4 bit words some of them repeat.
+func (w words) scale(rotations [4]int) [4]int {
+	var r [4]int
+	for i, rot := range rotations {
+		r[i] = max(1, int(math.Round(float64(rot*w.width)/32)))
+	}
+	return r
+}
+
+// sigma is "expand 32-byte k", cut to the low bits of each word.
+func (w words) sigma() [4]uint32 {
+	s := [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+	for i := range s {
+		s[i] &= w.mask
+	}
+	return s
+}
+
+// bytes is the size of n words in bytes.
+func (w words) bytes(n int) int {
+	return n * w.width / 8
+}
+
+// unpack reads n words from b, little endian: with 4 bit words the first word
+// is the low nibble of the first byte.
+func (w words) unpack(b []byte, n int) []uint32 {
+	out := make([]uint32, n)
+	for i := range out {
+		for j := 0; j < w.width; j++ {
+			bit := i*w.width + j
+			out[i] |= uint32(b[bit/8]>>(bit%8)&1) << j
+		}
+	}
+	return out
+}
+
+// pack is the inverse of unpack.
+func (w words) pack(x []uint32) []byte {
+	out := make([]byte, w.bytes(len(x)))
+	for i, v := range x {
+		for j := 0; j < w.width; j++ {
+			bit := i*w.width + j
+			out[bit/8] |= byte(v>>j&1) << (bit % 8)
+		}
+	}
+	return out
+}
+
+// counter splits a counter into two words, low word first.
+func (w words) counter(counter uint64) (uint32, uint32) {
+	return uint32(counter) & w.mask, uint32(counter>>w.width) & w.mask
+}
+
+// block adds the feed-forward to the permuted state and packs it.
+func (w words) block(initial, permuted [16]uint32) []byte {
+	for i := range permuted {
+		permuted[i] = w.add(permuted[i], initial[i])
+	}
+	return w.pack(permuted[:])
+}
+
+// xorKeyStream encrypts message with the blocks of counter first, first + 1
+// and so on.
+func xorKeyStream(message []byte, size int, first uint64, block func(counter uint64) []byte) []byte {
+	out := make([]byte, len(message))
+	for i := 0; i < len(message); i += size {
+		stream := block(first + uint64(i/size))
+		for j := 0; j < size && i+j < len(message); j++ {
+			out[i+j] = message[i+j] ^ stream[j]
+		}
+	}
+	return out
+}
+
+// Cycles returns how many cycles of each length the permutation next of
+// [0, n) has. It keeps one bit per element, so n must fit in memory.
+func Cycles(n uint64, next func(uint64) uint64) map[uint64]uint64 {
+	seen := make([]uint64, (n+63)/64)
+	cycles := map[uint64]uint64{}
+
+	for start := uint64(0); start < n; start++ {
+		if seen[start/64]>>(start%64)&1 == 1 {
+			continue
+		}
+
+		length := uint64(0)
+		for x := start; seen[x/64]>>(x%64)&1 == 0; x = next(x) {
+			seen[x/64] |= 1 << (x % 64)
+			length++
+		}
+		cycles[length]++
+	}
+
+	return cycles
+}
+
+// quarterRoundCycles is Cycles of quarterRound on 4 words packed in a uint64.
+func (w words) quarterRoundCycles(quarterRound func(a, b, c, d uint32) (uint32, uint32, uint32, uint32)) (map[uint64]uint64, error) {
+	if w.width > 4 {
+		return nil, fmt.Errorf("toy: %d states are too many to walk, only 4 bit words", uint64(1)<<(4*w.width))
+	}
+
+	shift := w.width
+	mask := uint64(w.mask)
+
+	return Cycles(1<<(4*w.width), func(x uint64) uint64 {
+		a, b, c, d := quarterRound(uint32(x&mask), uint32(x>>shift&mask), uint32(x>>(2*shift)&mask), uint32(x>>(3*shift)&mask))
+		return uint64(a) | uint64(b)<<shift | uint64(c)<<(2*shift) | uint64(d)<<(3*shift)
+	}), nil
+}
+
+// blockFunc adapts BlockRounds to analysis.Cipher.
+func blockFunc(blockRounds func(key, nonce []byte, counter uint64, rounds int) []byte) func(key, nonce, counter []byte, rounds int) []byte {
+	return func(key, nonce, counter []byte, rounds int) []byte {
+		c := make([]byte, 8)
+		copy(c, counter)
+		return blockRounds(key, nonce, binary.LittleEndian.Uint64(c), rounds)
+	}
+}
+
+// The toys with 4, 8 and 16 bit words are registered in analysis as chacha4,
+// chacha8, chacha16, salsa4, salsa8 and salsa16.
+func init() {
+	for _, width := range []int{4, 8, 16} {
+		analysis.Register(NewChaCha(width).Cipher())
+		analysis.Register(NewSalsa(width).Cipher())
+	}
+}
@@ -0,0 +1,214 @@
+package toy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/mario-areias/latin-dances-go/analysis"
+	"github.com/mario-areias/latin-dances-go/analysis/avalanche"
+	"github.com/mario-areias/latin-dances-go/chacha"
+	"github.com/mario-areias/latin-dances-go/salsa"
+)
+
+var widths = []int{4, 8, 16, 32}
+
+func randomBytes(rng *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+	return b
+}
+
+// With 32 bit words the toys are the real ciphers.
+func TestRealCiphers(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 0))
+	key := [32]byte(randomBytes(rng, 32))
+	nonce := randomBytes(rng, 8)
+	counter := rng.Uint64()
+
+	// RFC 8439 puts the high word of the counter in the nonce
+	var rfcNonce [12]byte
+	binary.LittleEndian.PutUint32(rfcNonce[:], uint32(counter>>32))
+	copy(rfcNonce[4:], nonce)
+
+	for _, rounds := range []int{7, 20} {
+		got := NewChaCha(32).BlockRounds(key[:], nonce, counter, rounds)
+		if expected := chacha.BlockRounds(key, uint32(counter), rfcNonce, rounds); !bytes.Equal(got, expected) {
+			t.Errorf("ChaCha{32}: %d rounds, expected %x, got %x", rounds, expected, got)
+		}
+
+		got = NewSalsa(32).BlockRounds(key[:], nonce, counter, rounds)
+		if expected := salsa.BlockRounds(&key, nonce, counter, rounds); !bytes.Equal(got, expected) {
+			t.Errorf("Salsa{32}: %d rounds, expected %x, got %x", rounds, expected, got)
+		}
+	}
+
+	message := randomBytes(rng, 200)
+	if got, expected := NewSalsa(32).Encrypt(key[:], nonce, message), salsa.Encrypt(&key, nonce, message); !bytes.Equal(got, expected) {
+		t.Errorf("Salsa{32}.Encrypt: expected %x, got %x", expected, got)
+	}
+
+	var zeroHigh [12]byte
+	copy(zeroHigh[4:], nonce)
+	if got, expected := NewChaCha(32).Encrypt(key[:], nonce, message), chacha.Encrypt(key, zeroHigh, message); !bytes.Equal(got, expected) {
+		t.Errorf("ChaCha{32}.Encrypt: expected %x, got %x", expected, got)
+	}
+}
+
+func TestPack(t *testing.T) {
+	w := newWords(4)
+
+	x := w.unpack([]byte{0x21, 0x43}, 4)
+	if x[0] != 1 || x[1] != 2 || x[2] != 3 || x[3] != 4 {
+		t.Errorf("unpack: expected [1 2 3 4], got %v", x)
+	}
+
+	if b := w.pack(x); !bytes.Equal(b, []byte{0x21, 0x43}) {
+		t.Errorf("pack: expected 2143, got %x", b)
+	}
+}
+
+func TestRotations(t *testing.T) {
+	for _, tt := range []struct {
+		width         int
+		chacha, salsa [4]int
+	}{
+		{32, [4]int{16, 12, 8, 7}, [4]int{7, 9, 13, 18}},
+		{16, [4]int{8, 6, 4, 4}, [4]int{4, 5, 7, 9}},
+		{8, [4]int{4, 3, 2, 2}, [4]int{2, 2, 3, 5}},
+		{4, [4]int{2, 2, 1, 1}, [4]int{1, 1, 2, 2}},
+	} {
+		w := newWords(tt.width)
+		if r := w.chacha; r != tt.chacha {
+			t.Errorf("%d bits: ChaCha rotations %v, expected %v", tt.width, r, tt.chacha)
+		}
+		if r := w.salsa; r != tt.salsa {
+			t.Errorf("%d bits: Salsa rotations %v, expected %v", tt.width, r, tt.salsa)
+		}
+	}
+}
+
+type permutation interface {
+	Permute(state *[16]uint32, rounds int)
+	Inverse(state *[16]uint32, from, to int)
+}
+
+func TestInverse(t *testing.T) {
+	rng := rand.New(rand.NewPCG(2, 0))
+
+	for _, width := range widths {
+		for _, p := range []permutation{NewChaCha(width), NewSalsa(width)} {
+			var initial [16]uint32
+			for i := range initial {
+				initial[i] = rng.Uint32() & newWords(width).mask
+			}
+
+			state := initial
+			p.Permute(&state, 9)
+			p.Inverse(&state, 9, 0)
+
+			if state != initial {
+				t.Errorf("%T with %d bits: Inverse doesn't undo Permute", p, width)
+			}
+		}
+	}
+}
+
+func TestDoubleRounds(t *testing.T) {
+	c, s := NewChaCha(8), NewSalsa(8)
+	initial := c.State([]byte("toy key!"), []byte{1, 2}, 3)
+
+	x, y := initial, initial
+	c.InnerBlock(&x)
+	c.Permute(&y, 2)
+	if x != y {
+		t.Errorf("InnerBlock is not 2 rounds of ChaCha")
+	}
+
+	x, y = initial, initial
+	s.DoubleRound(&x)
+	s.Permute(&y, 2)
+	if x != y {
+		t.Errorf("DoubleRound is not 2 rounds of Salsa")
+	}
+}
+
+func TestQuarterRoundCycles(t *testing.T) {
+	for _, cycles := range []func() (map[uint64]uint64, error){NewChaCha(4).QuarterRoundCycles, NewSalsa(4).QuarterRoundCycles} {
+		counts, err := cycles()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// zero is a fixed point of both, the cycles cover all 2^16 states
+		total := uint64(0)
+		for length, n := range counts {
+			total += length * n
+		}
+		if total != 1<<16 || counts[1] == 0 {
+			t.Errorf("QuarterRoundCycles: %v", counts)
+		}
+	}
+
+	if _, err := NewChaCha(8).QuarterRoundCycles(); err == nil {
+		t.Error("QuarterRoundCycles: accepted 8 bit words")
+	}
+}
+
+func TestCycles(t *testing.T) {
+	// x -> x + 3 mod 12 has three cycles of 4
+	counts := Cycles(12, func(x uint64) uint64 { return (x + 3) % 12 })
+	if len(counts) != 1 || counts[4] != 3 {
+		t.Errorf("Cycles: expected 3 cycles of length 4, got %v", counts)
+	}
+}
+
+// The 4 bit ChaCha has a 32 bit key: with half of it known, trying the other
+// half takes a moment and finds the key.
+func TestKeySearch(t *testing.T) {
+	c := NewChaCha(4)
+	key := []byte{0x3c, 0xa5, 0x17, 0xe2}
+	nonce := []byte{0x42}
+	block := c.Block(key, nonce, 0)
+
+	var found [][]byte
+	for guess := 0; guess < 1<<16; guess++ {
+		k := []byte{key[0], key[1], byte(guess), byte(guess >> 8)}
+		if bytes.Equal(c.Block(k, nonce, 0), block) {
+			found = append(found, k)
+		}
+	}
+
+	if len(found) != 1 || !bytes.Equal(found[0], key) {
+		t.Errorf("expected key %x, found %x", key, found)
+	}
+}
+
+func TestAnalysis(t *testing.T) {
+	for _, width := range []int{4, 8, 16} {
+		for _, name := range []string{"chacha", "salsa"} {
+			c, err := analysis.Lookup(fmt.Sprint(name, width))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 16 words out, 8 of key, 2 of nonce and 2 of counter in
+			if c.OutputSize != 2*width || c.InputSize() != 3*width/2 {
+				t.Errorf("%s: output %d bytes, input %d bytes", c.Name, c.OutputSize, c.InputSize())
+			}
+
+			// the full rounds diffuse the small state as well
+			r, err := avalanche.Analyze(c, analysis.All, 20, 256, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sac := r.SAC(); sac < 0.9 {
+				t.Errorf("%s: SAC %f after 20 rounds", c.Name, sac)
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Rounds keeps only the initial state, the state after each round and the
+// feed-forward, dropping the quarter rounds.
+func Rounds(steps []Step) []Step {
+	var out []Step
+	for _, s := range steps {
+		if s.Kind != QuarterRound {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Title describes the step in a few words.
+func (s Step) Title() string {
+	switch s.Kind {
+	case QuarterRound:
+		return fmt.Sprintf("round %d, quarter round %d on words %d %d %d %d", s.Round, s.Quarter, s.Words[0], s.Words[1], s.Words[2], s.Words[3])
+	case ColumnRound, DiagonalRound, RowRound:
+		return fmt.Sprintf("round %d, %s", s.Round, s.Kind)
+	default:
+		return s.Kind.String()
+	}
+}
+
+// changed returns which words of each step differ from the step before it.
+func changed(steps []Step) [][16]bool {
+	out := make([][16]bool, len(steps))
+	for i := 1; i < len(steps); i++ {
+		for w := 0; w < 16; w++ {
+			out[i][w] = steps[i].State[w] != steps[i-1].State[w]
+		}
+	}
+	return out
+}
+
+const (
+	highlight = "\x1b[1;33m"
+	reset     = "\x1b[0m"
+)
+
+// WriteText prints every step as a 4x4 matrix. Words that changed since the
+// previous step are highlighted with terminal colours, or marked with a * when
+// color is false.
+func WriteText(w io.Writer, steps []Step, color bool) error {
+	diff := changed(steps)
+
+	for i, s := range steps {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s\n", s.Title())
+
+		for row := 0; row < 4; row++ {
+			for col := 0; col < 4; col++ {
+				word := row*4 + col
+				cell := fmt.Sprintf("%08x", s.State[word])
+
+				switch {
+				case diff[i][word] && color:
+					cell = " " + highlight + cell + reset
+				case diff[i][word]:
+					cell = "*" + cell
+				default:
+					cell = " " + cell
+				}
+
+				b.WriteString(" " + cell)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type jsonStep struct {
+	Kind    string     `json:"kind"`
+	Round   int        `json:"round,omitempty"`
+	Quarter int        `json:"quarter,omitempty"`
+	Words   []int      `json:"words,omitempty"`
+	State   [16]string `json:"state"`
+	Changed []int      `json:"changed"`
+}
+
+// WriteJSON writes the steps as a JSON array. Words are hex strings and
+// changed lists the words that differ from the previous step.
+func WriteJSON(w io.Writer, steps []Step) error {
+	diff := changed(steps)
+
+	out := make([]jsonStep, len(steps))
+	for i, s := range steps {
+		out[i] = jsonStep{Kind: s.Kind.String(), Round: s.Round, Quarter: s.Quarter, Changed: []int{}}
+
+		if s.Kind == QuarterRound {
+			out[i].Words = s.Words[:]
+		}
+
+		for word := 0; word < 16; word++ {
+			out[i].State[word] = fmt.Sprintf("%08x", s.State[word])
+			if diff[i][word] {
+				out[i].Changed = append(out[i].Changed, word)
+			}
+		}
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(out)
+}
+
+var page = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+section { display: inline-block; margin: 1em; vertical-align: top; }
+table { border-collapse: collapse; font-family: monospace; }
+td { border: 1px solid #ccc; padding: 0.3em 0.5em; }
+td.changed { background: #ffe066; font-weight: bold; }
+td.input { outline: 2px solid #4dabf7; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Steps}}<section>
+<h3>{{.Title}}</h3>
+<table>
+{{range .Rows}}<tr>{{range .}}<td class="{{.Class}}">{{.Word}}</td>{{end}}</tr>
+{{end}}</table>
+</section>
+{{end}}</body>
+</html>
+`))
+
+type htmlCell struct {
+	Word  string
+	Class string
+}
+
+type htmlStep struct {
+	Title string
+	Rows  [4][4]htmlCell
+}
+
+// WriteHTML writes a standalone page with one table per step. Changed words
+// are highlighted, and so are the words a quarter round worked on.
+func WriteHTML(w io.Writer, title string, steps []Step) error {
+	diff := changed(steps)
+
+	out := make([]htmlStep, len(steps))
+	for i, s := range steps {
+		out[i].Title = s.Title()
+
+		for word := 0; word < 16; word++ {
+			var class []string
+			if diff[i][word] {
+				class = append(class, "changed")
+			}
+			if s.Kind == QuarterRound && (s.Words[0] == word || s.Words[1] == word || s.Words[2] == word || s.Words[3] == word) {
+				class = append(class, "input")
+			}
+
+			out[i].Rows[word/4][word%4] = htmlCell{Word: fmt.Sprintf("%08x", s.State[word]), Class: strings.Join(class, " ")}
+		}
+	}
+
+	return page.Execute(w, struct {
+		Title string
+		Steps []htmlStep
+	}{title, out})
+}
@@ -0,0 +1,112 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testSteps() []Step {
+	var steps []Step
+	observe := Collect(&steps)
+
+	state := [16]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+	observe(Step{Kind: Initial, State: state})
+
+	state[0], state[4] = 0x11111111, 0x22222222
+	observe(Step{Kind: QuarterRound, Round: 1, Quarter: 1, Words: [4]int{0, 4, 8, 12}, State: state})
+	observe(Step{Kind: ColumnRound, Round: 1, State: state})
+
+	state[15] = 0x33333333
+	observe(Step{Kind: FeedForward, State: state})
+
+	return steps
+}
+
+func TestRounds(t *testing.T) {
+	var kinds []Kind
+	for _, s := range Rounds(testSteps()) {
+		kinds = append(kinds, s.Kind)
+	}
+
+	expected := []Kind{Initial, ColumnRound, FeedForward}
+	if !slices.Equal(kinds, expected) {
+		t.Errorf("Rounds() = %v, want %v", kinds, expected)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteText(&out, testSteps(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	text := out.String()
+
+	for _, expected := range []string{
+		"initial\n  61707865  3320646e  79622d32  6b206574\n",
+		"round 1, quarter round 1 on words 0 4 8 12\n *11111111  3320646e  79622d32  6b206574\n *22222222",
+		"round 1, column round\n  11111111",
+		"feed-forward\n",
+		"*33333333\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("WriteText() = %s, missing %q", text, expected)
+		}
+	}
+
+	out.Reset()
+	if err := WriteText(&out, testSteps(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), highlight+"11111111"+reset) {
+		t.Errorf("WriteText() with colours did not highlight the change: %q", out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, testSteps()); err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []jsonStep
+	if err := json.Unmarshal(out.Bytes(), &steps); err != nil {
+		t.Fatalf("WriteJSON() is not valid JSON: %s", err)
+	}
+
+	if len(steps) != 4 {
+		t.Fatalf("WriteJSON() has %d steps, want 4", len(steps))
+	}
+
+	if steps[1].Kind != "quarter round" || !slices.Equal(steps[1].Words, []int{0, 4, 8, 12}) || !slices.Equal(steps[1].Changed, []int{0, 4}) {
+		t.Errorf("WriteJSON() quarter round = %+v", steps[1])
+	}
+
+	if steps[0].State[0] != "61707865" || len(steps[0].Changed) != 0 {
+		t.Errorf("WriteJSON() initial = %+v", steps[0])
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteHTML(&out, "ChaCha20 <block>", testSteps()); err != nil {
+		t.Fatal(err)
+	}
+
+	html := out.String()
+
+	for _, expected := range []string{
+		"<title>ChaCha20 &lt;block&gt;</title>",
+		`<td class="changed input">11111111</td>`,
+		`<td class="input">00000000</td>`,
+		`<td class="changed">33333333</td>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("WriteHTML() missing %q", expected)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package trace
+
+// Kind of step the block function went through.
+type Kind int
+
+const (
+	// Initial is the state before any round.
+	Initial Kind = iota
+	// QuarterRound changed the four words in Step.Words.
+	QuarterRound
+	// ColumnRound, DiagonalRound and RowRound are emitted after the four
+	// quarter rounds that make them.
+	ColumnRound
+	DiagonalRound
+	RowRound
+	// FeedForward is the initial state added to the permuted one, the output.
+	FeedForward
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Initial:
+		return "initial"
+	case QuarterRound:
+		return "quarter round"
+	case ColumnRound:
+		return "column round"
+	case DiagonalRound:
+		return "diagonal round"
+	case RowRound:
+		return "row round"
+	case FeedForward:
+		return "feed-forward"
+	default:
+		return "unknown"
+	}
+}
+
+// Step is a snapshot of the 4x4 state.
+type Step struct {
+	Kind Kind
+	// Round starts at 1, it is 0 for Initial and FeedForward.
+	Round int
+	// Quarter is the position of a QuarterRound in its round, from 1 to 4.
+	Quarter int
+	// Words the quarter round worked on, in the order they are passed to it.
+	Words [4]int
+	State [16]uint32
+}
+
+// Observer is called with every step of the block function.
+type Observer func(Step)
+
+// Collect returns an observer that appends every step to steps.
+func Collect(steps *[]Step) Observer {
+	return func(s Step) {
+		*steps = append(*steps, s)
+	}
+}